@@ -0,0 +1,192 @@
+// Package config loads the settings of the service.
+package config
+
+import (
+	"strings"
+	"time"
+)
+
+// Config holds every setting of the service. Each field names the
+// environment variable and the config file key it is read from; fields
+// marked secret are hidden by Settings.
+type Config struct {
+	Database    Database
+	ExternalAPI ExternalAPI
+	Server      Server
+	API         API
+	Log         Log
+	Tracing     Tracing
+	RateLimit   RateLimit
+	Songs       Songs
+	Trash       Trash
+	Jobs        Jobs
+	Import      Import
+	Health      Health
+	Admin       Admin
+
+	sources map[string]Source
+}
+
+type Database struct {
+	URL          string        `env:"DATABASE_URL" key:"database.url" required:"true" secret:"true"`
+	QueryTimeout time.Duration `env:"DB_QUERY_TIMEOUT" key:"database.query_timeout"`
+	// MaxOpenConns of 0 leaves the pool unbounded.
+	MaxOpenConns    int           `env:"DB_MAX_OPEN_CONNS" key:"database.max_open_conns"`
+	MaxIdleConns    int           `env:"DB_MAX_IDLE_CONNS" key:"database.max_idle_conns"`
+	ConnMaxLifetime time.Duration `env:"DB_CONN_MAX_LIFETIME" key:"database.conn_max_lifetime"`
+	ConnMaxIdleTime time.Duration `env:"DB_CONN_MAX_IDLE_TIME" key:"database.conn_max_idle_time"`
+	// ConnectTimeout bounds the attempts to reach the database at startup.
+	ConnectTimeout time.Duration `env:"DB_CONNECT_TIMEOUT" key:"database.connect_timeout"`
+	// MonitorInterval is how often the connection is checked so that its
+	// loss and recovery are logged; 0 disables the checks.
+	MonitorInterval time.Duration `env:"DB_MONITOR_INTERVAL" key:"database.monitor_interval"`
+}
+
+type ExternalAPI struct {
+	URL string `env:"EXTERNAL_API_URL" key:"external_api.url" required:"true"`
+}
+
+type Server struct {
+	Addr              string        `env:"SERVER_ADDR" key:"server.addr"`
+	ReadTimeout       time.Duration `env:"SERVER_READ_TIMEOUT" key:"server.read_timeout"`
+	ReadHeaderTimeout time.Duration `env:"SERVER_READ_HEADER_TIMEOUT" key:"server.read_header_timeout"`
+	WriteTimeout      time.Duration `env:"SERVER_WRITE_TIMEOUT" key:"server.write_timeout"`
+	IdleTimeout       time.Duration `env:"SERVER_IDLE_TIMEOUT" key:"server.idle_timeout"`
+	MaxHeaderBytes    int           `env:"SERVER_MAX_HEADER_BYTES" key:"server.max_header_bytes"`
+	// TrustedProxies lists, separated by commas, the addresses and CIDR
+	// ranges of the proxies whose X-Forwarded-For header is believed. When
+	// empty, the client IP is always the address of the peer.
+	TrustedProxies string `env:"TRUSTED_PROXIES" key:"server.trusted_proxies"`
+	// ShutdownTimeout bounds the time given to in-flight requests and
+	// background workers to finish once shutdown starts.
+	ShutdownTimeout time.Duration `env:"SHUTDOWN_TIMEOUT" key:"server.shutdown_timeout"`
+}
+
+// TrustedProxyList returns the entries of TrustedProxies.
+func (s Server) TrustedProxyList() []string {
+	var proxies []string
+	for _, proxy := range strings.Split(s.TrustedProxies, ",") {
+		if proxy = strings.TrimSpace(proxy); proxy != "" {
+			proxies = append(proxies, proxy)
+		}
+	}
+	return proxies
+}
+
+// API controls the unversioned aliases of version 1 of the API. The dates
+// are formatted as YYYY-MM-DD.
+type API struct {
+	LegacyRoutes       bool   `env:"API_LEGACY_ROUTES" key:"api.legacy_routes"`
+	LegacyDeprecatedAt string `env:"API_LEGACY_DEPRECATED_AT" key:"api.legacy_deprecated_at"`
+	LegacySunset       string `env:"API_LEGACY_SUNSET" key:"api.legacy_sunset"`
+}
+
+// LegacyDates returns the deprecation and sunset dates of the unversioned
+// routes; they are only meaningful once the configuration is validated.
+func (a API) LegacyDates() (deprecatedAt, sunset time.Time) {
+	deprecatedAt, _ = time.Parse(time.DateOnly, a.LegacyDeprecatedAt)
+	sunset, _ = time.Parse(time.DateOnly, a.LegacySunset)
+	return deprecatedAt, sunset
+}
+
+type Log struct {
+	Level  string `env:"LOG_LEVEL" key:"log.level"`
+	Format string `env:"LOG_FORMAT" key:"log.format"`
+}
+
+type Tracing struct {
+	Exporter     string `env:"OTEL_TRACES_EXPORTER" key:"tracing.exporter"`
+	OTLPEndpoint string `env:"OTEL_EXPORTER_OTLP_ENDPOINT" key:"tracing.otlp_endpoint"`
+}
+
+type RateLimit struct {
+	Store   string `env:"RATE_LIMIT_STORE" key:"rate_limit.store"`
+	Default string `env:"RATE_LIMIT_DEFAULT" key:"rate_limit.default"`
+	Routes  string `env:"RATE_LIMIT_ROUTES" key:"rate_limit.routes"`
+}
+
+type Songs struct {
+	RequireIfMatch bool `env:"REQUIRE_IF_MATCH" key:"songs.require_if_match"`
+}
+
+type Trash struct {
+	Retention     time.Duration `env:"TRASH_RETENTION" key:"trash.retention"`
+	PurgeInterval time.Duration `env:"TRASH_PURGE_INTERVAL" key:"trash.purge_interval"`
+}
+
+type Jobs struct {
+	Dir          string        `env:"JOBS_DIR" key:"jobs.dir"`
+	Workers      int           `env:"JOB_WORKERS" key:"jobs.workers"`
+	PollInterval time.Duration `env:"JOB_POLL_INTERVAL" key:"jobs.poll_interval"`
+	Lease        time.Duration `env:"JOB_LEASE" key:"jobs.lease"`
+	// MaxUploadSize is the largest import file accepted, in bytes.
+	MaxUploadSize int `env:"JOB_MAX_UPLOAD_SIZE" key:"jobs.max_upload_size"`
+	// Finished jobs and their artifacts are removed after Retention,
+	// checked every CleanupInterval.
+	Retention       time.Duration `env:"JOB_RETENTION" key:"jobs.retention"`
+	CleanupInterval time.Duration `env:"JOB_CLEANUP_INTERVAL" key:"jobs.cleanup_interval"`
+}
+
+// Import bounds uploads to the import endpoints. MaxUploadSize, in bytes,
+// applies to synchronous imports; jobs have their own limit. Timeout is the
+// time given to send the file and, for synchronous imports, to store it.
+type Import struct {
+	MaxUploadSize int           `env:"IMPORT_MAX_UPLOAD_SIZE" key:"import.max_upload_size"`
+	Timeout       time.Duration `env:"IMPORT_TIMEOUT" key:"import.timeout"`
+}
+
+type Health struct {
+	CheckExternalAPI bool `env:"READINESS_CHECK_EXTERNAL_API" key:"health.check_external_api"`
+	// DrainDelay is the time between failing readiness and closing the
+	// listener on shutdown.
+	DrainDelay time.Duration `env:"SHUTDOWN_DRAIN_DELAY" key:"health.drain_delay"`
+}
+
+type Admin struct {
+	Token string `env:"ADMIN_TOKEN" key:"admin.token" secret:"true"`
+}
+
+// Default returns the settings used when nothing else is configured.
+func Default() Config {
+	return Config{
+		Database: Database{
+			QueryTimeout:    5 * time.Second,
+			MaxOpenConns:    25,
+			MaxIdleConns:    10,
+			ConnMaxLifetime: 30 * time.Minute,
+			ConnMaxIdleTime: 5 * time.Minute,
+			ConnectTimeout:  time.Minute,
+			MonitorInterval: 15 * time.Second,
+		},
+		Server: Server{
+			Addr:              ":8080",
+			ReadTimeout:       30 * time.Second,
+			ReadHeaderTimeout: 5 * time.Second,
+			WriteTimeout:      60 * time.Second,
+			IdleTimeout:       2 * time.Minute,
+			MaxHeaderBytes:    1 << 20,
+			ShutdownTimeout:   30 * time.Second,
+		},
+		API: API{
+			LegacyRoutes:       true,
+			LegacyDeprecatedAt: "2026-10-19",
+			LegacySunset:       "2027-04-30",
+		},
+		Log:       Log{Level: "info", Format: "json"},
+		Tracing:   Tracing{Exporter: "none"},
+		RateLimit: RateLimit{Store: "memory"},
+		Trash:     Trash{Retention: 30 * 24 * time.Hour, PurgeInterval: time.Hour},
+		Jobs: Jobs{
+			Dir:          "data/jobs",
+			Workers:      2,
+			PollInterval: time.Second,
+			Lease:        time.Minute,
+
+			MaxUploadSize:   100 << 20,
+			Retention:       7 * 24 * time.Hour,
+			CleanupInterval: time.Hour,
+		},
+		Import: Import{MaxUploadSize: 20 << 20, Timeout: 10 * time.Minute},
+		Health: Health{DrainDelay: 5 * time.Second},
+	}
+}
@@ -0,0 +1,327 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"maps"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/joho/godotenv"
+	"github.com/pelletier/go-toml/v2"
+	"github.com/sirupsen/logrus"
+	"gopkg.in/yaml.v3"
+)
+
+// Source tells where the value of a setting came from.
+type Source string
+
+const (
+	SourceDefault Source = "default"
+	SourceFile    Source = "file"
+	SourceDotEnv  Source = ".env"
+	SourceEnv     Source = "env"
+)
+
+// ConfigFileEnv names the variable holding the path of the optional config
+// file. It is read from the environment or the .env file.
+const ConfigFileEnv = "CONFIG_FILE"
+
+const redacted = "[REDACTED]"
+
+// Load builds the configuration from, in increasing order of precedence, the
+// defaults, the YAML or TOML file named by CONFIG_FILE, the dotEnvFile and
+// the environment, then validates it. A missing dotEnvFile is not an error.
+// All invalid settings are reported together.
+func Load(dotEnvFile string) (*Config, error) {
+	dotEnv, err := readDotEnv(dotEnvFile)
+	if err != nil {
+		return nil, err
+	}
+	config := Default()
+	config.sources = make(map[string]Source)
+	fields := config.fields()
+
+	var layers []layer
+	path := dotEnv[ConfigFileEnv]
+	if value, ok := os.LookupEnv(ConfigFileEnv); ok {
+		path = value
+	}
+	if path != "" {
+		values, err := readFile(path, fields)
+		if err != nil {
+			return nil, err
+		}
+		layers = append(layers, layer{SourceFile, values})
+	}
+	layers = append(layers, layer{SourceDotEnv, dotEnv}, layer{SourceEnv, environ()})
+
+	var errs []error
+	for _, l := range layers {
+		for _, f := range fields {
+			name := f.name(l.source)
+			value, ok := l.values[name]
+			// An empty variable counts as unset, as in docker-compose files
+			// that pass through variables which may not be defined.
+			if !ok || value == "" {
+				continue
+			}
+			if err := f.set(value); err != nil {
+				errs = append(errs, fmt.Errorf("%s (from %s): %w", name, l.source, err))
+				continue
+			}
+			config.sources[f.env] = l.source
+		}
+	}
+	if len(errs) > 0 {
+		return nil, fmt.Errorf("invalid configuration: %w", errors.Join(errs...))
+	}
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+	return &config, nil
+}
+
+// Validate checks that required settings are present and that every value is
+// usable.
+func (c *Config) Validate() error {
+	var errs []error
+	for _, f := range c.fields() {
+		if f.required && f.value.IsZero() {
+			errs = append(errs, fmt.Errorf("%s is required", f.env))
+		}
+	}
+	check := func(ok bool, format string, args ...any) {
+		if !ok {
+			errs = append(errs, fmt.Errorf(format, args...))
+		}
+	}
+	if c.ExternalAPI.URL != "" {
+		u, err := url.Parse(c.ExternalAPI.URL)
+		check(err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != "",
+			"EXTERNAL_API_URL %q must be an http or https URL", c.ExternalAPI.URL)
+	}
+	_, err := logrus.ParseLevel(c.Log.Level)
+	check(err == nil, "LOG_LEVEL %q must be trace, debug, info, warn or error", c.Log.Level)
+	check(c.Log.Format == "json" || c.Log.Format == "text", "LOG_FORMAT %q must be json or text", c.Log.Format)
+	check(c.Tracing.Exporter == "none" || c.Tracing.Exporter == "otlp" || c.Tracing.Exporter == "stdout",
+		"OTEL_TRACES_EXPORTER %q must be otlp, stdout or none", c.Tracing.Exporter)
+	check(c.RateLimit.Store == "memory" || c.RateLimit.Store == "postgres",
+		"RATE_LIMIT_STORE %q must be memory or postgres", c.RateLimit.Store)
+	check(c.Database.MaxOpenConns >= 0, "DB_MAX_OPEN_CONNS must not be negative")
+	check(c.Database.MaxIdleConns >= 0, "DB_MAX_IDLE_CONNS must not be negative")
+	check(c.Database.MaxOpenConns == 0 || c.Database.MaxIdleConns <= c.Database.MaxOpenConns,
+		"DB_MAX_IDLE_CONNS must not exceed DB_MAX_OPEN_CONNS")
+	check(c.Database.ConnectTimeout > 0, "DB_CONNECT_TIMEOUT must be positive")
+	deprecatedAt, deprecatedErr := time.Parse(time.DateOnly, c.API.LegacyDeprecatedAt)
+	check(deprecatedErr == nil, "API_LEGACY_DEPRECATED_AT %q must be a date formatted as YYYY-MM-DD", c.API.LegacyDeprecatedAt)
+	sunset, sunsetErr := time.Parse(time.DateOnly, c.API.LegacySunset)
+	check(sunsetErr == nil, "API_LEGACY_SUNSET %q must be a date formatted as YYYY-MM-DD", c.API.LegacySunset)
+	check(deprecatedErr != nil || sunsetErr != nil || !sunset.Before(deprecatedAt),
+		"API_LEGACY_SUNSET must not be before API_LEGACY_DEPRECATED_AT")
+	check(c.Server.Addr != "", "SERVER_ADDR must not be empty")
+	check(c.Server.MaxHeaderBytes > 0, "SERVER_MAX_HEADER_BYTES must be positive")
+	for _, proxy := range c.Server.TrustedProxyList() {
+		_, _, cidrErr := net.ParseCIDR(proxy)
+		check(cidrErr == nil || net.ParseIP(proxy) != nil, "TRUSTED_PROXIES entry %q must be an IP address or CIDR range", proxy)
+	}
+	check(c.Jobs.Dir != "", "JOBS_DIR must not be empty")
+	check(c.Jobs.Workers > 0, "JOB_WORKERS must be positive")
+	check(c.Trash.Retention > 0, "TRASH_RETENTION must be positive")
+	check(c.Trash.PurgeInterval > 0, "TRASH_PURGE_INTERVAL must be positive")
+	check(c.Jobs.PollInterval > 0, "JOB_POLL_INTERVAL must be positive")
+	check(c.Jobs.Lease > 0, "JOB_LEASE must be positive")
+	check(c.Jobs.MaxUploadSize > 0, "JOB_MAX_UPLOAD_SIZE must be positive")
+	check(c.Jobs.Retention > 0, "JOB_RETENTION must be positive")
+	check(c.Jobs.CleanupInterval > 0, "JOB_CLEANUP_INTERVAL must be positive")
+	check(c.Import.MaxUploadSize > 0, "IMPORT_MAX_UPLOAD_SIZE must be positive")
+	check(c.Import.Timeout > 0, "IMPORT_TIMEOUT must be positive")
+	if len(errs) > 0 {
+		return fmt.Errorf("invalid configuration: %w", errors.Join(errs...))
+	}
+	return nil
+}
+
+// Setting is one configuration value as shown to administrators.
+type Setting struct {
+	Name   string `json:"name" example:"DB_QUERY_TIMEOUT"`
+	Key    string `json:"key" example:"database.query_timeout"`
+	Value  string `json:"value" example:"5s"`
+	Source Source `json:"source" example:"default"`
+}
+
+// Settings lists every setting with its origin, hiding the values of
+// secrets.
+func (c *Config) Settings() []Setting {
+	fields := c.fields()
+	settings := make([]Setting, 0, len(fields))
+	for _, f := range fields {
+		value := f.String()
+		if f.secret && value != "" {
+			value = redacted
+		}
+		source, ok := c.sources[f.env]
+		if !ok {
+			source = SourceDefault
+		}
+		settings = append(settings, Setting{Name: f.env, Key: f.key, Value: value, Source: source})
+	}
+	return settings
+}
+
+// layer is one source of settings, keyed by variable name or, for the
+// config file, by dotted key.
+type layer struct {
+	source Source
+	values map[string]string
+}
+
+type field struct {
+	env      string
+	key      string
+	required bool
+	secret   bool
+	value    reflect.Value
+}
+
+// fields returns the settings of c in declaration order.
+func (c *Config) fields() []field {
+	var fields []field
+	sections := reflect.ValueOf(c).Elem()
+	for i := 0; i < sections.NumField(); i++ {
+		section := sections.Field(i)
+		if !sections.Type().Field(i).IsExported() {
+			continue
+		}
+		for j := 0; j < section.NumField(); j++ {
+			tag := section.Type().Field(j).Tag
+			fields = append(fields, field{
+				env:      tag.Get("env"),
+				key:      tag.Get("key"),
+				required: tag.Get("required") == "true",
+				secret:   tag.Get("secret") == "true",
+				value:    section.Field(j),
+			})
+		}
+	}
+	return fields
+}
+
+func (f field) name(source Source) string {
+	if source == SourceFile {
+		return f.key
+	}
+	return f.env
+}
+
+func (f field) set(value string) error {
+	value = strings.TrimSpace(value)
+	switch f.value.Interface().(type) {
+	case string:
+		f.value.SetString(value)
+	case bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("%q is not a boolean", value)
+		}
+		f.value.SetBool(b)
+	case int:
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("%q is not a number", value)
+		}
+		f.value.SetInt(int64(n))
+	case time.Duration:
+		d, err := time.ParseDuration(value)
+		if err != nil || d < 0 {
+			return fmt.Errorf("%q is not a duration such as 30s or 5m", value)
+		}
+		f.value.SetInt(int64(d))
+	}
+	return nil
+}
+
+func (f field) String() string {
+	return fmt.Sprint(f.value.Interface())
+}
+
+func readDotEnv(path string) (map[string]string, error) {
+	values, err := godotenv.Read(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return map[string]string{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	return values, nil
+}
+
+func environ() map[string]string {
+	values := make(map[string]string)
+	for _, entry := range os.Environ() {
+		if name, value, ok := strings.Cut(entry, "="); ok {
+			values[name] = value
+		}
+	}
+	return values
+}
+
+// readFile reads a YAML or TOML config file, chosen by its extension, into
+// values keyed by dotted paths such as "server.addr". Unknown keys are
+// rejected so that typos do not go unnoticed.
+func readFile(path string, fields []field) (map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file: %w", err)
+	}
+	var tree map[string]any
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &tree)
+	case ".toml":
+		err = toml.Unmarshal(data, &tree)
+	default:
+		return nil, fmt.Errorf("unsupported config file %s: expected .yaml, .yml or .toml", path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse config file %s: %w", path, err)
+	}
+
+	values := make(map[string]string)
+	flatten("", tree, values)
+	known := make(map[string]bool, len(fields))
+	for _, f := range fields {
+		known[f.key] = true
+	}
+	var errs []error
+	for _, key := range slices.Sorted(maps.Keys(values)) {
+		if !known[key] {
+			errs = append(errs, fmt.Errorf("unknown setting %s", key))
+		}
+	}
+	if len(errs) > 0 {
+		return nil, fmt.Errorf("invalid config file %s: %w", path, errors.Join(errs...))
+	}
+	return values, nil
+}
+
+func flatten(prefix string, tree map[string]any, values map[string]string) {
+	for key, value := range tree {
+		if prefix != "" {
+			key = prefix + "." + key
+		}
+		if nested, ok := value.(map[string]any); ok {
+			flatten(key, nested, values)
+			continue
+		}
+		values[key] = fmt.Sprint(value)
+	}
+}
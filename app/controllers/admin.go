@@ -0,0 +1,94 @@
+package controllers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lmd1e/song_library/app/config"
+	"github.com/lmd1e/song_library/app/requests"
+	"github.com/lmd1e/song_library/app/utils"
+)
+
+type AdminController struct {
+	config *config.Config
+}
+
+func NewAdminController(config *config.Config) *AdminController {
+	return &AdminController{config: config}
+}
+
+// @Summary Конфигурация сервиса
+// @Description Значения всех настроек при запуске и их источник: default, file, .env или env. Значения секретов скрыты. Доступно, только если задан ADMIN_TOKEN.
+// @Tags Admin
+// @Produce json
+// @Param Authorization header string true "Bearer и значение ADMIN_TOKEN"
+// @Success 200 {array} config.Setting
+// @Failure 401 {object} utils.Problem
+// @Failure 404 {object} utils.Problem
+// @Router /admin/config [get]
+func (c *AdminController) GetConfig(ctx *gin.Context) {
+	requestLogger(ctx).Debug("GetConfig request received")
+	ctx.JSON(http.StatusOK, c.config.Settings())
+}
+
+// @Summary Настройки логирования
+// @Description Текущий уровень и формат логов. Доступно, только если задан ADMIN_TOKEN.
+// @Tags Admin
+// @Produce json
+// @Param Authorization header string true "Bearer и значение ADMIN_TOKEN"
+// @Success 200 {object} requests.LoggingSettings
+// @Failure 401 {object} utils.Problem
+// @Failure 404 {object} utils.Problem
+// @Router /admin/logging [get]
+func (c *AdminController) GetLogging(ctx *gin.Context) {
+	requestLogger(ctx).Debug("GetLogging request received")
+	ctx.JSON(http.StatusOK, loggingSettings())
+}
+
+// @Summary Изменение настроек логирования
+// @Description Меняет уровень и/или формат логов без перезапуска сервиса; незаданные поля не меняются. Доступно, только если задан ADMIN_TOKEN.
+// @Tags Admin
+// @Accept json
+// @Produce json
+// @Param Authorization header string true "Bearer и значение ADMIN_TOKEN"
+// @Param settings body requests.LoggingSettings true "Уровень и формат логов"
+// @Success 200 {object} requests.LoggingSettings
+// @Failure 400 {object} utils.Problem
+// @Failure 401 {object} utils.Problem
+// @Failure 404 {object} utils.Problem
+// @Router /admin/logging [patch]
+func (c *AdminController) UpdateLogging(ctx *gin.Context) {
+	requestLogger(ctx).Debug("UpdateLogging request received")
+	var req requests.LoggingSettings
+	if err := bindJSON(ctx, &req); err != nil {
+		respondInvalidPayload(ctx, err)
+		return
+	}
+	// Both values are checked before either is applied.
+	if req.Level != "" {
+		if _, err := utils.ParseLogLevel(req.Level); err != nil {
+			utils.RespondWithProblem(ctx, http.StatusBadRequest, "invalid_parameter", err.Error())
+			return
+		}
+	}
+	if req.Format != "" && !utils.ValidLogFormat(req.Format) {
+		utils.RespondWithProblem(ctx, http.StatusBadRequest, "invalid_parameter", "Invalid log format, expected json or text")
+		return
+	}
+	if req.Level != "" {
+		utils.SetLogLevel(req.Level)
+	}
+	if req.Format != "" {
+		utils.SetLogFormat(req.Format)
+	}
+	settings := loggingSettings()
+	requestLogger(ctx).Warnf("Logging changed to level %s, format %s", settings.Level, settings.Format)
+	ctx.JSON(http.StatusOK, settings)
+}
+
+func loggingSettings() requests.LoggingSettings {
+	return requests.LoggingSettings{
+		Level:  utils.Logger.GetLevel().String(),
+		Format: utils.LogFormat(),
+	}
+}
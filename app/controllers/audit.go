@@ -0,0 +1,89 @@
+package controllers
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lmd1e/song_library/app/models"
+	"github.com/lmd1e/song_library/app/repositories"
+	"github.com/lmd1e/song_library/app/utils"
+)
+
+type AuditController struct {
+	repo repositories.AuditRepository
+}
+
+func NewAuditController(repo repositories.AuditRepository) *AuditController {
+	return &AuditController{repo: repo}
+}
+
+// @Summary Журнал изменений песен
+// @Description Получение записей журнала аудита с фильтрацией по песне, автору и периоду времени
+// @Tags Audit
+// @Accept json
+// @Produce json
+// @Param song_id query int false "Фильтр по ID песни"
+// @Param actor query string false "Фильтр по автору изменения"
+// @Param from query string false "Начало периода (RFC3339)"
+// @Param to query string false "Конец периода (RFC3339)"
+// @Param limit query int false "Количество записей на странице"
+// @Param offset query int false "Смещение (страница)"
+// @Success 200 {array} models.AuditEntry
+// @Failure 400 {object} utils.Problem
+// @Failure 500 {object} utils.Problem
+// @Failure 504 {object} utils.Problem
+// @Router /api/v1/audit [get]
+func (c *AuditController) GetAudit(ctx *gin.Context) {
+	requestLogger(ctx).Debug("GetAudit request received")
+	var filter models.AuditFilter
+	var err error
+	if songID := ctx.Query("song_id"); songID != "" {
+		if filter.SongID, err = strconv.Atoi(songID); err != nil {
+			utils.RespondWithProblem(ctx, http.StatusBadRequest, "invalid_parameter", "Invalid song_id")
+			return
+		}
+	}
+	filter.Actor = ctx.Query("actor")
+	if from := ctx.Query("from"); from != "" {
+		if filter.From, err = time.Parse(time.RFC3339, from); err != nil {
+			utils.RespondWithProblem(ctx, http.StatusBadRequest, "invalid_parameter", "Invalid from, expected RFC3339 time")
+			return
+		}
+	}
+	if to := ctx.Query("to"); to != "" {
+		if filter.To, err = time.Parse(time.RFC3339, to); err != nil {
+			utils.RespondWithProblem(ctx, http.StatusBadRequest, "invalid_parameter", "Invalid to, expected RFC3339 time")
+			return
+		}
+	}
+	limit, _ := strconv.Atoi(ctx.DefaultQuery("limit", "10"))
+	offset, _ := strconv.Atoi(ctx.DefaultQuery("offset", "0"))
+
+	entries, err := c.repo.GetAuditEntries(ctx.Request.Context(), filter, limit, offset)
+	if err != nil {
+		respondSongError(ctx, err, "Failed to fetch audit entries")
+		return
+	}
+	ctx.JSON(http.StatusOK, entries)
+}
+
+// auditMeta describes the author of a mutation. The actor is taken from the
+// X-Actor header and defaults to the client IP, which comes from
+// X-Forwarded-For only behind a trusted proxy; the request id is the one
+// assigned by the RequestID middleware.
+func auditMeta(ctx *gin.Context) models.AuditMeta {
+	actor := ctx.GetHeader("X-Actor")
+	if actor == "" {
+		actor = ctx.ClientIP()
+	}
+	requestID := utils.RequestIDFromContext(ctx.Request.Context())
+	if requestID == "" {
+		requestID = ctx.GetHeader("X-Request-ID")
+	}
+	return models.AuditMeta{
+		Actor:     actor,
+		RequestID: requestID,
+	}
+}
@@ -0,0 +1,184 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/lmd1e/song_library/app/models"
+	"github.com/lmd1e/song_library/app/repositories"
+	"github.com/lmd1e/song_library/app/requests"
+)
+
+var (
+	errInvalidOperation     = errors.New("invalid operation")
+	errPreconditionRequired = errors.New("precondition required")
+	errBatchRolledBack      = errors.New("batch rolled back")
+)
+
+// @Summary Пакетное изменение песен
+// @Description Выполняет список операций create, update, patch и delete в одной транзакции. В режиме all_or_nothing ошибка любой операции отменяет весь пакет, в режиме best_effort сохраняются все успешные операции. Для каждой операции возвращается свой статус. Если включён REQUIRE_IF_MATCH, операции update, patch и delete без version отклоняются со статусом 428.
+// @Tags Songs
+// @Accept json
+// @Produce json
+// @Param batch body requests.BatchRequest true "Операции"
+// @Param X-Actor header string false "Автор изменения для журнала аудита"
+// @Success 200 {object} requests.BatchResponse
+// @Failure 400 {object} utils.Problem
+// @Failure 409 {object} requests.BatchResponse
+// @Failure 500 {object} utils.Problem
+// @Failure 504 {object} utils.Problem
+// @Router /api/v1/songs/batch [post]
+func (c *SongController) BatchSongs(ctx *gin.Context) {
+	requestLogger(ctx).Debug("BatchSongs request received")
+	var req requests.BatchRequest
+	// Operations are validated one by one, so that an invalid operation
+	// fails on its own in best-effort mode.
+	if err := bindJSON(ctx, &req); err != nil {
+		respondInvalidPayload(ctx, err)
+		return
+	}
+	if req.Mode == "" {
+		req.Mode = requests.BatchModeAllOrNothing
+	}
+
+	meta := auditMeta(ctx)
+	resp := requests.BatchResponse{Mode: req.Mode, Results: make([]requests.BatchOperationResult, len(req.Operations))}
+	failed := -1
+	err := c.repo.WithTx(ctx.Request.Context(), func(tx repositories.SongRepository) error {
+		for i, op := range req.Operations {
+			result := &resp.Results[i]
+			result.Index = i
+			result.Op = op.Op
+			if failed >= 0 && req.Mode == requests.BatchModeAllOrNothing {
+				result.Status = http.StatusFailedDependency
+				result.Code = "not_executed"
+				result.Error = fmt.Sprintf("Not executed: operation %d failed", failed)
+				continue
+			}
+			// Every write runs in its own savepoint, so a failed operation
+			// leaves the transaction usable in best-effort mode.
+			song, status, code, err := c.runBatchOperation(ctx.Request.Context(), tx, op, meta)
+			result.Status = status
+			if err != nil {
+				result.Code = code
+				result.Error = err.Error()
+				if failed < 0 {
+					failed = i
+				}
+				continue
+			}
+			result.Song = song
+		}
+		if failed >= 0 && req.Mode == requests.BatchModeAllOrNothing {
+			return errBatchRolledBack
+		}
+		return nil
+	})
+
+	switch {
+	case errors.Is(err, errBatchRolledBack):
+		for i := 0; i < failed; i++ {
+			resp.Results[i].Status = http.StatusFailedDependency
+			resp.Results[i].Song = nil
+			resp.Results[i].Code = "rolled_back"
+			resp.Results[i].Error = fmt.Sprintf("Rolled back: operation %d failed", failed)
+		}
+		ctx.JSON(http.StatusConflict, resp)
+	case err != nil:
+		respondSongError(ctx, err, "Failed to run batch")
+	default:
+		resp.Committed = true
+		ctx.JSON(http.StatusOK, resp)
+	}
+}
+
+// runBatchOperation applies one operation and returns the resulting song, if
+// any, with the status and error code the operation would have had as a
+// separate request.
+func (c *SongController) runBatchOperation(ctx context.Context, repo repositories.SongRepository, op requests.BatchOperation, meta models.AuditMeta) (*requests.SongResponse, int, string, error) {
+	if err := validateBatchOperation(op); err != nil {
+		return nil, http.StatusBadRequest, "invalid_operation", err
+	}
+	// The version of an operation stands in for If-Match.
+	if c.requireIfMatch && op.Op != requests.BatchOpCreate && op.Version <= 0 {
+		return nil, http.StatusPreconditionRequired, "precondition_required",
+			fmt.Errorf("%w: %s requires version", errPreconditionRequired, op.Op)
+	}
+
+	var song models.Song
+	var err error
+	status := http.StatusOK
+	switch op.Op {
+	case requests.BatchOpCreate:
+		song, err = repo.AddSong(ctx, op.Song.ToSong(0, 0), meta)
+		status = http.StatusCreated
+	case requests.BatchOpUpdate:
+		song, err = repo.UpdateSong(ctx, op.Song.ToSong(op.ID, op.Version), meta)
+	case requests.BatchOpPatch:
+		song, err = repo.PatchSong(ctx, op.ID, op.Patch.ToPatch(op.Version), meta)
+	case requests.BatchOpDelete:
+		err = repo.DeleteSong(ctx, op.ID, op.Version, meta)
+		if err == nil {
+			return nil, http.StatusOK, "", nil
+		}
+	}
+	if err != nil {
+		status, code, message := songError(err, "Failed to "+op.Op+" song")
+		logSongError(ctx, status, message, err)
+		return nil, status, code, errors.New(message)
+	}
+	resp := requests.NewSongResponse(song)
+	return &resp, status, "", nil
+}
+
+func validateBatchOperation(op requests.BatchOperation) error {
+	switch op.Op {
+	case requests.BatchOpCreate:
+		if op.Song == nil {
+			return fmt.Errorf("%w: create requires song", errInvalidOperation)
+		}
+		return validateBatchPayload(op)
+	case requests.BatchOpUpdate, requests.BatchOpPatch, requests.BatchOpDelete:
+	default:
+		return fmt.Errorf("%w: op must be one of create, update, patch, delete", errInvalidOperation)
+	}
+	if op.ID <= 0 {
+		return fmt.Errorf("%w: %s requires id", errInvalidOperation, op.Op)
+	}
+	if op.Op == requests.BatchOpUpdate && op.Song == nil {
+		return fmt.Errorf("%w: update requires song", errInvalidOperation)
+	}
+	if op.Op == requests.BatchOpPatch && op.Patch == nil {
+		return fmt.Errorf("%w: patch requires patch", errInvalidOperation)
+	}
+	return validateBatchPayload(op)
+}
+
+// validateBatchPayload applies the rules of the song and patch bodies to the
+// payload of an operation.
+func validateBatchPayload(op requests.BatchOperation) error {
+	var payload any
+	var name string
+	switch {
+	case op.Song != nil && (op.Op == requests.BatchOpCreate || op.Op == requests.BatchOpUpdate):
+		payload, name = op.Song, "song"
+	case op.Patch != nil && op.Op == requests.BatchOpPatch:
+		payload, name = op.Patch, "patch"
+	default:
+		return nil
+	}
+	err := binding.Validator.ValidateStruct(payload)
+	if err == nil {
+		return nil
+	}
+	var problems []string
+	for _, field := range fieldErrors(err) {
+		problems = append(problems, name+"."+field.Field+" "+field.Message)
+	}
+	return fmt.Errorf("%w: %s", errInvalidOperation, strings.Join(problems, "; "))
+}
@@ -0,0 +1,133 @@
+package controllers
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"maps"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lmd1e/song_library/app/models"
+	"github.com/lmd1e/song_library/app/utils"
+)
+
+// etagSuffixes tell apart the representations of a song other than JSON,
+// whose tag is the bare version.
+var etagSuffixes = map[string]string{
+	utils.MediaTypeXML:  "xml",
+	utils.MediaTypeCSV:  "csv",
+	utils.MediaTypeYAML: "yaml",
+}
+
+// songETag is the entity tag of a song in the media type negotiated for the
+// request, e.g. "3" in JSON and "3-xml" in XML. It changes with every new
+// version, and every representation has a tag of its own.
+func songETag(ctx *gin.Context, song models.Song) string {
+	tag := strconv.Itoa(song.Version)
+	if suffix, ok := etagSuffixes[utils.MediaTypeFromContext(ctx.Request.Context())]; ok {
+		tag += "-" + suffix
+	}
+	return `"` + tag + `"`
+}
+
+// etagVersion returns the song version of a strong tag made by songETag, in
+// any representation.
+func etagVersion(tag string) (int, bool) {
+	if len(tag) < 2 || tag[0] != '"' || tag[len(tag)-1] != '"' {
+		return 0, false
+	}
+	tag, suffix, hasSuffix := strings.Cut(tag[1:len(tag)-1], "-")
+	if hasSuffix && !slices.Contains(slices.Collect(maps.Values(etagSuffixes)), suffix) {
+		return 0, false
+	}
+	version, err := strconv.Atoi(tag)
+	return version, err == nil && version > 0
+}
+
+// ifMatchVersion returns the song version required by the If-Match header,
+// or 0 when any version is acceptable. The header may list several tags, any
+// of which may match; when it does, the current version of the song decides
+// which one applies. The tag of any representation of a version matches it.
+// If-Match uses the strong comparison, so weak tags never match. On failure
+// it responds with 412 and returns false.
+func (c *SongController) ifMatchVersion(ctx *gin.Context, songID int) (version int, ok bool) {
+	header := strings.TrimSpace(ctx.GetHeader("If-Match"))
+	if header == "" {
+		return 0, true
+	}
+	var versions []int
+	weak := false
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" {
+			return 0, true
+		}
+		if strings.HasPrefix(candidate, "W/") {
+			weak = true
+			continue
+		}
+		if v, ok := etagVersion(candidate); ok {
+			versions = append(versions, v)
+		}
+	}
+
+	switch {
+	case len(versions) == 0 && weak:
+		utils.RespondWithProblem(ctx, http.StatusPreconditionFailed, "weak_etag", "If-Match requires a strong entity tag")
+		return 0, false
+	case len(versions) == 0:
+		utils.RespondWithProblem(ctx, http.StatusPreconditionFailed, "version_conflict", "Song version does not match If-Match")
+		return 0, false
+	case len(versions) == 1:
+		return versions[0], true
+	}
+
+	song, err := c.repo.GetSong(ctx.Request.Context(), songID)
+	if err != nil {
+		respondSongError(ctx, err, "Failed to fetch song")
+		return 0, false
+	}
+	if !slices.Contains(versions, song.Version) {
+		utils.RespondWithProblem(ctx, http.StatusPreconditionFailed, "version_conflict", "Song version does not match If-Match")
+		return 0, false
+	}
+	// The write still checks the version, in case the song changes first.
+	return song.Version, true
+}
+
+// notModified answers with 304 Not Modified when the If-None-Match header
+// matches etag, using the weak comparison required for GET requests.
+func notModified(ctx *gin.Context, etag string) bool {
+	header := ctx.GetHeader("If-None-Match")
+	if header == "" {
+		return false
+	}
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == strings.TrimPrefix(etag, "W/") {
+			ctx.Status(http.StatusNotModified)
+			return true
+		}
+	}
+	return false
+}
+
+// respondWithETag renders payload in the negotiated media type with a weak
+// ETag derived from the encoded body, so that unchanged collections can be
+// revalidated cheaply and every representation has its own tag.
+func respondWithETag(ctx *gin.Context, code int, payload interface{}) {
+	body, contentType, ok := renderBody(ctx, payload)
+	if !ok {
+		return
+	}
+	sum := sha256.Sum256(body)
+	etag := `W/"` + hex.EncodeToString(sum[:16]) + `"`
+	ctx.Header("ETag", etag)
+	if notModified(ctx, etag) {
+		return
+	}
+	ctx.Data(code, contentType, body)
+}
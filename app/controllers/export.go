@@ -0,0 +1,78 @@
+package controllers
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lmd1e/song_library/app/repositories"
+	"github.com/lmd1e/song_library/app/server"
+	"github.com/lmd1e/song_library/app/services"
+	"github.com/lmd1e/song_library/app/utils"
+)
+
+// @Summary Выгрузка библиотеки
+// @Description Потоковая выгрузка всех песен, подходящих под фильтры списка, в CSV, NDJSON или JSON. С gzip=true файл сжимается.
+// @Tags Export
+// @Produce text/csv
+// @Produce application/x-ndjson
+// @Produce json
+// @Produce application/gzip
+// @Param format query string false "Формат файла" Enums(csv, ndjson, json) default(ndjson)
+// @Param gzip query bool false "Сжать файл gzip"
+// @Param group query string false "Фильтр по группе"
+// @Param song query string false "Фильтр по названию песни"
+// @Param release_date query string false "Фильтр по дате выхода"
+// @Param text query string false "Фильтр по тексту"
+// @Param link query string false "Фильтр по ссылке"
+// @Success 200 {file} file
+// @Header 200 {string} Content-Disposition "Имя файла выгрузки"
+// @Failure 400 {object} utils.Problem
+// @Failure 500 {object} utils.Problem
+// @Failure 504 {object} utils.Problem
+// @Router /api/v1/songs/export [get]
+func (c *SongController) ExportSongs(ctx *gin.Context) {
+	requestLogger(ctx).Debug("ExportSongs request received")
+	opts := services.ExportOptions{
+		Format: ctx.DefaultQuery("format", services.FormatNDJSON),
+		Filter: songFilter(ctx),
+	}
+	if _, ok := services.ExportContentTypes[opts.Format]; !ok {
+		utils.RespondWithProblem(ctx, http.StatusBadRequest, "invalid_parameter", "Invalid format, expected csv, ndjson or json")
+		return
+	}
+	opts.Gzip, _ = strconv.ParseBool(ctx.Query("gzip"))
+
+	// A large export may take longer than the server write timeout.
+	server.LiftDeadlines(ctx.Writer)
+	ctx.Header("Content-Type", opts.ContentType())
+	ctx.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, opts.Filename(time.Now())))
+
+	count, err := services.NewExporter(c.repo).Export(ctx.Request.Context(), ctx.Writer, opts)
+	if err != nil {
+		// Once streaming has started the status can no longer change; the
+		// response is left truncated.
+		if !ctx.Writer.Written() {
+			ctx.Writer.Header().Del("Content-Type")
+			ctx.Writer.Header().Del("Content-Disposition")
+			respondSongError(ctx, err, "Failed to export songs")
+			return
+		}
+		requestLogger(ctx).Error("Failed to export songs: ", err)
+		return
+	}
+	requestLogger(ctx).Infof("Exported %d songs", count)
+}
+
+// songFilter collects the song filters from the query string.
+func songFilter(ctx *gin.Context) map[string]string {
+	filter := make(map[string]string)
+	for field := range repositories.SongFilterFields {
+		if value, ok := ctx.GetQuery(field); ok {
+			filter[field] = value
+		}
+	}
+	return filter
+}
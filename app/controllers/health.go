@@ -0,0 +1,43 @@
+package controllers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lmd1e/song_library/app/services"
+)
+
+type HealthController struct {
+	checker *services.HealthChecker
+}
+
+func NewHealthController(checker *services.HealthChecker) *HealthController {
+	return &HealthController{checker: checker}
+}
+
+// @Summary Проверка жизнеспособности
+// @Description Отвечает, пока процесс запущен; зависимости не проверяются
+// @Tags Health
+// @Produce json
+// @Success 200 {object} map[string]string
+// @Router /healthz [get]
+func (c *HealthController) Liveness(ctx *gin.Context) {
+	ctx.JSON(http.StatusOK, gin.H{"status": services.HealthStatusUp})
+}
+
+// @Summary Проверка готовности
+// @Description Проверяет базу данных, актуальность версии схемы и, если включено, внешний API. Возвращает статус и задержку каждой проверки; во время остановки сервиса отвечает 503.
+// @Tags Health
+// @Produce json
+// @Success 200 {object} services.HealthReport
+// @Failure 503 {object} services.HealthReport
+// @Router /readyz [get]
+func (c *HealthController) Readiness(ctx *gin.Context) {
+	report := c.checker.Check(ctx.Request.Context())
+	status := http.StatusOK
+	if report.Status != services.HealthStatusUp {
+		status = http.StatusServiceUnavailable
+		requestLogger(ctx).Warn("Readiness check failed: ", report.Checks)
+	}
+	ctx.JSON(status, report)
+}
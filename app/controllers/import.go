@@ -0,0 +1,179 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lmd1e/song_library/app/config"
+	"github.com/lmd1e/song_library/app/server"
+	"github.com/lmd1e/song_library/app/services"
+	"github.com/lmd1e/song_library/app/utils"
+)
+
+// @Summary Массовый импорт песен
+// @Description Потоковый импорт песен из CSV (с заголовком) или NDJSON. Файл передаётся телом запроса или полем file формы multipart/form-data. Ошибки отдельных строк возвращаются в ответе, остальные строки сохраняются пакетами.
+// @Tags Import
+// @Accept text/csv
+// @Accept application/x-ndjson
+// @Accept multipart/form-data
+// @Produce json
+// @Param format query string false "Формат файла; по умолчанию определяется по Content-Type" Enums(csv, ndjson)
+// @Param map query string false "Соответствие полей колонкам файла, например group:artist,song:title"
+// @Param enrich query bool false "Дополнить недостающие поля данными внешнего API"
+// @Param dry_run query bool false "Только проверить файл, ничего не сохраняя"
+// @Param X-Actor header string false "Автор изменения для журнала аудита"
+// @Success 200 {object} services.ImportResult
+// @Failure 400 {object} utils.Problem
+// @Failure 413 {object} utils.Problem
+// @Failure 500 {object} utils.Problem
+// @Failure 504 {object} utils.Problem
+// @Router /api/v1/songs/import [post]
+func (c *SongController) ImportSongs(ctx *gin.Context) {
+	requestLogger(ctx).Debug("ImportSongs request received")
+	opts, body, err := importRequest(ctx, c.importLimits)
+	if respondUploadTooLarge(ctx, err, c.importLimits) {
+		return
+	}
+	if err != nil {
+		utils.RespondWithProblem(ctx, http.StatusBadRequest, "invalid_parameter", err.Error())
+		return
+	}
+
+	importer := services.NewImporter(c.repo, c.details)
+	result, err := importer.Import(ctx.Request.Context(), body, opts, auditMeta(ctx))
+	var maxBytesErr *http.MaxBytesError
+	if errors.As(err, &maxBytesErr) {
+		// The batches read before the limit was reached are stored.
+		status := http.StatusRequestEntityTooLarge
+		problem := utils.NewProblem(ctx.Request, status, "payload_too_large", uploadTooLargeMessage(c.importLimits))
+		utils.WriteProblem(ctx, status, importProblem{Problem: problem, Result: result})
+		return
+	}
+	if err != nil {
+		status, code, message := songError(err, "Failed to import songs")
+		requestLogger(ctx).Error(message+": ", err)
+		utils.WriteProblem(ctx, status, importProblem{Problem: utils.NewProblem(ctx.Request, status, code, message), Result: result})
+		return
+	}
+	ctx.JSON(http.StatusOK, result)
+}
+
+// importProblem reports a failed import together with the rows stored
+// before the failure.
+type importProblem struct {
+	utils.Problem
+	Result services.ImportResult `json:"result"`
+}
+
+// UploadLimits bound an uploaded import file: MaxSize in bytes, and Timeout
+// for the whole request.
+type UploadLimits struct {
+	MaxSize int64
+	Timeout time.Duration
+}
+
+// NewUploadLimits returns the limits of synchronous imports set in cfg.
+func NewUploadLimits(cfg config.Import) UploadLimits {
+	return UploadLimits{MaxSize: int64(cfg.MaxUploadSize), Timeout: cfg.Timeout}
+}
+
+// importRequest reads the import options from the query string and opens
+// the uploaded file, which is cut off at limits.MaxSize.
+func importRequest(ctx *gin.Context, limits UploadLimits) (services.ImportOptions, io.Reader, error) {
+	// Uploads are read as a stream, which may take longer than the server
+	// read timeout, but not longer than the import timeout.
+	server.ExtendDeadlines(ctx.Writer, limits.Timeout)
+	ctx.Request.Body = http.MaxBytesReader(ctx.Writer, ctx.Request.Body, limits.MaxSize)
+	opts, err := importOptions(ctx)
+	if err != nil {
+		return opts, nil, err
+	}
+	body, format, err := importBody(ctx)
+	if err != nil {
+		return opts, nil, err
+	}
+	if opts.Format == "" {
+		opts.Format = format
+	}
+	if opts.Format == "" {
+		return opts, nil, errors.New("unknown import format, pass format=csv or format=ndjson")
+	}
+	return opts, body, nil
+}
+
+func importOptions(ctx *gin.Context) (services.ImportOptions, error) {
+	var opts services.ImportOptions
+	switch format := ctx.Query("format"); format {
+	case "", services.FormatCSV, services.FormatNDJSON:
+		opts.Format = format
+	default:
+		return opts, errors.New("invalid format, expected csv or ndjson")
+	}
+	mapping, err := services.ParseColumnMapping(ctx.Query("map"))
+	if err != nil {
+		return opts, err
+	}
+	opts.Mapping = mapping
+	opts.Enrich, _ = strconv.ParseBool(ctx.Query("enrich"))
+	opts.DryRun, _ = strconv.ParseBool(ctx.Query("dry_run"))
+	return opts, nil
+}
+
+// importBody returns the uploaded file as a stream without buffering it,
+// together with the format implied by its content type.
+func importBody(ctx *gin.Context) (io.Reader, string, error) {
+	mediaType, _, _ := mime.ParseMediaType(ctx.GetHeader("Content-Type"))
+	if mediaType != "multipart/form-data" {
+		return ctx.Request.Body, formatForMediaType(mediaType), nil
+	}
+
+	reader, err := ctx.Request.MultipartReader()
+	if err != nil {
+		return nil, "", err
+	}
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			return nil, "", errors.New("multipart request has no file field")
+		}
+		if err != nil {
+			return nil, "", err
+		}
+		if part.FormName() == "file" {
+			partType, _, _ := mime.ParseMediaType(part.Header.Get("Content-Type"))
+			return part, formatForMediaType(partType), nil
+		}
+	}
+}
+
+func formatForMediaType(mediaType string) string {
+	switch mediaType {
+	case "text/csv":
+		return services.FormatCSV
+	case "application/x-ndjson", "application/ndjson", "application/jsonl", "application/x-jsonlines":
+		return services.FormatNDJSON
+	default:
+		return ""
+	}
+}
+
+// respondUploadTooLarge responds with 413 when err reports an upload over
+// the size limit.
+func respondUploadTooLarge(ctx *gin.Context, err error, limits UploadLimits) bool {
+	var maxBytesErr *http.MaxBytesError
+	if !errors.As(err, &maxBytesErr) {
+		return false
+	}
+	utils.RespondWithProblem(ctx, http.StatusRequestEntityTooLarge, "payload_too_large", uploadTooLargeMessage(limits))
+	return true
+}
+
+func uploadTooLargeMessage(limits UploadLimits) string {
+	return fmt.Sprintf("Import file must not exceed %d bytes", limits.MaxSize)
+}
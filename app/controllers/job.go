@@ -0,0 +1,229 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lmd1e/song_library/app/models"
+	"github.com/lmd1e/song_library/app/repositories"
+	"github.com/lmd1e/song_library/app/services"
+	"github.com/lmd1e/song_library/app/utils"
+)
+
+type JobController struct {
+	repo         repositories.JobRepository
+	storage      *services.JobStorage
+	uploadLimits UploadLimits
+}
+
+func NewJobController(repo repositories.JobRepository, storage *services.JobStorage, uploadLimits UploadLimits) *JobController {
+	return &JobController{repo: repo, storage: storage, uploadLimits: uploadLimits}
+}
+
+// @Summary Фоновый импорт песен
+// @Description Сохраняет файл CSV или NDJSON и ставит его импорт в очередь. Параметры те же, что у /api/v1/songs/import; ход выполнения доступен по GET /api/v1/jobs/{id}.
+// @Tags Jobs
+// @Accept text/csv
+// @Accept application/x-ndjson
+// @Accept multipart/form-data
+// @Produce json
+// @Param format query string false "Формат файла; по умолчанию определяется по Content-Type" Enums(csv, ndjson)
+// @Param map query string false "Соответствие полей колонкам файла, например group:artist,song:title"
+// @Param enrich query bool false "Дополнить недостающие поля данными внешнего API"
+// @Param dry_run query bool false "Только проверить файл, ничего не сохраняя"
+// @Param X-Actor header string false "Автор изменения для журнала аудита"
+// @Success 202 {object} models.Job
+// @Header 202 {string} Location "Адрес задачи"
+// @Failure 400 {object} utils.Problem
+// @Failure 413 {object} utils.Problem
+// @Failure 500 {object} utils.Problem
+// @Failure 504 {object} utils.Problem
+// @Router /api/v1/jobs/import [post]
+func (c *JobController) SubmitImportJob(ctx *gin.Context) {
+	requestLogger(ctx).Debug("SubmitImportJob request received")
+	opts, body, err := importRequest(ctx, c.uploadLimits)
+	if respondUploadTooLarge(ctx, err, c.uploadLimits) {
+		return
+	}
+	if err != nil {
+		utils.RespondWithProblem(ctx, http.StatusBadRequest, "invalid_parameter", err.Error())
+		return
+	}
+	path, err := c.storage.SaveUpload(body, opts.Format)
+	if respondUploadTooLarge(ctx, err, c.uploadLimits) {
+		return
+	}
+	if err != nil {
+		requestLogger(ctx).Error("Failed to save import file: ", err)
+		utils.RespondWithProblem(ctx, http.StatusInternalServerError, "internal_error", "Failed to save import file")
+		return
+	}
+	c.submit(ctx, models.Job{
+		Kind:      models.JobKindImport,
+		InputPath: path,
+		Params: models.JobParams{
+			Format:  opts.Format,
+			Mapping: opts.Mapping,
+			Enrich:  opts.Enrich,
+			DryRun:  opts.DryRun,
+		},
+	})
+}
+
+// @Summary Фоновая выгрузка библиотеки
+// @Description Ставит в очередь выгрузку песен с теми же параметрами, что у /api/v1/songs/export; готовый файл скачивается по GET /api/v1/jobs/{id}/artifact.
+// @Tags Jobs
+// @Produce json
+// @Param format query string false "Формат файла" Enums(csv, ndjson, json) default(ndjson)
+// @Param gzip query bool false "Сжать файл gzip"
+// @Param group query string false "Фильтр по группе"
+// @Param song query string false "Фильтр по названию песни"
+// @Param release_date query string false "Фильтр по дате выхода"
+// @Param text query string false "Фильтр по тексту"
+// @Param link query string false "Фильтр по ссылке"
+// @Success 202 {object} models.Job
+// @Header 202 {string} Location "Адрес задачи"
+// @Failure 400 {object} utils.Problem
+// @Failure 500 {object} utils.Problem
+// @Failure 504 {object} utils.Problem
+// @Router /api/v1/jobs/export [post]
+func (c *JobController) SubmitExportJob(ctx *gin.Context) {
+	requestLogger(ctx).Debug("SubmitExportJob request received")
+	params := models.JobParams{
+		Format: ctx.DefaultQuery("format", services.FormatNDJSON),
+		Filter: songFilter(ctx),
+	}
+	if _, ok := services.ExportContentTypes[params.Format]; !ok {
+		utils.RespondWithProblem(ctx, http.StatusBadRequest, "invalid_parameter", "Invalid format, expected csv, ndjson or json")
+		return
+	}
+	params.Gzip, _ = strconv.ParseBool(ctx.Query("gzip"))
+	c.submit(ctx, models.Job{Kind: models.JobKindExport, Params: params})
+}
+
+func (c *JobController) submit(ctx *gin.Context, job models.Job) {
+	meta := auditMeta(ctx)
+	job.Actor = meta.Actor
+	job.RequestID = meta.RequestID
+	job, err := c.repo.CreateJob(ctx.Request.Context(), job)
+	if err != nil {
+		if job.InputPath != "" {
+			os.Remove(job.InputPath)
+		}
+		respondJobError(ctx, err, "Failed to create job")
+		return
+	}
+	ctx.Header("Location", fmt.Sprintf("%s/jobs/%d", apiPrefix(ctx), job.ID))
+	ctx.JSON(http.StatusAccepted, withArtifactURL(ctx, job))
+}
+
+// @Summary Состояние задачи
+// @Description Статус фоновой задачи, число обработанных строк и ошибки
+// @Tags Jobs
+// @Produce json
+// @Param id path int true "ID задачи"
+// @Success 200 {object} models.Job
+// @Failure 404 {object} utils.Problem
+// @Failure 500 {object} utils.Problem
+// @Failure 504 {object} utils.Problem
+// @Router /api/v1/jobs/{id} [get]
+func (c *JobController) GetJob(ctx *gin.Context) {
+	requestLogger(ctx).Debug("GetJob request received")
+	jobID, _ := strconv.ParseInt(ctx.Param("id"), 10, 64)
+	job, err := c.repo.GetJob(ctx.Request.Context(), jobID)
+	if err != nil {
+		respondJobError(ctx, err, "Failed to fetch job")
+		return
+	}
+	ctx.JSON(http.StatusOK, withArtifactURL(ctx, job))
+}
+
+// @Summary Отмена задачи
+// @Description Задача в очереди отменяется сразу, выполняемая — при ближайшей проверке обработчиком
+// @Tags Jobs
+// @Produce json
+// @Param id path int true "ID задачи"
+// @Success 202 {object} models.Job
+// @Failure 404 {object} utils.Problem
+// @Failure 409 {object} utils.Problem
+// @Failure 500 {object} utils.Problem
+// @Failure 504 {object} utils.Problem
+// @Router /api/v1/jobs/{id}/cancel [post]
+func (c *JobController) CancelJob(ctx *gin.Context) {
+	requestLogger(ctx).Debug("CancelJob request received")
+	jobID, _ := strconv.ParseInt(ctx.Param("id"), 10, 64)
+	job, err := c.repo.CancelJob(ctx.Request.Context(), jobID)
+	if err != nil {
+		respondJobError(ctx, err, "Failed to cancel job")
+		return
+	}
+	if job.Status == models.JobStatusCancelled && job.InputPath != "" {
+		os.Remove(job.InputPath)
+	}
+	ctx.JSON(http.StatusAccepted, withArtifactURL(ctx, job))
+}
+
+// @Summary Результат задачи
+// @Description Скачивание файла, созданного завершённой задачей выгрузки
+// @Tags Jobs
+// @Produce application/octet-stream
+// @Param id path int true "ID задачи"
+// @Success 200 {file} file
+// @Failure 404 {object} utils.Problem
+// @Failure 409 {object} utils.Problem
+// @Failure 500 {object} utils.Problem
+// @Failure 504 {object} utils.Problem
+// @Router /api/v1/jobs/{id}/artifact [get]
+func (c *JobController) DownloadJobArtifact(ctx *gin.Context) {
+	requestLogger(ctx).Debug("DownloadJobArtifact request received")
+	jobID, _ := strconv.ParseInt(ctx.Param("id"), 10, 64)
+	job, err := c.repo.GetJob(ctx.Request.Context(), jobID)
+	if err != nil {
+		respondJobError(ctx, err, "Failed to fetch job")
+		return
+	}
+	if !job.Finished() {
+		utils.RespondWithProblem(ctx, http.StatusConflict, "job_not_finished", "Job has not finished yet")
+		return
+	}
+	if job.ArtifactPath == "" {
+		utils.RespondWithProblem(ctx, http.StatusNotFound, "artifact_not_found", "Job has no artifact")
+		return
+	}
+	if _, err := os.Stat(job.ArtifactPath); err != nil {
+		requestLogger(ctx).Error("Failed to open job artifact: ", err)
+		utils.RespondWithProblem(ctx, http.StatusNotFound, "artifact_not_found", "Job artifact is no longer available")
+		return
+	}
+	ctx.FileAttachment(job.ArtifactPath, job.ArtifactName)
+}
+
+func withArtifactURL(ctx *gin.Context, job models.Job) models.Job {
+	if job.ArtifactPath != "" {
+		job.ArtifactURL = fmt.Sprintf("%s/jobs/%d/artifact", apiPrefix(ctx), job.ID)
+	}
+	return job
+}
+
+// apiPrefix returns the part of the matched route before /jobs, such as
+// /api/v1, so that links stay within the API version the client called.
+func apiPrefix(ctx *gin.Context) string {
+	prefix, _, _ := strings.Cut(ctx.FullPath(), "/jobs/")
+	return prefix
+}
+
+func respondJobError(ctx *gin.Context, err error, message string) {
+	switch {
+	case errors.Is(err, repositories.ErrJobNotFound):
+		utils.RespondWithProblem(ctx, http.StatusNotFound, "job_not_found", "Job not found")
+	case errors.Is(err, repositories.ErrJobFinished):
+		utils.RespondWithProblem(ctx, http.StatusConflict, "job_finished", "Job has already finished")
+	default:
+		respondSongError(ctx, err, message)
+	}
+}
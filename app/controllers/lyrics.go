@@ -0,0 +1,150 @@
+package controllers
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lmd1e/song_library/app/models"
+	"github.com/lmd1e/song_library/app/requests"
+	"github.com/lmd1e/song_library/app/utils"
+)
+
+const (
+	// maxLyricsUpload bounds the size of an uploaded text or LRC file.
+	maxLyricsUpload = 1 << 20
+	// maxTextLength matches the limit on the text of a song request.
+	maxTextLength = 20000
+)
+
+// @Summary Загрузка текста песни
+// @Description Заменяет текст песни. Обычный текст (text/plain) удаляет синхронизацию, файл LRC (text/x-lrc) задаёт и текст, и время начала каждой строки.
+// @Tags Songs
+// @Accept plain
+// @Accept text/x-lrc
+// @Produce json
+// @Produce xml
+// @Produce text/csv
+// @Produce application/yaml
+// @Param id path int true "ID песни"
+// @Param text body string true "Текст песни или файл LRC"
+// @Param If-Match header string false "ETag изменяемой версии песни"
+// @Param X-Actor header string false "Автор изменения для журнала аудита"
+// @Success 200 {object} requests.SongResponse
+// @Header 200 {string} ETag "Тег версии песни"
+// @Failure 400 {object} utils.Problem
+// @Failure 404 {object} utils.Problem
+// @Failure 406 {object} utils.Problem
+// @Failure 412 {object} utils.Problem
+// @Failure 413 {object} utils.Problem
+// @Failure 415 {object} utils.Problem
+// @Failure 428 {object} utils.Problem
+// @Failure 500 {object} utils.Problem
+// @Failure 504 {object} utils.Problem
+// @Router /api/v1/songs/{id}/text [put]
+func (c *SongController) UpdateSongText(ctx *gin.Context) {
+	requestLogger(ctx).Debug("UpdateSongText request received")
+	songID, _ := strconv.Atoi(ctx.Param("id"))
+	version, ok := c.ifMatchVersion(ctx, songID)
+	if !ok {
+		return
+	}
+	patch, ok := lyricsPatch(ctx)
+	if !ok {
+		return
+	}
+	patch.Version = version
+	song, err := c.repo.PatchSong(ctx.Request.Context(), songID, patch, auditMeta(ctx))
+	if err != nil {
+		respondSongError(ctx, err, "Failed to update song text")
+		return
+	}
+	ctx.Header("ETag", songETag(ctx, song))
+	render(ctx, http.StatusOK, requests.NewSongResponse(song))
+}
+
+// lyricsPatch reads the uploaded text or LRC file into a patch setting the
+// text and timings of a song. On failure it responds and returns false.
+func lyricsPatch(ctx *gin.Context) (models.SongPatch, bool) {
+	mediaType, _, _ := mime.ParseMediaType(ctx.GetHeader("Content-Type"))
+	if mediaType != utils.MediaTypePlainText && mediaType != utils.MediaTypeLRC {
+		utils.RespondWithProblem(ctx, http.StatusUnsupportedMediaType, "unsupported_media_type",
+			"Content-Type must be "+utils.MediaTypePlainText+" or "+utils.MediaTypeLRC)
+		return models.SongPatch{}, false
+	}
+	body, err := io.ReadAll(http.MaxBytesReader(ctx.Writer, ctx.Request.Body, maxLyricsUpload))
+	if err != nil {
+		utils.RespondWithProblem(ctx, http.StatusRequestEntityTooLarge, "payload_too_large", "Text must not exceed 1 MiB")
+		return models.SongPatch{}, false
+	}
+
+	var lines []string
+	timings := models.LyricTimings{}
+	if mediaType == utils.MediaTypeLRC {
+		lines, timings, err = utils.ParseLRC(bytes.NewReader(body))
+		if err != nil {
+			utils.RespondWithProblem(ctx, http.StatusBadRequest, "invalid_lrc", "Invalid LRC file: "+err.Error())
+			return models.SongPatch{}, false
+		}
+		for i, line := range lines {
+			lines[i] = utils.NormalizeString(line)
+		}
+	} else {
+		lines = []string{utils.NormalizeString(strings.ReplaceAll(string(body), "\r\n", "\n"))}
+	}
+
+	text := strings.Join(lines, "\n")
+	if !utf8.ValidString(text) {
+		utils.RespondWithProblem(ctx, http.StatusBadRequest, "invalid_payload", "Invalid request payload",
+			utils.FieldError{Field: "text", Code: "encoding", Message: "must be UTF-8"})
+		return models.SongPatch{}, false
+	}
+	if utf8.RuneCountInString(text) > maxTextLength {
+		utils.RespondWithProblem(ctx, http.StatusBadRequest, "invalid_payload", "Invalid request payload",
+			utils.FieldError{Field: "text", Code: "max", Message: fmt.Sprintf("must be at most %d characters long", maxTextLength)})
+		return models.SongPatch{}, false
+	}
+	return models.SongPatch{Text: &text, Timings: &timings}, true
+}
+
+// @Summary Разделы текста песни
+// @Description Текст песни, разбитый на разделы (куплет, припев, бридж и т. д.) по заголовкам вида [Chorus], [Verse 2] или «Chorus:». Текст до первого заголовка делится на куплеты по пустым строкам. Повтор раздела без строк получает строки предыдущего раздела с той же меткой.
+// @Tags Songs
+// @Produce json
+// @Produce xml
+// @Produce text/csv
+// @Produce application/yaml
+// @Param id path int true "ID песни"
+// @Param type query string false "Тип раздела" Enums(intro, verse, pre-chorus, chorus, post-chorus, bridge, interlude, outro, other)
+// @Param If-None-Match header string false "ETag ранее полученного ответа"
+// @Success 200 {object} requests.SongSectionsResponse
+// @Header 200 {string} ETag "Тег версии ответа"
+// @Failure 400 {object} utils.Problem
+// @Failure 404 {object} utils.Problem
+// @Failure 406 {object} utils.Problem
+// @Failure 500 {object} utils.Problem
+// @Failure 504 {object} utils.Problem
+// @Router /api/v1/songs/{id}/sections [get]
+func (c *SongController) GetSongSections(ctx *gin.Context) {
+	requestLogger(ctx).Debug("GetSongSections request received")
+	songID, _ := strconv.Atoi(ctx.Param("id"))
+	sectionType := strings.ToLower(ctx.Query("type"))
+	if sectionType != "" && !slices.Contains(models.SectionTypes, sectionType) {
+		utils.RespondWithProblem(ctx, http.StatusBadRequest, "invalid_parameter",
+			"Invalid type, expected one of "+strings.Join(models.SectionTypes, ", "))
+		return
+	}
+	structure, err := c.repo.GetSongSections(ctx.Request.Context(), songID)
+	if err != nil {
+		respondSongError(ctx, err, "Failed to fetch song sections")
+		return
+	}
+	respondWithETag(ctx, http.StatusOK, requests.NewSongSectionsResponse(songID, structure, sectionType))
+}
@@ -0,0 +1,15 @@
+package controllers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lmd1e/song_library/app/utils"
+)
+
+// respondInvalidPayload reports a request body that could not be bound,
+// listing the offending fields when they are known.
+func respondInvalidPayload(ctx *gin.Context, err error) {
+	requestLogger(ctx).Warn("Invalid request payload: ", err)
+	utils.RespondWithProblem(ctx, http.StatusBadRequest, "invalid_payload", "Invalid request payload", fieldErrors(err)...)
+}
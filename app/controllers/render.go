@@ -0,0 +1,30 @@
+package controllers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lmd1e/song_library/app/utils"
+)
+
+// render writes payload in the media type negotiated for the request, JSON
+// unless the route negotiates another.
+func render(ctx *gin.Context, code int, payload any) {
+	body, contentType, ok := renderBody(ctx, payload)
+	if !ok {
+		return
+	}
+	ctx.Data(code, contentType, body)
+}
+
+// renderBody encodes payload in the negotiated media type. On failure it
+// responds with 500 and returns false.
+func renderBody(ctx *gin.Context, payload any) ([]byte, string, bool) {
+	body, contentType, err := utils.Render(utils.MediaTypeFromContext(ctx.Request.Context()), payload)
+	if err != nil {
+		requestLogger(ctx).Error("Failed to encode response: ", err)
+		utils.RespondWithProblem(ctx, http.StatusInternalServerError, "internal_error", "Failed to encode response")
+		return nil, "", false
+	}
+	return body, contentType, true
+}
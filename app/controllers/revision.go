@@ -0,0 +1,144 @@
+package controllers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lmd1e/song_library/app/models"
+	"github.com/lmd1e/song_library/app/requests"
+	"github.com/lmd1e/song_library/app/utils"
+)
+
+// @Summary История изменений песни
+// @Description Получение списка ревизий песни, начиная с последней
+// @Tags Revisions
+// @Accept json
+// @Produce json
+// @Param id path int true "ID песни"
+// @Param limit query int false "Количество ревизий на странице"
+// @Param offset query int false "Смещение (страница)"
+// @Success 200 {array} models.SongRevision
+// @Failure 500 {object} utils.Problem
+// @Failure 504 {object} utils.Problem
+// @Router /api/v1/songs/{id}/revisions [get]
+func (c *SongController) GetSongRevisions(ctx *gin.Context) {
+	requestLogger(ctx).Debug("GetSongRevisions request received")
+	songID, _ := strconv.Atoi(ctx.Param("id"))
+	limit, _ := strconv.Atoi(ctx.DefaultQuery("limit", "10"))
+	offset, _ := strconv.Atoi(ctx.DefaultQuery("offset", "0"))
+	revisions, err := c.repo.GetSongRevisions(ctx.Request.Context(), songID, limit, offset)
+	if err != nil {
+		respondSongError(ctx, err, "Failed to fetch song revisions")
+		return
+	}
+	ctx.JSON(http.StatusOK, revisions)
+}
+
+// @Summary Ревизия песни
+// @Description Получение конкретной ревизии песни
+// @Tags Revisions
+// @Accept json
+// @Produce json
+// @Param id path int true "ID песни"
+// @Param rev path int true "Номер ревизии"
+// @Success 200 {object} models.SongRevision
+// @Failure 404 {object} utils.Problem
+// @Failure 500 {object} utils.Problem
+// @Failure 504 {object} utils.Problem
+// @Router /api/v1/songs/{id}/revisions/{rev} [get]
+func (c *SongController) GetSongRevision(ctx *gin.Context) {
+	requestLogger(ctx).Debug("GetSongRevision request received")
+	songID, _ := strconv.Atoi(ctx.Param("id"))
+	revision, _ := strconv.Atoi(ctx.Param("rev"))
+	rev, err := c.repo.GetSongRevision(ctx.Request.Context(), songID, revision)
+	if err != nil {
+		respondSongError(ctx, err, "Failed to fetch song revision")
+		return
+	}
+	ctx.JSON(http.StatusOK, rev)
+}
+
+// @Summary Сравнение ревизий песни
+// @Description Построчное или покуплетное сравнение текста двух ревизий песни
+// @Tags Revisions
+// @Accept json
+// @Produce json
+// @Param id path int true "ID песни"
+// @Param from query int true "Исходная ревизия"
+// @Param to query int true "Конечная ревизия"
+// @Param granularity query string false "Единица сравнения" Enums(line, verse)
+// @Success 200 {object} models.RevisionDiff
+// @Failure 400 {object} utils.Problem
+// @Failure 404 {object} utils.Problem
+// @Failure 500 {object} utils.Problem
+// @Failure 504 {object} utils.Problem
+// @Router /api/v1/songs/{id}/revisions/diff [get]
+func (c *SongController) DiffSongRevisions(ctx *gin.Context) {
+	requestLogger(ctx).Debug("DiffSongRevisions request received")
+	songID, _ := strconv.Atoi(ctx.Param("id"))
+	from, fromErr := strconv.Atoi(ctx.Query("from"))
+	to, toErr := strconv.Atoi(ctx.Query("to"))
+	if fromErr != nil || toErr != nil {
+		utils.RespondWithProblem(ctx, http.StatusBadRequest, "invalid_parameter", "Both from and to revisions are required")
+		return
+	}
+	granularity := ctx.DefaultQuery("granularity", "line")
+	split := utils.SplitLines
+	switch granularity {
+	case "line":
+	case "verse":
+		split = utils.SplitVerses
+	default:
+		utils.RespondWithProblem(ctx, http.StatusBadRequest, "invalid_parameter", "Invalid granularity, expected line or verse")
+		return
+	}
+
+	fromRev, err := c.repo.GetSongRevision(ctx.Request.Context(), songID, from)
+	if err != nil {
+		respondSongError(ctx, err, "Failed to fetch song revision")
+		return
+	}
+	toRev, err := c.repo.GetSongRevision(ctx.Request.Context(), songID, to)
+	if err != nil {
+		respondSongError(ctx, err, "Failed to fetch song revision")
+		return
+	}
+
+	ctx.JSON(http.StatusOK, models.RevisionDiff{
+		SongID:      songID,
+		From:        from,
+		To:          to,
+		Granularity: granularity,
+		Changes:     utils.Diff(split(fromRev.Text), split(toRev.Text)),
+	})
+}
+
+// @Summary Восстановление ревизии песни
+// @Description Откат данных песни к указанной ревизии; откат сохраняется как новая ревизия
+// @Tags Revisions
+// @Accept json
+// @Produce json
+// @Produce xml
+// @Produce text/csv
+// @Produce application/yaml
+// @Param id path int true "ID песни"
+// @Param rev path int true "Номер ревизии"
+// @Param X-Actor header string false "Автор изменения для журнала аудита"
+// @Success 200 {object} requests.SongResponse
+// @Failure 404 {object} utils.Problem
+// @Failure 406 {object} utils.Problem
+// @Failure 500 {object} utils.Problem
+// @Failure 504 {object} utils.Problem
+// @Router /api/v1/songs/{id}/revisions/{rev}/restore [post]
+func (c *SongController) RestoreSongRevision(ctx *gin.Context) {
+	requestLogger(ctx).Debug("RestoreSongRevision request received")
+	songID, _ := strconv.Atoi(ctx.Param("id"))
+	revision, _ := strconv.Atoi(ctx.Param("rev"))
+	song, err := c.repo.RestoreSongRevision(ctx.Request.Context(), songID, revision, auditMeta(ctx))
+	if err != nil {
+		respondSongError(ctx, err, "Failed to restore song revision")
+		return
+	}
+	render(ctx, http.StatusOK, requests.NewSongResponse(song))
+}
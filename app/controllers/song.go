@@ -1,23 +1,43 @@
 package controllers
 
 import (
+	"context"
+	"errors"
 	"net/http"
 	"strconv"
 
 	"github.com/gin-gonic/gin"
+	"github.com/lmd1e/song_library/app/config"
 	_ "github.com/lmd1e/song_library/app/docs"
-	"github.com/lmd1e/song_library/app/models"
 	"github.com/lmd1e/song_library/app/repositories"
 	"github.com/lmd1e/song_library/app/requests"
 	"github.com/lmd1e/song_library/app/utils"
+	"github.com/sirupsen/logrus"
 )
 
 type SongController struct {
-	repo repositories.SongRepository
+	repo         repositories.SongRepository
+	details      requests.SongDetailClient
+	importLimits UploadLimits
+	// requireIfMatch makes batch operations that change a song carry its
+	// version, as RequireIfMatch does for If-Match.
+	requireIfMatch bool
 }
 
-func NewSongController(repo repositories.SongRepository) *SongController {
-	return &SongController{repo: repo}
+// NewSongController returns a controller with the default import limits.
+func NewSongController(repo repositories.SongRepository, details requests.SongDetailClient) *SongController {
+	return &SongController{repo: repo, details: details, importLimits: NewUploadLimits(config.Default().Import)}
+}
+
+// SetRequireIfMatch makes batch updates, patches and deletes without a
+// version fail with 428 Precondition Required.
+func (c *SongController) SetRequireIfMatch(required bool) {
+	c.requireIfMatch = required
+}
+
+// SetImportLimits changes the limits of synchronous imports.
+func (c *SongController) SetImportLimits(limits UploadLimits) {
+	c.importLimits = limits
 }
 
 // @Summary Получение данных библиотеки с фильтрацией и пагинацией
@@ -25,70 +45,133 @@ func NewSongController(repo repositories.SongRepository) *SongController {
 // @Tags Songs
 // @Accept json
 // @Produce json
+// @Produce xml
+// @Produce text/csv
+// @Produce application/yaml
 // @Param group query string false "Фильтр по группе"
 // @Param song query string false "Фильтр по названию песни"
 // @Param limit query int false "Количество записей на странице"
 // @Param offset query int false "Смещение (страница)"
-// @Success 200 {array} models.Song
-// @Failure 500 {object} map[string]string
-// @Router /songs [get]
+// @Param If-None-Match header string false "ETag ранее полученного ответа"
+// @Success 200 {array} requests.SongResponse
+// @Header 200 {string} ETag "Тег версии ответа"
+// @Failure 406 {object} utils.Problem
+// @Failure 500 {object} utils.Problem
+// @Failure 504 {object} utils.Problem
+// @Router /api/v1/songs [get]
 func (c *SongController) GetSongs(ctx *gin.Context) {
-	utils.Logger.Info("GetSongs request received")
-	filter := make(map[string]string)
-	for key, value := range ctx.Request.URL.Query() {
-		filter[key] = value[0]
-	}
+	requestLogger(ctx).Debug("GetSongs request received")
+	filter := songFilter(ctx)
 	limit, _ := strconv.Atoi(ctx.DefaultQuery("limit", "10"))
 	offset, _ := strconv.Atoi(ctx.DefaultQuery("offset", "0"))
-	songs, err := c.repo.GetSongs(filter, limit, offset)
+	songs, err := c.repo.GetSongs(ctx.Request.Context(), filter, limit, offset)
+	if err != nil {
+		respondSongError(ctx, err, "Failed to fetch songs")
+		return
+	}
+	respondWithETag(ctx, http.StatusOK, requests.NewSongResponses(songs))
+}
+
+// @Summary Получение песни
+// @Description Получение данных песни по ID
+// @Tags Songs
+// @Accept json
+// @Produce json
+// @Produce xml
+// @Produce text/csv
+// @Produce application/yaml
+// @Param id path int true "ID песни"
+// @Param If-None-Match header string false "ETag ранее полученной версии песни"
+// @Success 200 {object} requests.SongResponse
+// @Header 200 {string} ETag "Тег версии песни"
+// @Success 304 "Песня не изменилась"
+// @Failure 404 {object} utils.Problem
+// @Failure 406 {object} utils.Problem
+// @Failure 500 {object} utils.Problem
+// @Failure 504 {object} utils.Problem
+// @Router /api/v1/songs/{id} [get]
+func (c *SongController) GetSong(ctx *gin.Context) {
+	requestLogger(ctx).Debug("GetSong request received")
+	songID, _ := strconv.Atoi(ctx.Param("id"))
+	song, err := c.repo.GetSong(ctx.Request.Context(), songID)
 	if err != nil {
-		utils.Logger.Error("Failed to fetch songs: ", err)
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch songs"})
+		respondSongError(ctx, err, "Failed to fetch song")
+		return
+	}
+	etag := songETag(ctx, song)
+	ctx.Header("ETag", etag)
+	if notModified(ctx, etag) {
 		return
 	}
-	ctx.JSON(http.StatusOK, songs)
+	render(ctx, http.StatusOK, requests.NewSongResponse(song))
 }
 
 // @Summary Получение текста песни с пагинацией по куплетам
-// @Description Получение текста песни с пагинацией по куплетам
+// @Description Получение текста песни с пагинацией по куплетам. Синхронизированный текст также отдаётся в форматах LRC (text/x-lrc) и WebVTT (text/vtt) с временем начала каждой строки.
 // @Tags Songs
 // @Accept json
 // @Produce json
+// @Produce xml
+// @Produce text/csv
+// @Produce application/yaml
+// @Produce plain
+// @Produce text/x-lrc
+// @Produce text/vtt
 // @Param id path int true "ID песни"
 // @Param limit query int false "Количество куплетов на странице"
 // @Param offset query int false "Смещение (страница)"
-// @Success 200 {object} map[string]string
-// @Failure 500 {object} map[string]string
-// @Router /songs/{id}/text [get]
+// @Param If-None-Match header string false "ETag ранее полученного ответа"
+// @Success 200 {object} requests.SongTextResponse
+// @Header 200 {string} ETag "Тег версии ответа"
+// @Failure 404 {object} utils.Problem
+// @Failure 406 {object} utils.Problem
+// @Failure 500 {object} utils.Problem
+// @Failure 504 {object} utils.Problem
+// @Router /api/v1/songs/{id}/text [get]
 func (c *SongController) GetSongText(ctx *gin.Context) {
-	utils.Logger.Info("GetSongText request received")
+	requestLogger(ctx).Debug("GetSongText request received")
 	songID, _ := strconv.Atoi(ctx.Param("id"))
 	limit, _ := strconv.Atoi(ctx.DefaultQuery("limit", "10"))
 	offset, _ := strconv.Atoi(ctx.DefaultQuery("offset", "0"))
-	text, err := c.repo.GetSongText(songID, limit, offset)
+	lyrics, err := c.repo.GetSongText(ctx.Request.Context(), songID, limit, offset)
 	if err != nil {
-		utils.Logger.Error("Failed to fetch song text: ", err)
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch song text"})
+		respondSongError(ctx, err, "Failed to fetch song text")
 		return
 	}
-	ctx.JSON(http.StatusOK, gin.H{"text": text})
+	switch utils.MediaTypeFromContext(ctx.Request.Context()) {
+	case utils.MediaTypeLRC, utils.MediaTypeWebVTT:
+		if !lyrics.Synced() {
+			utils.RespondWithProblem(ctx, http.StatusNotFound, "lyrics_not_synced", "Song has no synced lyrics")
+			return
+		}
+	}
+	respondWithETag(ctx, http.StatusOK, requests.NewSongTextResponse(lyrics))
 }
 
 // @Summary Удаление песни
-// @Description Удаление песни по ID
+// @Description Перемещение песни в корзину по ID
 // @Tags Songs
 // @Accept json
 // @Produce json
 // @Param id path int true "ID песни"
+// @Param If-Match header string false "ETag удаляемой версии песни"
+// @Param X-Actor header string false "Автор изменения для журнала аудита"
 // @Success 200 {object} map[string]string
-// @Failure 500 {object} map[string]string
-// @Router /songs/{id} [delete]
+// @Failure 404 {object} utils.Problem
+// @Failure 412 {object} utils.Problem
+// @Failure 428 {object} utils.Problem
+// @Failure 500 {object} utils.Problem
+// @Failure 504 {object} utils.Problem
+// @Router /api/v1/songs/{id} [delete]
 func (c *SongController) DeleteSong(ctx *gin.Context) {
-	utils.Logger.Info("DeleteSong request received")
+	requestLogger(ctx).Debug("DeleteSong request received")
 	songID, _ := strconv.Atoi(ctx.Param("id"))
-	if err := c.repo.DeleteSong(songID); err != nil {
-		utils.Logger.Error("Failed to delete song: ", err)
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete song"})
+	version, ok := c.ifMatchVersion(ctx, songID)
+	if !ok {
+		return
+	}
+	if err := c.repo.DeleteSong(ctx.Request.Context(), songID, version, auditMeta(ctx)); err != nil {
+		respondSongError(ctx, err, "Failed to delete song")
 		return
 	}
 	ctx.JSON(http.StatusOK, gin.H{"message": "Song deleted"})
@@ -99,28 +182,85 @@ func (c *SongController) DeleteSong(ctx *gin.Context) {
 // @Tags Songs
 // @Accept json
 // @Produce json
+// @Produce xml
+// @Produce text/csv
+// @Produce application/yaml
 // @Param id path int true "ID песни"
-// @Param song body models.Song true "Данные песни"
-// @Success 200 {object} models.Song
-// @Failure 400 {object} map[string]string
-// @Failure 500 {object} map[string]string
-// @Router /songs/{id} [put]
+// @Param song body requests.SongRequest true "Данные песни"
+// @Param If-Match header string false "ETag изменяемой версии песни"
+// @Param X-Actor header string false "Автор изменения для журнала аудита"
+// @Success 200 {object} requests.SongResponse
+// @Header 200 {string} ETag "Тег версии песни"
+// @Failure 400 {object} utils.Problem
+// @Failure 404 {object} utils.Problem
+// @Failure 412 {object} utils.Problem
+// @Failure 428 {object} utils.Problem
+// @Failure 406 {object} utils.Problem
+// @Failure 500 {object} utils.Problem
+// @Failure 504 {object} utils.Problem
+// @Router /api/v1/songs/{id} [put]
 func (c *SongController) UpdateSong(ctx *gin.Context) {
-	utils.Logger.Info("UpdateSong request received")
+	requestLogger(ctx).Debug("UpdateSong request received")
 	songID, _ := strconv.Atoi(ctx.Param("id"))
-	var song models.Song
-	if err := ctx.ShouldBindJSON(&song); err != nil {
-		utils.Logger.Error("Invalid request payload: ", err)
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload"})
+	version, ok := c.ifMatchVersion(ctx, songID)
+	if !ok {
+		return
+	}
+	var req requests.SongRequest
+	if err := bindJSON(ctx, &req); err != nil {
+		respondInvalidPayload(ctx, err)
 		return
 	}
-	song.ID = songID
-	if err := c.repo.UpdateSong(song); err != nil {
-		utils.Logger.Error("Failed to update song: ", err)
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update song"})
+	song, err := c.repo.UpdateSong(ctx.Request.Context(), req.ToSong(songID, version), auditMeta(ctx))
+	if err != nil {
+		respondSongError(ctx, err, "Failed to update song")
 		return
 	}
-	ctx.JSON(http.StatusOK, song)
+	ctx.Header("ETag", songETag(ctx, song))
+	render(ctx, http.StatusOK, requests.NewSongResponse(song))
+}
+
+// @Summary Частичное изменение данных песни
+// @Description Изменение переданных полей песни по ID
+// @Tags Songs
+// @Accept json
+// @Produce json
+// @Produce xml
+// @Produce text/csv
+// @Produce application/yaml
+// @Param id path int true "ID песни"
+// @Param song body requests.PatchSongRequest true "Изменяемые поля песни"
+// @Param If-Match header string false "ETag изменяемой версии песни"
+// @Param X-Actor header string false "Автор изменения для журнала аудита"
+// @Success 200 {object} requests.SongResponse
+// @Header 200 {string} ETag "Тег версии песни"
+// @Failure 400 {object} utils.Problem
+// @Failure 404 {object} utils.Problem
+// @Failure 412 {object} utils.Problem
+// @Failure 428 {object} utils.Problem
+// @Failure 406 {object} utils.Problem
+// @Failure 500 {object} utils.Problem
+// @Failure 504 {object} utils.Problem
+// @Router /api/v1/songs/{id} [patch]
+func (c *SongController) PatchSong(ctx *gin.Context) {
+	requestLogger(ctx).Debug("PatchSong request received")
+	songID, _ := strconv.Atoi(ctx.Param("id"))
+	version, ok := c.ifMatchVersion(ctx, songID)
+	if !ok {
+		return
+	}
+	var req requests.PatchSongRequest
+	if err := bindJSON(ctx, &req); err != nil {
+		respondInvalidPayload(ctx, err)
+		return
+	}
+	song, err := c.repo.PatchSong(ctx.Request.Context(), songID, req.ToPatch(version), auditMeta(ctx))
+	if err != nil {
+		respondSongError(ctx, err, "Failed to update song")
+		return
+	}
+	ctx.Header("ETag", songETag(ctx, song))
+	render(ctx, http.StatusOK, requests.NewSongResponse(song))
 }
 
 // @Summary Добавление новой песни
@@ -128,40 +268,89 @@ func (c *SongController) UpdateSong(ctx *gin.Context) {
 // @Tags Songs
 // @Accept json
 // @Produce json
+// @Produce xml
+// @Produce text/csv
+// @Produce application/yaml
 // @Param song body requests.AddSongRequest true "Данные песни"
-// @Success 201 {object} models.Song
-// @Failure 400 {object} map[string]string
-// @Failure 500 {object} map[string]string
-// @Router /songs [post]
+// @Param X-Actor header string false "Автор изменения для журнала аудита"
+// @Success 201 {object} requests.SongResponse
+// @Header 201 {string} ETag "Тег версии песни"
+// @Failure 400 {object} utils.Problem
+// @Failure 406 {object} utils.Problem
+// @Failure 500 {object} utils.Problem
+// @Failure 504 {object} utils.Problem
+// @Router /api/v1/songs [post]
 func (c *SongController) AddSong(ctx *gin.Context) {
-	utils.Logger.Info("AddSong request received")
+	requestLogger(ctx).Debug("AddSong request received")
 	var req requests.AddSongRequest
-	if err := ctx.ShouldBindJSON(&req); err != nil {
-		utils.Logger.Error("Invalid request payload: ", err)
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload"})
+	if err := bindJSON(ctx, &req); err != nil {
+		respondInvalidPayload(ctx, err)
 		return
 	}
 
-	songDetail, err := requests.GetSongDetail(req.Group, req.Song)
+	songDetail, err := c.details.GetSongDetail(ctx.Request.Context(), req.Group, req.Song)
 	if err != nil {
-		utils.Logger.Error("Failed to fetch song details: ", err)
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch song details"})
+		requestLogger(ctx).Error("Failed to fetch song details: ", err)
+		utils.RespondWithProblem(ctx, http.StatusInternalServerError, "external_api_error", "Failed to fetch song details")
 		return
 	}
 
-	song := models.Song{
+	// Details from the external API must pass the same rules as a song
+	// body before they are stored.
+	songReq := requests.SongRequest{
 		Group:       req.Group,
 		Song:        req.Song,
 		ReleaseDate: songDetail.ReleaseDate,
 		Text:        songDetail.Text,
 		Link:        songDetail.Link,
 	}
+	if err := requests.Validate(&songReq); err != nil {
+		requestLogger(ctx).Error("Invalid song details: ", err)
+		utils.RespondWithProblem(ctx, http.StatusInternalServerError, "external_api_error", "External API returned invalid song details", fieldErrors(err)...)
+		return
+	}
 
-	if err := c.repo.AddSong(song); err != nil {
-		utils.Logger.Error("Failed to add song: ", err)
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to add song"})
+	song, err := c.repo.AddSong(ctx.Request.Context(), songReq.ToSong(0, 0), auditMeta(ctx))
+	if err != nil {
+		respondSongError(ctx, err, "Failed to add song")
 		return
 	}
 
-	ctx.JSON(http.StatusCreated, song)
+	ctx.Header("ETag", songETag(ctx, song))
+	render(ctx, http.StatusCreated, requests.NewSongResponse(song))
+}
+
+func respondSongError(ctx *gin.Context, err error, message string) {
+	status, code, message := songError(err, message)
+	logSongError(ctx.Request.Context(), status, message, err)
+	utils.RespondWithProblem(ctx, status, code, message)
+}
+
+// songError maps a repository error to a response status, problem code and
+// message. Unexpected errors map to 500 with the given message.
+func songError(err error, message string) (int, string, string) {
+	switch {
+	case errors.Is(err, repositories.ErrSongNotFound):
+		return http.StatusNotFound, "song_not_found", "Song not found"
+	case errors.Is(err, repositories.ErrRevisionNotFound):
+		return http.StatusNotFound, "revision_not_found", "Revision not found"
+	case errors.Is(err, repositories.ErrVersionConflict):
+		return http.StatusPreconditionFailed, "version_conflict", "Song version does not match If-Match"
+	case errors.Is(err, repositories.ErrQueryTimeout):
+		return http.StatusGatewayTimeout, "query_timeout", "Database query timed out"
+	default:
+		return http.StatusInternalServerError, "internal_error", message
+	}
+}
+
+// logSongError logs the errors that are not the client's fault.
+func logSongError(ctx context.Context, status int, message string, err error) {
+	if status >= http.StatusInternalServerError {
+		utils.LoggerFromContext(ctx).Error(message+": ", err)
+	}
+}
+
+// requestLogger returns the logger of the request, which carries its id.
+func requestLogger(ctx *gin.Context) *logrus.Entry {
+	return utils.LoggerFromContext(ctx.Request.Context())
 }
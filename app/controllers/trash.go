@@ -0,0 +1,70 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lmd1e/song_library/app/repositories"
+	"github.com/lmd1e/song_library/app/requests"
+	"github.com/lmd1e/song_library/app/utils"
+)
+
+// @Summary Корзина
+// @Description Получение удалённых песен, начиная с последних удалённых
+// @Tags Trash
+// @Accept json
+// @Produce json
+// @Produce xml
+// @Produce text/csv
+// @Produce application/yaml
+// @Param limit query int false "Количество записей на странице"
+// @Param offset query int false "Смещение (страница)"
+// @Success 200 {array} requests.SongResponse
+// @Failure 406 {object} utils.Problem
+// @Failure 500 {object} utils.Problem
+// @Failure 504 {object} utils.Problem
+// @Router /api/v1/songs/trash [get]
+func (c *SongController) GetDeletedSongs(ctx *gin.Context) {
+	requestLogger(ctx).Debug("GetDeletedSongs request received")
+	limit, _ := strconv.Atoi(ctx.DefaultQuery("limit", "10"))
+	offset, _ := strconv.Atoi(ctx.DefaultQuery("offset", "0"))
+	songs, err := c.repo.GetDeletedSongs(ctx.Request.Context(), limit, offset)
+	if err != nil {
+		respondSongError(ctx, err, "Failed to fetch deleted songs")
+		return
+	}
+	render(ctx, http.StatusOK, requests.NewSongResponses(songs))
+}
+
+// @Summary Восстановление песни из корзины
+// @Description Восстановление удалённой песни по ID
+// @Tags Trash
+// @Accept json
+// @Produce json
+// @Produce xml
+// @Produce text/csv
+// @Produce application/yaml
+// @Param id path int true "ID песни"
+// @Param X-Actor header string false "Автор изменения для журнала аудита"
+// @Success 200 {object} requests.SongResponse
+// @Failure 404 {object} utils.Problem
+// @Failure 406 {object} utils.Problem
+// @Failure 500 {object} utils.Problem
+// @Failure 504 {object} utils.Problem
+// @Router /api/v1/songs/{id}/restore [post]
+func (c *SongController) RestoreSong(ctx *gin.Context) {
+	requestLogger(ctx).Debug("RestoreSong request received")
+	songID, _ := strconv.Atoi(ctx.Param("id"))
+	song, err := c.repo.RestoreSong(ctx.Request.Context(), songID, auditMeta(ctx))
+	if errors.Is(err, repositories.ErrSongNotFound) {
+		utils.RespondWithProblem(ctx, http.StatusNotFound, "song_not_found", "Song not found in trash")
+		return
+	}
+	if err != nil {
+		respondSongError(ctx, err, "Failed to restore song")
+		return
+	}
+	render(ctx, http.StatusOK, requests.NewSongResponse(song))
+}
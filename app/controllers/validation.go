@@ -0,0 +1,33 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lmd1e/song_library/app/requests"
+	"github.com/lmd1e/song_library/app/utils"
+)
+
+// bindJSON decodes the request body into obj and validates it with
+// requests.Validate, which normalises its strings first.
+func bindJSON(ctx *gin.Context, obj any) error {
+	if ctx.Request.Body == nil {
+		return errors.New("request body is empty")
+	}
+	if err := json.NewDecoder(ctx.Request.Body).Decode(obj); err != nil {
+		return err
+	}
+	return requests.Validate(obj)
+}
+
+func fieldErrors(err error) []utils.FieldError {
+	if fields := requests.FieldErrors(err); fields != nil {
+		return fields
+	}
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &typeErr) && typeErr.Field != "" {
+		return []utils.FieldError{{Field: typeErr.Field, Code: "type", Message: "must be a " + typeErr.Type.String()}}
+	}
+	return nil
+}
@@ -0,0 +1,102 @@
+// Package database opens the connection pool of the service.
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/XSAM/otelsql"
+	"github.com/lmd1e/song_library/app/config"
+	"github.com/lmd1e/song_library/app/utils"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+)
+
+const (
+	initialConnectBackoff = 250 * time.Millisecond
+	maxConnectBackoff     = 5 * time.Second
+)
+
+// Pinger checks that the database can be reached, as *sql.DB does.
+type Pinger interface {
+	PingContext(ctx context.Context) error
+}
+
+// Open opens a Postgres connection pool sized by cfg whose statements are
+// traced: each query and exec becomes a span carrying its SQL, a child of
+// the repository call that ran it. No connection is made until the pool is
+// first used; see Connect.
+func Open(cfg config.Database) (*sql.DB, error) {
+	db, err := otelsql.Open("postgres", cfg.URL,
+		otelsql.WithAttributes(semconv.DBSystemPostgreSQL),
+		otelsql.WithSpanOptions(otelsql.SpanOptions{
+			DisableErrSkip:       true,
+			OmitConnResetSession: true,
+			OmitConnPrepare:      true,
+			OmitRows:             true,
+		}),
+	)
+	if err != nil {
+		return nil, err
+	}
+	db.SetMaxOpenConns(cfg.MaxOpenConns)
+	db.SetMaxIdleConns(cfg.MaxIdleConns)
+	db.SetConnMaxLifetime(cfg.ConnMaxLifetime)
+	db.SetConnMaxIdleTime(cfg.ConnMaxIdleTime)
+	return db, nil
+}
+
+// Connect pings db until it answers, waiting between attempts with an
+// exponential backoff, and gives up once timeout has passed. It lets the
+// service start together with the database rather than fail while Postgres
+// is still starting.
+func Connect(ctx context.Context, db Pinger, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	backoff := initialConnectBackoff
+	for attempt := 1; ; attempt++ {
+		err := db.PingContext(ctx)
+		if err == nil {
+			if attempt > 1 {
+				utils.Logger.Infof("Connected to the database after %d attempts", attempt)
+			}
+			return nil
+		}
+		utils.Logger.Warnf("Database is not reachable (attempt %d), retrying in %s: %v", attempt, backoff, err)
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("database not reachable within %s: %w", timeout, err)
+		case <-time.After(backoff):
+		}
+		backoff = min(2*backoff, maxConnectBackoff)
+	}
+}
+
+// Monitor pings db every interval until ctx is cancelled, logging when the
+// connection is lost and when it comes back.
+func Monitor(ctx context.Context, db Pinger, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	var lostAt time.Time
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		pingCtx, cancel := context.WithTimeout(ctx, interval)
+		err := db.PingContext(pingCtx)
+		cancel()
+		switch {
+		case ctx.Err() != nil:
+			return
+		case err != nil && lostAt.IsZero():
+			lostAt = time.Now()
+			utils.Logger.Error("Lost connection to the database: ", err)
+		case err == nil && !lostAt.IsZero():
+			utils.Logger.Infof("Database connection restored after %s", time.Since(lostAt).Round(time.Second))
+			lostAt = time.Time{}
+		}
+	}
+}
@@ -1,14 +1,18 @@
 package migrations
 
 import (
+	"context"
 	"database/sql"
+	"fmt"
 
 	"github.com/lmd1e/song_library/app/utils"
 )
 
-func RunMigrations(db *sql.DB) error {
-	utils.Logger.Info("Running database migrations")
-	_, err := db.Exec(`
+// migrations are applied in order and never edited once released; a schema
+// change is a new entry at the end. They are written to be safe to rerun, as
+// databases created before schema_migrations existed run them all once more.
+var migrations = []string{
+	`
         CREATE TABLE IF NOT EXISTS songs (
             id SERIAL PRIMARY KEY,
             "group" VARCHAR(255) NOT NULL,
@@ -17,12 +21,161 @@ func RunMigrations(db *sql.DB) error {
             text TEXT NOT NULL,
             link VARCHAR(255) NOT NULL
         );
-    `)
+    `,
+	`
+        CREATE TABLE IF NOT EXISTS rate_limit_buckets (
+            key VARCHAR(512) PRIMARY KEY,
+            tokens DOUBLE PRECISION NOT NULL,
+            updated_at TIMESTAMPTZ NOT NULL
+        );
+    `,
+	`
+        CREATE TABLE IF NOT EXISTS audit_log (
+            id BIGSERIAL PRIMARY KEY,
+            song_id INTEGER NOT NULL,
+            action VARCHAR(32) NOT NULL,
+            actor VARCHAR(255) NOT NULL,
+            request_id VARCHAR(255) NOT NULL DEFAULT '',
+            before JSONB,
+            after JSONB,
+            created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+        );
+        CREATE INDEX IF NOT EXISTS audit_log_song_id_idx ON audit_log (song_id, created_at);
+        CREATE INDEX IF NOT EXISTS audit_log_actor_idx ON audit_log (actor, created_at);
+        CREATE INDEX IF NOT EXISTS audit_log_created_at_idx ON audit_log (created_at);
+    `,
+	`
+        CREATE TABLE IF NOT EXISTS song_revisions (
+            song_id INTEGER NOT NULL,
+            revision INTEGER NOT NULL,
+            "group" VARCHAR(255) NOT NULL,
+            song VARCHAR(255) NOT NULL,
+            release_date TIMESTAMP NOT NULL,
+            text TEXT NOT NULL,
+            link VARCHAR(255) NOT NULL,
+            actor VARCHAR(255) NOT NULL,
+            created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+            PRIMARY KEY (song_id, revision)
+        );
+        INSERT INTO song_revisions (song_id, revision, "group", song, release_date, text, link, actor)
+        SELECT s.id, 1, s."group", s.song, s.release_date, s.text, s.link, 'migration'
+        FROM songs s
+        WHERE NOT EXISTS (SELECT 1 FROM song_revisions r WHERE r.song_id = s.id);
+    `,
+	`
+        ALTER TABLE songs ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMPTZ;
+        CREATE INDEX IF NOT EXISTS songs_deleted_at_idx ON songs (deleted_at) WHERE deleted_at IS NOT NULL;
+    `,
+	`
+        ALTER TABLE songs ADD COLUMN IF NOT EXISTS version INTEGER NOT NULL DEFAULT 1;
+        ALTER TABLE songs ADD COLUMN IF NOT EXISTS updated_at TIMESTAMPTZ NOT NULL DEFAULT now();
+    `,
+	`
+        CREATE TABLE IF NOT EXISTS jobs (
+            id BIGSERIAL PRIMARY KEY,
+            kind VARCHAR(16) NOT NULL,
+            status VARCHAR(16) NOT NULL DEFAULT 'queued',
+            params JSONB NOT NULL DEFAULT '{}',
+            input_path TEXT NOT NULL DEFAULT '',
+            artifact_path TEXT NOT NULL DEFAULT '',
+            artifact_name TEXT NOT NULL DEFAULT '',
+            rows_processed INTEGER NOT NULL DEFAULT 0,
+            rows_failed INTEGER NOT NULL DEFAULT 0,
+            errors JSONB NOT NULL DEFAULT '[]',
+            error TEXT NOT NULL DEFAULT '',
+            cancel_requested BOOLEAN NOT NULL DEFAULT false,
+            attempts INTEGER NOT NULL DEFAULT 0,
+            actor VARCHAR(255) NOT NULL DEFAULT '',
+            request_id VARCHAR(255) NOT NULL DEFAULT '',
+            locked_until TIMESTAMPTZ,
+            created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+            started_at TIMESTAMPTZ,
+            finished_at TIMESTAMPTZ
+        )
+    `,
+	`CREATE INDEX IF NOT EXISTS jobs_pending_idx ON jobs (id) WHERE status IN ('queued', 'running')`,
+	`ALTER TABLE songs ADD COLUMN IF NOT EXISTS timings JSONB`,
+	`ALTER TABLE songs ADD COLUMN IF NOT EXISTS sections JSONB`,
+	`ALTER TABLE song_revisions ADD COLUMN IF NOT EXISTS timings JSONB`,
+}
+
+// migrationLock is the advisory lock key that keeps instances starting at
+// the same time from running the migrations concurrently.
+const migrationLock = 7_348_201
+
+// LatestVersion is the schema version this build expects: the number of
+// migrations it knows.
+func LatestVersion() int {
+	return len(migrations)
+}
+
+// CurrentVersion returns the schema version recorded in the database, or 0
+// if no migration has been recorded yet.
+func CurrentVersion(ctx context.Context, db *sql.DB) (int, error) {
+	var version int
+	err := db.QueryRowContext(ctx, "SELECT COALESCE(MAX(version), 0) FROM schema_migrations").Scan(&version)
+	return version, err
+}
+
+// CheckVersion returns an error if the schema is older than this build
+// expects. A newer schema, written by a newer instance during a rollout, is
+// accepted.
+func CheckVersion(ctx context.Context, db *sql.DB) error {
+	current, err := CurrentVersion(ctx, db)
 	if err != nil {
+		return err
+	}
+	if current < LatestVersion() {
+		return fmt.Errorf("schema version %d is behind the expected version %d", current, LatestVersion())
+	}
+	return nil
+}
+
+// RunMigrations applies the migrations that are newer than the version
+// recorded in schema_migrations, in one transaction. Migration n brings the
+// schema to version n.
+func RunMigrations(db *sql.DB) error {
+	utils.Logger.Info("Running database migrations")
+	if _, err := db.Exec(`
+        CREATE TABLE IF NOT EXISTS schema_migrations (
+            version INTEGER PRIMARY KEY,
+            applied_at TIMESTAMPTZ NOT NULL DEFAULT now()
+        )
+    `); err != nil {
+		utils.Logger.Error("Failed to run migrations: ", err)
+		return err
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		utils.Logger.Error("Failed to run migrations: ", err)
+		return err
+	}
+	defer tx.Rollback()
+	if _, err := tx.Exec("SELECT pg_advisory_xact_lock($1)", migrationLock); err != nil {
+		utils.Logger.Error("Failed to run migrations: ", err)
+		return err
+	}
+	var current int
+	if err := tx.QueryRow("SELECT COALESCE(MAX(version), 0) FROM schema_migrations").Scan(&current); err != nil {
+		utils.Logger.Error("Failed to run migrations: ", err)
+		return err
+	}
+	for i := current; i < len(migrations); i++ {
+		if _, err := tx.Exec(migrations[i]); err != nil {
+			utils.Logger.Errorf("Failed to run migration %d: %v", i+1, err)
+			return err
+		}
+		if _, err := tx.Exec("INSERT INTO schema_migrations (version) VALUES ($1)", i+1); err != nil {
+			utils.Logger.Error("Failed to run migrations: ", err)
+			return err
+		}
+	}
+	if err := tx.Commit(); err != nil {
 		utils.Logger.Error("Failed to run migrations: ", err)
 		return err
 	}
 
-	utils.Logger.Info("Migrations completed successfully")
+	utils.Logger.Infof("Migrations completed successfully, schema version %d", max(current, len(migrations)))
 	return nil
 }
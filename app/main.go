@@ -1,19 +1,32 @@
 package main
 
 import (
-	"database/sql"
-	"log"
-	"os"
+	"context"
+	"errors"
+	"net/http"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
 
 	"github.com/gin-gonic/gin"
-	"github.com/joho/godotenv"
+	"github.com/lmd1e/song_library/app/config"
 	"github.com/lmd1e/song_library/app/controllers"
+	"github.com/lmd1e/song_library/app/database"
 	migrations "github.com/lmd1e/song_library/app/database/migrations"
+	"github.com/lmd1e/song_library/app/metrics"
+	"github.com/lmd1e/song_library/app/middleware"
 	"github.com/lmd1e/song_library/app/repositories"
+	"github.com/lmd1e/song_library/app/requests"
 	"github.com/lmd1e/song_library/app/routes"
+	"github.com/lmd1e/song_library/app/server"
+	"github.com/lmd1e/song_library/app/services"
+	"github.com/lmd1e/song_library/app/tracing"
 	"github.com/lmd1e/song_library/app/utils"
+	"github.com/lmd1e/song_library/app/workers"
 	swaggerFiles "github.com/swaggo/files"
 	ginSwagger "github.com/swaggo/gin-swagger"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/gin-gonic/gin/otelgin"
 
 	_ "github.com/lib/pq"
 )
@@ -25,29 +38,170 @@ import (
 // @BasePath /
 func main() {
 
-	if err := godotenv.Load(); err != nil {
-		utils.Logger.Fatal("Error loading .env file")
+	cfg, err := config.Load(".env")
+	if err != nil {
+		utils.Logger.Fatal(err)
+	}
+	if err := utils.ConfigureLogger(cfg.Log.Level, cfg.Log.Format); err != nil {
+		utils.Logger.Fatal(err)
+	}
+
+	shutdownTracing, err := tracing.Setup(context.Background(), cfg.Tracing)
+	if err != nil {
+		utils.Logger.Fatal(err)
 	}
 
-	db, err := sql.Open("postgres", os.Getenv("DATABASE_URL"))
+	db, err := database.Open(cfg.Database)
 	if err != nil {
 		utils.Logger.Fatal(err)
 	}
-	defer db.Close()
+	if err := database.Connect(context.Background(), db, cfg.Database.ConnectTimeout); err != nil {
+		utils.Logger.Fatal(err)
+	}
 
 	if err := migrations.RunMigrations(db); err != nil {
 		utils.Logger.Fatal(err)
 	}
+	if err := metrics.RegisterDB(db, "songs"); err != nil {
+		utils.Logger.Fatal(err)
+	}
+
+	songRepo := repositories.NewSongRepository(db, cfg.Database.QueryTimeout)
+	auditRepo := repositories.NewAuditRepository(db, cfg.Database.QueryTimeout)
+	jobRepo := repositories.NewJobRepository(db, cfg.Database.QueryTimeout)
+	metrics.Registry.MustRegister(metrics.NewSongCountCollector(songRepo.CountSongs))
+
+	songDetailClient := requests.NewSongDetailClient(cfg.ExternalAPI.URL)
+
+	songController := controllers.NewSongController(songRepo, songDetailClient)
+	songController.SetImportLimits(controllers.NewUploadLimits(cfg.Import))
+	songController.SetRequireIfMatch(cfg.Songs.RequireIfMatch)
+	auditController := controllers.NewAuditController(auditRepo)
+	adminController := controllers.NewAdminController(cfg)
+
+	jobStorage, err := services.NewJobStorage(cfg.Jobs.Dir)
+	if err != nil {
+		utils.Logger.Fatal(err)
+	}
+	jobController := controllers.NewJobController(jobRepo, jobStorage,
+		controllers.UploadLimits{MaxSize: int64(cfg.Jobs.MaxUploadSize), Timeout: cfg.Import.Timeout})
+
+	healthChecks := []services.HealthCheck{
+		{Name: "database", Check: db.PingContext},
+		{Name: "migrations", Check: func(ctx context.Context) error { return migrations.CheckVersion(ctx, db) }},
+	}
+	if cfg.Health.CheckExternalAPI {
+		healthChecks = append(healthChecks, services.HealthCheck{Name: "external_api", Check: songDetailClient.Ping})
+	}
+	healthChecker := services.NewHealthChecker(healthChecks...)
+	healthController := controllers.NewHealthController(healthChecker)
+
+	trashPurger := workers.NewTrashPurger(songRepo, cfg.Trash.Retention, cfg.Trash.PurgeInterval)
+	jobPurger := workers.NewJobPurger(jobRepo, jobStorage, cfg.Jobs.Retention, cfg.Jobs.CleanupInterval)
+	jobRunner := workers.NewJobRunner(jobRepo, songRepo, songDetailClient, jobStorage,
+		cfg.Jobs.Workers, cfg.Jobs.PollInterval, cfg.Jobs.Lease)
+
+	// Background workers run until shutdown, which waits for them to stop.
+	workerCtx, stopWorkers := context.WithCancel(context.Background())
+	var workersDone sync.WaitGroup
+	runners := []func(context.Context){trashPurger.Run, jobPurger.Run, jobRunner.Run}
+	if interval := cfg.Database.MonitorInterval; interval > 0 {
+		runners = append(runners, func(ctx context.Context) { database.Monitor(ctx, db, interval) })
+	}
+	for _, run := range runners {
+		workersDone.Add(1)
+		go func() {
+			defer workersDone.Done()
+			run(workerCtx)
+		}()
+	}
+
+	rateLimitConfig, err := middleware.NewRateLimitConfig(cfg.RateLimit.Default, cfg.RateLimit.Routes)
+	if err != nil {
+		utils.Logger.Fatal(err)
+	}
+	rateLimitStore, err := middleware.NewRateLimitStore(cfg.RateLimit.Store, db)
+	if err != nil {
+		utils.Logger.Fatal(err)
+	}
+
+	router, err := server.NewRouter(cfg.Server)
+	if err != nil {
+		utils.Logger.Fatal(err)
+	}
+	// Probes are registered before the middleware so that they are not
+	// logged, traced, counted or rate limited.
+	routes.RegisterHealthRoutes(router, healthController)
+	router.Use(otelgin.Middleware(tracing.ServiceName), middleware.RequestID(), middleware.AccessLog(), middleware.Metrics(), middleware.Recovery())
+	router.Use(middleware.RateLimit(rateLimitStore, rateLimitConfig))
 
-	songRepo := repositories.NewSongRepository(db)
+	var apiGuards []gin.HandlerFunc
+	if cfg.Songs.RequireIfMatch {
+		apiGuards = append(apiGuards, middleware.RequireIfMatch())
+	}
+	apiControllers := routes.APIControllers{Songs: songController, Audit: auditController, Jobs: jobController}
+	routes.RegisterAPIV1(router.Group(routes.APIV1Prefix, apiGuards...), apiControllers)
+	if cfg.API.LegacyRoutes {
+		// Clients written before versioning keep working at the root until
+		// the sunset date, with headers pointing them at /api/v1.
+		deprecatedAt, sunset := cfg.API.LegacyDates()
+		legacyGuards := append([]gin.HandlerFunc{middleware.Deprecated(deprecatedAt, sunset, routes.APIV1Prefix)}, apiGuards...)
+		routes.RegisterAPIV1(router.Group("/", legacyGuards...), apiControllers)
+	}
 
-	songController := controllers.NewSongController(songRepo)
+	if !routes.RegisterAdminRoutes(router, adminController, cfg.Admin.Token) {
+		utils.Logger.Info("Admin endpoints are disabled, set ADMIN_TOKEN to enable them")
+	}
 
-	router := gin.Default()
-	routes.RegisterSongRoutes(router, songController)
+	router.NoRoute(middleware.NoRoute())
+	router.NoMethod(middleware.NoMethod())
 
+	router.GET("/metrics", gin.WrapH(metrics.Handler()))
 	router.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
 
-	utils.Logger.Info("Server started on :8080")
-	log.Fatal(router.Run(":8080"))
+	srv := server.New(cfg.Server, router)
+	go func() {
+		utils.Logger.Info("Server started on ", cfg.Server.Addr)
+		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			utils.Logger.Fatal(err)
+		}
+	}()
+
+	signals, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+	<-signals.Done()
+
+	// Fail readiness first and give load balancers time to notice before
+	// the listener closes.
+	utils.Logger.Info("Shutting down")
+	healthChecker.SetDraining()
+	time.Sleep(cfg.Health.DrainDelay)
+
+	// In-flight requests and background workers finish concurrently within
+	// the shutdown timeout; interrupted jobs are requeued by the runner.
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.Server.ShutdownTimeout)
+	defer cancel()
+	stopWorkers()
+	if err := srv.Shutdown(ctx); err != nil {
+		utils.Logger.Error("Failed to drain in-flight requests: ", err)
+		srv.Close()
+	}
+	stopped := make(chan struct{})
+	go func() {
+		workersDone.Wait()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-ctx.Done():
+		utils.Logger.Error("Background workers did not stop before the shutdown timeout")
+	}
+
+	if err := shutdownTracing(context.Background()); err != nil {
+		utils.Logger.Error("Failed to flush traces: ", err)
+	}
+	if err := db.Close(); err != nil {
+		utils.Logger.Error("Failed to close database: ", err)
+	}
+	utils.Logger.Info("Server stopped")
 }
@@ -0,0 +1,144 @@
+// Package metrics holds the Prometheus collectors of the service and the
+// handler that exposes them.
+package metrics
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const (
+	OutcomeOK      = "ok"
+	OutcomeError   = "error"
+	OutcomeTimeout = "timeout"
+)
+
+// Registry holds every collector of the service, so that tests and other
+// packages registering on the default registry do not interfere.
+var Registry = prometheus.NewRegistry()
+
+var (
+	httpRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "http_requests_total",
+		Help: "HTTP requests served, by method, route and status.",
+	}, []string{"method", "route", "status"})
+
+	httpDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "http_request_duration_seconds",
+		Help:    "Time taken to serve HTTP requests, by method, route and status.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"method", "route", "status"})
+
+	repositoryDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "repository_call_duration_seconds",
+		Help:    "Time taken by repository calls, by repository, method and outcome.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"repository", "method", "outcome"})
+
+	externalDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "external_api_request_duration_seconds",
+		Help:    "Time taken by calls to external APIs, by API and outcome.",
+		Buckets: []float64{.05, .1, .25, .5, 1, 2.5, 5, 10},
+	}, []string{"api", "outcome"})
+
+	externalErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "external_api_errors_total",
+		Help: "Failed calls to external APIs, by API.",
+	}, []string{"api"})
+)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		httpRequests,
+		httpDuration,
+		repositoryDuration,
+		externalDuration,
+		externalErrors,
+	)
+}
+
+// Handler serves the metrics in the Prometheus text format. A failing
+// collector is reported in the log of the scrape and leaves out its metrics
+// instead of failing the whole scrape.
+func Handler() http.Handler {
+	return promhttp.HandlerFor(Registry, promhttp.HandlerOpts{ErrorHandling: promhttp.ContinueOnError})
+}
+
+// ObserveHTTPRequest records a served request. Requests that matched no
+// route share the route label "unmatched".
+func ObserveHTTPRequest(method, route string, status int, elapsed time.Duration) {
+	if route == "" {
+		route = "unmatched"
+	}
+	code := strconv.Itoa(status)
+	httpRequests.WithLabelValues(method, route, code).Inc()
+	httpDuration.WithLabelValues(method, route, code).Observe(elapsed.Seconds())
+}
+
+// ObserveRepositoryCall records the duration of a repository method.
+func ObserveRepositoryCall(repository, method, outcome string, elapsed time.Duration) {
+	repositoryDuration.WithLabelValues(repository, method, outcome).Observe(elapsed.Seconds())
+}
+
+// ObserveExternalCall records a call to an external API.
+func ObserveExternalCall(api string, err error, elapsed time.Duration) {
+	externalDuration.WithLabelValues(api, Outcome(err)).Observe(elapsed.Seconds())
+	if err != nil {
+		externalErrors.WithLabelValues(api).Inc()
+	}
+}
+
+// Outcome classifies an error for the outcome label.
+func Outcome(err error) string {
+	switch {
+	case err == nil:
+		return OutcomeOK
+	case errors.Is(err, context.DeadlineExceeded) || isTimeout(err):
+		return OutcomeTimeout
+	default:
+		return OutcomeError
+	}
+}
+
+func isTimeout(err error) bool {
+	var timeout interface{ Timeout() bool }
+	return errors.As(err, &timeout) && timeout.Timeout()
+}
+
+// RegisterDB exports the connection pool statistics of db.
+func RegisterDB(db *sql.DB, name string) error {
+	return Registry.Register(collectors.NewDBStatsCollector(db, name))
+}
+
+// songsDesc describes the gauge reported by SongCountCollector.
+var songsDesc = prometheus.NewDesc("songs_total", "Songs in the library, not counting the trash.", nil, nil)
+
+// SongCountCollector reports the number of songs, counted on every scrape.
+type SongCountCollector struct {
+	count func(ctx context.Context) (int, error)
+}
+
+func NewSongCountCollector(count func(ctx context.Context) (int, error)) *SongCountCollector {
+	return &SongCountCollector{count: count}
+}
+
+func (c *SongCountCollector) Describe(ch chan<- *prometheus.Desc) { ch <- songsDesc }
+
+func (c *SongCountCollector) Collect(ch chan<- prometheus.Metric) {
+	n, err := c.count(context.Background())
+	if err != nil {
+		ch <- prometheus.NewInvalidMetric(songsDesc, err)
+		return
+	}
+	ch <- prometheus.MustNewConstMetric(songsDesc, prometheus.GaugeValue, float64(n))
+}
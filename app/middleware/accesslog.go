@@ -0,0 +1,46 @@
+package middleware
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lmd1e/song_library/app/utils"
+	"github.com/sirupsen/logrus"
+)
+
+// AccessLog writes one structured line per request once it has been served.
+// It must run after RequestID so that the line carries the request id.
+// Server errors are logged at error level and client errors at warn level.
+func AccessLog() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		start := time.Now()
+		ctx.Next()
+
+		status := ctx.Writer.Status()
+		actor := ctx.GetHeader("X-Actor")
+		if actor == "" {
+			actor = ctx.ClientIP()
+		}
+		entry := utils.LoggerFromContext(ctx.Request.Context()).WithFields(logrus.Fields{
+			"status":     status,
+			"path":       ctx.Request.URL.Path,
+			"latency_ms": float64(time.Since(start).Microseconds()) / 1000,
+			"bytes":      max(ctx.Writer.Size(), 0),
+			"client_ip":  ctx.ClientIP(),
+			"actor":      actor,
+			"user_agent": ctx.Request.UserAgent(),
+		})
+		if len(ctx.Errors) > 0 {
+			entry = entry.WithField("errors", ctx.Errors.String())
+		}
+		switch {
+		case status >= http.StatusInternalServerError:
+			entry.Error("Request served")
+		case status >= http.StatusBadRequest:
+			entry.Warn("Request served")
+		default:
+			entry.Info("Request served")
+		}
+	}
+}
@@ -0,0 +1,23 @@
+package middleware
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lmd1e/song_library/app/utils"
+)
+
+// RequireAdminToken rejects requests that do not carry the given token as
+// "Authorization: Bearer <token>" with 401 Unauthorized.
+func RequireAdminToken(token string) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		given, ok := strings.CutPrefix(ctx.GetHeader("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(given), []byte(token)) != 1 {
+			utils.RespondWithProblem(ctx, http.StatusUnauthorized, "unauthorized", "Admin token is required")
+			return
+		}
+		ctx.Next()
+	}
+}
@@ -0,0 +1,30 @@
+package middleware
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Deprecated marks responses of deprecated routes with the Deprecation
+// (RFC 9745) and Sunset (RFC 8594) headers, and links to the same path under
+// successorPrefix. A zero sunset omits the Sunset header.
+func Deprecated(deprecatedAt, sunset time.Time, successorPrefix string) gin.HandlerFunc {
+	deprecation := "@" + strconv.FormatInt(deprecatedAt.Unix(), 10)
+	var sunsetDate string
+	if !sunset.IsZero() {
+		sunsetDate = sunset.UTC().Format(http.TimeFormat)
+	}
+	return func(ctx *gin.Context) {
+		header := ctx.Writer.Header()
+		header.Set("Deprecation", deprecation)
+		if sunsetDate != "" {
+			header.Set("Sunset", sunsetDate)
+		}
+		header.Add("Link", fmt.Sprintf(`<%s%s>; rel="successor-version"`, successorPrefix, ctx.Request.URL.Path))
+		ctx.Next()
+	}
+}
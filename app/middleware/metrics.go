@@ -0,0 +1,19 @@
+package middleware
+
+import (
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lmd1e/song_library/app/metrics"
+)
+
+// Metrics counts requests and records their duration per route and status.
+// Routes are labelled by their pattern, e.g. /songs/:id, to keep the number
+// of series bounded.
+func Metrics() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		start := time.Now()
+		ctx.Next()
+		metrics.ObserveHTTPRequest(ctx.Request.Method, ctx.FullPath(), ctx.Writer.Status(), time.Since(start))
+	}
+}
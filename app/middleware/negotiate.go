@@ -0,0 +1,27 @@
+package middleware
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lmd1e/song_library/app/utils"
+)
+
+// Negotiate picks the media type of the response from the offered ones
+// according to the Accept header and stores it in the request context for
+// the handler to render with. Requests accepting none of them are rejected
+// with 406 Not Acceptable before the handler runs.
+func Negotiate(offered ...string) gin.HandlerFunc {
+	supported := strings.Join(offered, ", ")
+	return func(ctx *gin.Context) {
+		ctx.Writer.Header().Add("Vary", "Accept")
+		mediaType := utils.NegotiateMediaType(ctx.GetHeader("Accept"), offered)
+		if mediaType == "" {
+			utils.RespondWithProblem(ctx, http.StatusNotAcceptable, "not_acceptable", "Supported media types are "+supported)
+			return
+		}
+		ctx.Request = ctx.Request.WithContext(utils.ContextWithMediaType(ctx.Request.Context(), mediaType))
+		ctx.Next()
+	}
+}
@@ -0,0 +1,24 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lmd1e/song_library/app/utils"
+)
+
+// RequireIfMatch rejects PUT, PATCH and DELETE requests that do not carry an
+// If-Match header with 428 Precondition Required, so that clients cannot
+// overwrite changes they have not seen.
+func RequireIfMatch() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		switch ctx.Request.Method {
+		case http.MethodPut, http.MethodPatch, http.MethodDelete:
+			if ctx.GetHeader("If-Match") == "" {
+				utils.RespondWithProblem(ctx, http.StatusPreconditionRequired, "precondition_required", "If-Match header is required")
+				return
+			}
+		}
+		ctx.Next()
+	}
+}
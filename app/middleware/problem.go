@@ -0,0 +1,38 @@
+package middleware
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"runtime/debug"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lmd1e/song_library/app/utils"
+)
+
+// Recovery turns a panic in a handler into a 500 problem response, logging
+// the panic with its stack trace.
+func Recovery() gin.HandlerFunc {
+	return gin.CustomRecoveryWithWriter(io.Discard, func(ctx *gin.Context, recovered any) {
+		utils.LoggerFromContext(ctx.Request.Context()).
+			WithField("stack", string(debug.Stack())).
+			Error("Recovered from panic: ", fmt.Sprint(recovered))
+		utils.RespondWithProblem(ctx, http.StatusInternalServerError, "internal_error", "Internal server error")
+	})
+}
+
+// NoRoute answers requests for unknown paths with a 404 problem response.
+func NoRoute() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		utils.RespondWithProblem(ctx, http.StatusNotFound, "route_not_found", "No route for "+ctx.Request.URL.Path)
+	}
+}
+
+// NoMethod answers requests whose path exists for other methods only with a
+// 405 problem response. It needs HandleMethodNotAllowed set on the engine,
+// which also fills in the Allow header.
+func NoMethod() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		utils.RespondWithProblem(ctx, http.StatusMethodNotAllowed, "method_not_allowed", "Method "+ctx.Request.Method+" is not allowed for "+ctx.Request.URL.Path)
+	}
+}
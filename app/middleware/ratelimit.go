@@ -0,0 +1,155 @@
+package middleware
+
+import (
+	"database/sql"
+	"fmt"
+	"math"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lmd1e/song_library/app/utils"
+)
+
+var apiVersionPrefix = regexp.MustCompile(`^/api/v[0-9]+/`)
+
+// RateLimitConfig holds the default limit and per-route overrides keyed by
+// "METHOD /route/:param" as registered in gin. A zero Burst means unlimited.
+// An unversioned route also covers the same route under every /api/vN
+// prefix, which shares its bucket, unless the versioned route has an
+// override of its own.
+type RateLimitConfig struct {
+	Default Limit
+	Routes  map[string]Limit
+}
+
+// ParseLimit parses a limit written as "<requests>/<period>", e.g. "60/1m".
+func ParseLimit(spec string) (Limit, error) {
+	parts := strings.SplitN(strings.TrimSpace(spec), "/", 2)
+	if len(parts) != 2 {
+		return Limit{}, fmt.Errorf("invalid rate limit %q: expected <requests>/<period>", spec)
+	}
+	burst, err := strconv.Atoi(parts[0])
+	if err != nil || burst < 0 {
+		return Limit{}, fmt.Errorf("invalid rate limit %q: bad request count", spec)
+	}
+	period, err := time.ParseDuration(parts[1])
+	if err != nil || period <= 0 {
+		return Limit{}, fmt.Errorf("invalid rate limit %q: bad period", spec)
+	}
+	return Limit{Burst: burst, Period: period}, nil
+}
+
+// ParseRouteLimits parses a ";"-separated list of "METHOD /route=<limit>"
+// entries, e.g. "POST /songs=10/1m;GET /songs=100/1m".
+func ParseRouteLimits(spec string) (map[string]Limit, error) {
+	routes := make(map[string]Limit)
+	for _, entry := range strings.Split(spec, ";") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		route, limitSpec, ok := strings.Cut(entry, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid route rate limit %q: expected METHOD /route=<limit>", entry)
+		}
+		limit, err := ParseLimit(limitSpec)
+		if err != nil {
+			return nil, err
+		}
+		routes[strings.Join(strings.Fields(route), " ")] = limit
+	}
+	return routes, nil
+}
+
+// NewRateLimitConfig parses the default limit, empty for unlimited, and the
+// per-route overrides in the formats of ParseLimit and ParseRouteLimits.
+func NewRateLimitConfig(defaultSpec, routesSpec string) (RateLimitConfig, error) {
+	var config RateLimitConfig
+	if spec := defaultSpec; spec != "" {
+		limit, err := ParseLimit(spec)
+		if err != nil {
+			return config, err
+		}
+		config.Default = limit
+	}
+	routes, err := ParseRouteLimits(routesSpec)
+	if err != nil {
+		return config, err
+	}
+	config.Routes = routes
+	return config, nil
+}
+
+// NewRateLimitStore returns the store named by kind, "memory" (the default)
+// or "postgres".
+func NewRateLimitStore(kind string, db *sql.DB) (RateLimitStore, error) {
+	switch store := kind; store {
+	case "", "memory":
+		return NewMemoryRateLimitStore(), nil
+	case "postgres":
+		return NewPostgresRateLimitStore(db), nil
+	default:
+		return nil, fmt.Errorf("unknown rate limit store %q", store)
+	}
+}
+
+// routeFor returns the route a request is limited under.
+func (c RateLimitConfig) routeFor(method, route string) string {
+	if _, ok := c.Routes[method+" "+route]; ok {
+		return route
+	}
+	if prefix := apiVersionPrefix.FindString(route); prefix != "" {
+		return route[len(prefix)-1:]
+	}
+	return route
+}
+
+func (c RateLimitConfig) limitFor(method, route string) Limit {
+	if limit, ok := c.Routes[method+" "+route]; ok {
+		return limit
+	}
+	return c.Default
+}
+
+// RateLimit limits requests per client and route with a token bucket. Clients
+// are identified by their IP address: API keys are not authenticated, so a
+// client could otherwise get a fresh bucket by sending a new key. Store
+// failures are logged and the request is let through.
+func RateLimit(store RateLimitStore, config RateLimitConfig) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		route := config.routeFor(ctx.Request.Method, ctx.FullPath())
+		limit := config.limitFor(ctx.Request.Method, route)
+		if limit.Burst == 0 {
+			ctx.Next()
+			return
+		}
+
+		key := "ip:" + ctx.ClientIP() + "|" + ctx.Request.Method + " " + route
+		result, err := store.Take(ctx.Request.Context(), key, limit)
+		if err != nil {
+			utils.LoggerFromContext(ctx.Request.Context()).Error("Failed to apply rate limit: ", err)
+			ctx.Next()
+			return
+		}
+
+		ctx.Header("RateLimit-Limit", strconv.Itoa(limit.Burst))
+		ctx.Header("RateLimit-Remaining", strconv.Itoa(result.Remaining))
+		ctx.Header("RateLimit-Reset", strconv.Itoa(ceilSeconds(result.ResetAfter)))
+		ctx.Header("RateLimit-Policy", fmt.Sprintf("%d;w=%d", limit.Burst, ceilSeconds(limit.Period)))
+
+		if !result.Allowed {
+			ctx.Header("Retry-After", strconv.Itoa(ceilSeconds(result.RetryAfter)))
+			utils.RespondWithProblem(ctx, http.StatusTooManyRequests, "rate_limited", "Too many requests")
+			return
+		}
+		ctx.Next()
+	}
+}
+
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
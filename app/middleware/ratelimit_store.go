@@ -0,0 +1,168 @@
+package middleware
+
+import (
+	"context"
+	"database/sql"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/lmd1e/song_library/app/utils"
+)
+
+// Limit describes a token bucket: Burst tokens refilled evenly over Period.
+type Limit struct {
+	Burst  int
+	Period time.Duration
+}
+
+// RateLimitResult is the outcome of taking a token from a bucket.
+type RateLimitResult struct {
+	Allowed    bool
+	Remaining  int
+	RetryAfter time.Duration
+	ResetAfter time.Duration
+}
+
+// RateLimitStore keeps token buckets. Implementations must be safe for
+// concurrent use.
+type RateLimitStore interface {
+	Take(ctx context.Context, key string, limit Limit) (RateLimitResult, error)
+}
+
+func (l Limit) ratePerSecond() float64 {
+	return float64(l.Burst) / l.Period.Seconds()
+}
+
+// take refills a bucket holding tokens after elapsed time and tries to
+// consume one token from it, returning the new token count.
+func (l Limit) take(tokens float64, elapsed time.Duration) (float64, RateLimitResult) {
+	rate := l.ratePerSecond()
+	tokens = math.Min(float64(l.Burst), tokens+elapsed.Seconds()*rate)
+
+	var result RateLimitResult
+	if tokens >= 1 {
+		tokens--
+		result.Allowed = true
+	} else {
+		result.RetryAfter = time.Duration((1 - tokens) / rate * float64(time.Second))
+	}
+	result.Remaining = int(tokens)
+	result.ResetAfter = time.Duration((float64(l.Burst) - tokens) / rate * float64(time.Second))
+	return tokens, result
+}
+
+type bucket struct {
+	tokens  float64
+	updated time.Time
+	period  time.Duration
+}
+
+// MemoryRateLimitStore keeps buckets in process memory. It is the default
+// store and is only accurate for single-instance deployments.
+type MemoryRateLimitStore struct {
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+}
+
+func NewMemoryRateLimitStore() *MemoryRateLimitStore {
+	return &MemoryRateLimitStore{
+		buckets: make(map[string]*bucket),
+	}
+}
+
+func (s *MemoryRateLimitStore) Take(_ context.Context, key string, limit Limit) (RateLimitResult, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	s.sweep(now)
+
+	b, ok := s.buckets[key]
+	if !ok {
+		b = &bucket{tokens: float64(limit.Burst), updated: now, period: limit.Period}
+		s.buckets[key] = b
+	}
+	tokens, result := limit.take(b.tokens, now.Sub(b.updated))
+	b.tokens = tokens
+	b.updated = now
+	return result, nil
+}
+
+// sweep drops buckets that have been idle long enough to be full again.
+func (s *MemoryRateLimitStore) sweep(now time.Time) {
+	if now.Sub(s.lastSweep) < time.Minute {
+		return
+	}
+	s.lastSweep = now
+	for key, b := range s.buckets {
+		if now.Sub(b.updated) > b.period {
+			delete(s.buckets, key)
+		}
+	}
+}
+
+// PostgresRateLimitStore keeps buckets in the rate_limit_buckets table so
+// that limits are shared between all instances using the same database.
+type PostgresRateLimitStore struct {
+	db        *sql.DB
+	mu        sync.Mutex
+	lastSweep time.Time
+}
+
+func NewPostgresRateLimitStore(db *sql.DB) *PostgresRateLimitStore {
+	return &PostgresRateLimitStore{db: db}
+}
+
+func (s *PostgresRateLimitStore) Take(ctx context.Context, key string, limit Limit) (RateLimitResult, error) {
+	s.sweep(ctx)
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return RateLimitResult{}, err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, `
+        INSERT INTO rate_limit_buckets (key, tokens, updated_at)
+        VALUES ($1, $2, now())
+        ON CONFLICT (key) DO NOTHING
+    `, key, float64(limit.Burst))
+	if err != nil {
+		return RateLimitResult{}, err
+	}
+
+	var tokens, elapsed float64
+	err = tx.QueryRowContext(ctx, `
+        SELECT tokens, EXTRACT(EPOCH FROM (now() - updated_at))
+        FROM rate_limit_buckets
+        WHERE key = $1
+        FOR UPDATE
+    `, key).Scan(&tokens, &elapsed)
+	if err != nil {
+		return RateLimitResult{}, err
+	}
+
+	tokens, result := limit.take(tokens, time.Duration(elapsed*float64(time.Second)))
+	_, err = tx.ExecContext(ctx, "UPDATE rate_limit_buckets SET tokens = $1, updated_at = now() WHERE key = $2", tokens, key)
+	if err != nil {
+		return RateLimitResult{}, err
+	}
+	return result, tx.Commit()
+}
+
+// sweep periodically removes buckets that have not been touched for a day.
+func (s *PostgresRateLimitStore) sweep(ctx context.Context) {
+	s.mu.Lock()
+	if time.Since(s.lastSweep) < 10*time.Minute {
+		s.mu.Unlock()
+		return
+	}
+	s.lastSweep = time.Now()
+	s.mu.Unlock()
+
+	if _, err := s.db.ExecContext(ctx, "DELETE FROM rate_limit_buckets WHERE updated_at < now() - interval '1 day'"); err != nil {
+		utils.Logger.Error("Failed to sweep rate limit buckets: ", err)
+	}
+}
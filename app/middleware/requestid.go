@@ -0,0 +1,62 @@
+package middleware
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lmd1e/song_library/app/utils"
+	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	RequestIDHeader    = "X-Request-ID"
+	maxRequestIDLength = 128
+)
+
+// RequestID gives every request an id, taken from the X-Request-ID header
+// when the client sends a usable one and generated otherwise, and echoes it
+// in the response. The id and a logger carrying it, the method and the route
+// are stored in the request context for the handlers and repositories; when
+// the request is traced, the logger also carries the trace id.
+func RequestID() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		requestID := ctx.GetHeader(RequestIDHeader)
+		if !validRequestID(requestID) {
+			requestID = newRequestID()
+		}
+		ctx.Header(RequestIDHeader, requestID)
+
+		fields := logrus.Fields{
+			"request_id": requestID,
+			"method":     ctx.Request.Method,
+			"route":      ctx.FullPath(),
+		}
+		if span := trace.SpanContextFromContext(ctx.Request.Context()); span.IsValid() {
+			fields["trace_id"] = span.TraceID().String()
+		}
+		logger := utils.Logger.WithFields(fields)
+		reqCtx := utils.ContextWithRequestID(ctx.Request.Context(), requestID)
+		ctx.Request = ctx.Request.WithContext(utils.ContextWithLogger(reqCtx, logger))
+		ctx.Next()
+	}
+}
+
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] <= ' ' || id[i] > '~' {
+			return false
+		}
+	}
+	return true
+}
+
+func newRequestID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
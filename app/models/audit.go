@@ -0,0 +1,40 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+const (
+	AuditActionCreate   = "create"
+	AuditActionUpdate   = "update"
+	AuditActionDelete   = "delete"
+	AuditActionRestore  = "restore"
+	AuditActionUndelete = "undelete"
+	AuditActionPurge    = "purge"
+)
+
+type AuditEntry struct {
+	ID        int             `json:"id"`
+	SongID    int             `json:"song_id"`
+	Action    string          `json:"action"`
+	Actor     string          `json:"actor"`
+	RequestID string          `json:"request_id"`
+	Before    json.RawMessage `json:"before" swaggertype:"object"`
+	After     json.RawMessage `json:"after" swaggertype:"object"`
+	CreatedAt time.Time       `json:"created_at"`
+}
+
+// AuditMeta identifies who made a change and in which request.
+type AuditMeta struct {
+	Actor     string
+	RequestID string
+}
+
+// AuditFilter narrows the audit log; zero values are ignored.
+type AuditFilter struct {
+	SongID int
+	Actor  string
+	From   time.Time
+	To     time.Time
+}
@@ -0,0 +1,54 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+const (
+	JobKindImport = "import"
+	JobKindExport = "export"
+
+	JobStatusQueued    = "queued"
+	JobStatusRunning   = "running"
+	JobStatusSucceeded = "succeeded"
+	JobStatusFailed    = "failed"
+	JobStatusCancelled = "cancelled"
+)
+
+type Job struct {
+	ID              int64           `json:"id"`
+	Kind            string          `json:"kind"`
+	Status          string          `json:"status"`
+	Params          JobParams       `json:"params"`
+	RowsProcessed   int             `json:"rows_processed"`
+	RowsFailed      int             `json:"rows_failed"`
+	Errors          json.RawMessage `json:"errors" swaggertype:"array,object"`
+	Error           string          `json:"error,omitempty"`
+	CancelRequested bool            `json:"cancel_requested"`
+	Attempts        int             `json:"attempts"`
+	Actor           string          `json:"actor"`
+	RequestID       string          `json:"request_id"`
+	ArtifactURL     string          `json:"artifact_url,omitempty"`
+	CreatedAt       time.Time       `json:"created_at"`
+	StartedAt       *time.Time      `json:"started_at,omitempty"`
+	FinishedAt      *time.Time      `json:"finished_at,omitempty"`
+	InputPath       string          `json:"-"`
+	ArtifactPath    string          `json:"-"`
+	ArtifactName    string          `json:"-"`
+}
+
+// JobParams holds the options of an import or export job.
+type JobParams struct {
+	Format  string            `json:"format"`
+	Mapping map[string]string `json:"map,omitempty"`
+	Enrich  bool              `json:"enrich,omitempty"`
+	DryRun  bool              `json:"dry_run,omitempty"`
+	Filter  map[string]string `json:"filter,omitempty"`
+	Gzip    bool              `json:"gzip,omitempty"`
+}
+
+// Finished reports whether the job has reached a final status.
+func (j Job) Finished() bool {
+	return j.Status == JobStatusSucceeded || j.Status == JobStatusFailed || j.Status == JobStatusCancelled
+}
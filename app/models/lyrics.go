@@ -0,0 +1,111 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// LyricTimings holds the start of every line of a song's text, counted from
+// the beginning of the song, for synced lyrics. It is stored and encoded in
+// JSON as whole milliseconds.
+type LyricTimings []time.Duration
+
+func (t LyricTimings) MarshalJSON() ([]byte, error) {
+	ms := make([]int64, len(t))
+	for i, start := range t {
+		ms[i] = start.Milliseconds()
+	}
+	return json.Marshal(ms)
+}
+
+func (t *LyricTimings) UnmarshalJSON(data []byte) error {
+	var ms []int64
+	if err := json.Unmarshal(data, &ms); err != nil {
+		return err
+	}
+	if ms == nil {
+		*t = nil
+		return nil
+	}
+	*t = make(LyricTimings, len(ms))
+	for i, v := range ms {
+		(*t)[i] = time.Duration(v) * time.Millisecond
+	}
+	return nil
+}
+
+// Value stores timings as a JSON array, and no timings as NULL.
+func (t LyricTimings) Value() (driver.Value, error) {
+	if len(t) == 0 {
+		return nil, nil
+	}
+	return t.MarshalJSON()
+}
+
+func (t *LyricTimings) Scan(src interface{}) error {
+	switch src := src.(type) {
+	case nil:
+		*t = nil
+		return nil
+	case []byte:
+		return t.UnmarshalJSON(src)
+	case string:
+		return t.UnmarshalJSON([]byte(src))
+	default:
+		return fmt.Errorf("cannot scan %T into LyricTimings", src)
+	}
+}
+
+// Lyrics is the text of a song split into lines, with the start of every
+// line when the song has synced lyrics.
+type Lyrics struct {
+	Lines   []string
+	Timings LyricTimings
+	// End is the start of the line following the last one, or zero when
+	// the lines run to the end of the song.
+	End time.Duration
+}
+
+// NewLyrics splits text into lines. Timings that do not match the lines
+// are ignored.
+func NewLyrics(text string, timings LyricTimings) Lyrics {
+	lyrics := Lyrics{Lines: strings.Split(text, "\n")}
+	if len(timings) == len(lyrics.Lines) {
+		lyrics.Timings = timings
+	}
+	return lyrics
+}
+
+// Synced reports whether the lines have timings. A page of synced lyrics
+// stays synced when it has no lines.
+func (l Lyrics) Synced() bool {
+	return l.Timings != nil
+}
+
+// Text joins the lines back into the text.
+func (l Lyrics) Text() string {
+	return strings.Join(l.Lines, "\n")
+}
+
+// Page returns limit lines starting at offset; past the last line, it has
+// none.
+func (l Lyrics) Page(offset, limit int) Lyrics {
+	if offset >= len(l.Lines) {
+		if l.Synced() {
+			return Lyrics{Timings: LyricTimings{}}
+		}
+		return Lyrics{}
+	}
+	end := min(offset+limit, len(l.Lines))
+	page := Lyrics{Lines: l.Lines[offset:end], End: l.End}
+	if l.Synced() {
+		page.Timings = l.Timings[offset:end]
+		if end < len(l.Timings) {
+			page.End = l.Timings[end]
+		}
+	}
+	return page
+}
@@ -0,0 +1,29 @@
+package models
+
+import (
+	"time"
+
+	"github.com/lmd1e/song_library/app/utils"
+)
+
+type SongRevision struct {
+	SongID      int       `json:"song_id"`
+	Revision    int       `json:"revision"`
+	Group       string    `json:"group"`
+	Song        string    `json:"song"`
+	ReleaseDate time.Time `json:"release_date"`
+	Text        string    `json:"text"`
+	Link        string    `json:"link"`
+	// Timings, in milliseconds, is set when the revision had synced lyrics.
+	Timings   LyricTimings `json:"timings,omitempty" swaggertype:"array,integer"`
+	Actor     string       `json:"actor"`
+	CreatedAt time.Time    `json:"created_at"`
+}
+
+type RevisionDiff struct {
+	SongID      int                `json:"song_id"`
+	From        int                `json:"from"`
+	To          int                `json:"to"`
+	Granularity string             `json:"granularity"`
+	Changes     []utils.DiffChange `json:"changes"`
+}
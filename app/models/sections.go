@@ -0,0 +1,207 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// Section types of a song's text. Labels that name none of them, such as
+// [Spoken], have the type other.
+const (
+	SectionIntro      = "intro"
+	SectionVerse      = "verse"
+	SectionPreChorus  = "pre-chorus"
+	SectionChorus     = "chorus"
+	SectionPostChorus = "post-chorus"
+	SectionBridge     = "bridge"
+	SectionInterlude  = "interlude"
+	SectionOutro      = "outro"
+	SectionOther      = "other"
+)
+
+var SectionTypes = []string{
+	SectionIntro, SectionVerse, SectionPreChorus, SectionChorus, SectionPostChorus,
+	SectionBridge, SectionInterlude, SectionOutro, SectionOther,
+}
+
+// sectionSynonyms maps the first word of a label to its section type.
+var sectionSynonyms = map[string]string{
+	"intro":       SectionIntro,
+	"verse":       SectionVerse,
+	"pre-chorus":  SectionPreChorus,
+	"prechorus":   SectionPreChorus,
+	"chorus":      SectionChorus,
+	"refrain":     SectionChorus,
+	"hook":        SectionChorus,
+	"post-chorus": SectionPostChorus,
+	"postchorus":  SectionPostChorus,
+	"bridge":      SectionBridge,
+	"interlude":   SectionInterlude,
+	"outro":       SectionOutro,
+}
+
+// LyricSection is a labelled part of a song's text, such as a verse or the
+// chorus. Start and End delimit its lines in the text, header excluded; a
+// section without lines, such as a second bare [Chorus], repeats the last
+// section with the same label.
+type LyricSection struct {
+	Type  string `json:"type"`
+	Label string `json:"label,omitempty"`
+	Start int    `json:"start"`
+	End   int    `json:"end"`
+}
+
+// LyricSections are stored as JSON.
+type LyricSections []LyricSection
+
+func (s LyricSections) Value() (driver.Value, error) {
+	return json.Marshal(s)
+}
+
+func (s *LyricSections) Scan(src interface{}) error {
+	switch src := src.(type) {
+	case nil:
+		*s = nil
+		return nil
+	case []byte:
+		return json.Unmarshal(src, s)
+	case string:
+		return json.Unmarshal([]byte(src), s)
+	default:
+		return fmt.Errorf("cannot scan %T into LyricSections", src)
+	}
+}
+
+var (
+	bracketHeader = regexp.MustCompile(`^\[([^\[\]]+)\]$`)
+	colonHeader   = regexp.MustCompile(`^([\pL][\pL\d -]*):$`)
+	lrcTime       = regexp.MustCompile(`^\d+:\d`)
+)
+
+// ParseLyricSections finds the sections of text. A section starts at a
+// header line written as [Chorus], [Verse 2: Artist] or "Chorus:" and runs
+// until the next header. Text before the first header, or all of it when
+// there are none, is split at blank lines into verses.
+func ParseLyricSections(text string) LyricSections {
+	lines := strings.Split(text, "\n")
+	sections := LyricSections{}
+	stanzas := func(start, end int) {
+		for i := start; i < end; i++ {
+			if strings.TrimSpace(lines[i]) == "" {
+				continue
+			}
+			j := i
+			for j < end && strings.TrimSpace(lines[j]) != "" {
+				j++
+			}
+			sections = append(sections, LyricSection{Type: SectionVerse, Start: i, End: j})
+			i = j
+		}
+	}
+
+	var header *LyricSection
+	closeHeader := func(end int) {
+		if header == nil {
+			return
+		}
+		start := header.Start
+		for start < end && strings.TrimSpace(lines[start]) == "" {
+			start++
+		}
+		for end > start && strings.TrimSpace(lines[end-1]) == "" {
+			end--
+		}
+		if start == end {
+			start, end = header.Start, header.Start
+		}
+		header.Start, header.End = start, end
+		sections = append(sections, *header)
+	}
+
+	for i, line := range lines {
+		label, ok := sectionHeader(strings.TrimSpace(line))
+		if !ok {
+			continue
+		}
+		if header == nil {
+			stanzas(0, i)
+		}
+		closeHeader(i)
+		header = &LyricSection{Type: SectionType(label), Label: label, Start: i + 1}
+	}
+	if header == nil {
+		stanzas(0, len(lines))
+	}
+	closeHeader(len(lines))
+	return sections
+}
+
+// sectionHeader returns the label of a header line.
+func sectionHeader(line string) (string, bool) {
+	if match := bracketHeader.FindStringSubmatch(line); match != nil && !lrcTime.MatchString(match[1]) {
+		return strings.TrimSpace(match[1]), true
+	}
+	if match := colonHeader.FindStringSubmatch(line); match != nil && SectionType(match[1]) != SectionOther {
+		return strings.TrimSpace(match[1]), true
+	}
+	return "", false
+}
+
+// SectionType returns the type named by the first word of a label.
+func SectionType(label string) string {
+	words := strings.FieldsFunc(strings.ToLower(label), func(r rune) bool {
+		return !unicode.IsLetter(r) && r != '-'
+	})
+	if len(words) == 0 {
+		return SectionOther
+	}
+	word := words[0]
+	if word == "pre" || word == "post" {
+		if len(words) > 1 {
+			word += words[1]
+		}
+	}
+	if sectionType, ok := sectionSynonyms[word]; ok {
+		return sectionType
+	}
+	return SectionOther
+}
+
+// LyricStructure is the text of a song split into lines with its sections.
+type LyricStructure struct {
+	Lines    []string
+	Sections LyricSections
+}
+
+// ResolvedSection is a section with its lines; Repeat is set when the lines
+// are those of an earlier section with the same label.
+type ResolvedSection struct {
+	Type   string
+	Label  string
+	Repeat bool
+	Lines  []string
+}
+
+// Resolve returns the sections with their lines, filling in repeats.
+func (s LyricStructure) Resolve() []ResolvedSection {
+	resolved := make([]ResolvedSection, 0, len(s.Sections))
+	lastByLabel := make(map[string][]string)
+	for _, section := range s.Sections {
+		if section.Start < 0 || section.End > len(s.Lines) || section.Start > section.End {
+			continue
+		}
+		r := ResolvedSection{Type: section.Type, Label: section.Label, Lines: s.Lines[section.Start:section.End]}
+		key := strings.ToLower(section.Label)
+		if len(r.Lines) == 0 && key != "" {
+			r.Lines, r.Repeat = lastByLabel[key], lastByLabel[key] != nil
+		} else if key != "" {
+			lastByLabel[key] = r.Lines
+		}
+		resolved = append(resolved, r)
+	}
+	return resolved
+}
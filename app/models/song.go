@@ -9,4 +9,46 @@ type Song struct {
 	ReleaseDate time.Time `json:"release_date"`
 	Text        string    `json:"text"`
 	Link        string    `json:"link"`
+	// Timings is set for synced lyrics. A nil Timings passed to an update
+	// keeps the current timings if the text is unchanged.
+	Timings   LyricTimings `json:"timings,omitempty" swaggertype:"array,integer"`
+	DeletedAt *time.Time   `json:"deleted_at,omitempty"`
+	Version   int          `json:"version"`
+	UpdatedAt time.Time    `json:"updated_at"`
+}
+
+// SongPatch holds a partial update of a song; nil fields are left unchanged.
+type SongPatch struct {
+	Group       *string    `json:"group"`
+	Song        *string    `json:"song"`
+	ReleaseDate *time.Time `json:"release_date"`
+	Text        *string    `json:"text"`
+	Link        *string    `json:"link"`
+	// Timings replaces the timings of synced lyrics; when nil, a changed
+	// Text drops them.
+	Timings *LyricTimings `json:"-"`
+	Version int           `json:"-"`
+}
+
+func (p SongPatch) Apply(song Song) Song {
+	if p.Group != nil {
+		song.Group = *p.Group
+	}
+	if p.Song != nil {
+		song.Song = *p.Song
+	}
+	if p.ReleaseDate != nil {
+		song.ReleaseDate = *p.ReleaseDate
+	}
+	if p.Text != nil && *p.Text != song.Text {
+		song.Text = *p.Text
+		song.Timings = nil
+	}
+	if p.Timings != nil {
+		song.Timings = *p.Timings
+	}
+	if p.Link != nil {
+		song.Link = *p.Link
+	}
+	return song
 }
@@ -0,0 +1,109 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/lmd1e/song_library/app/models"
+	"github.com/lmd1e/song_library/app/utils"
+)
+
+type AuditRepository interface {
+	GetAuditEntries(ctx context.Context, filter models.AuditFilter, limit, offset int) ([]models.AuditEntry, error)
+}
+
+type AuditRepositoryImpl struct {
+	db           DBTX
+	queryTimeout time.Duration
+}
+
+func NewAuditRepository(db DBTX, queryTimeout time.Duration) *AuditRepositoryImpl {
+	return &AuditRepositoryImpl{db: db, queryTimeout: queryTimeout}
+}
+
+func (r *AuditRepositoryImpl) GetAuditEntries(ctx context.Context, filter models.AuditFilter, limit, offset int) (_ []models.AuditEntry, err error) {
+	utils.LoggerFromContext(ctx).Debug("Fetching audit entries from the database")
+	ctx, done := startCall(ctx, r.queryTimeout, "AuditRepository", "GetAuditEntries")
+	defer done(&err)
+	query := "SELECT id, song_id, action, actor, request_id, before, after, created_at FROM audit_log"
+	var conditions []string
+	var args []interface{}
+	addCondition := func(condition string, arg interface{}) {
+		args = append(args, arg)
+		conditions = append(conditions, fmt.Sprintf(condition, len(args)))
+	}
+	if filter.SongID != 0 {
+		addCondition("song_id = $%d", filter.SongID)
+	}
+	if filter.Actor != "" {
+		addCondition("actor = $%d", filter.Actor)
+	}
+	if !filter.From.IsZero() {
+		addCondition("created_at >= $%d", filter.From)
+	}
+	if !filter.To.IsZero() {
+		addCondition("created_at < $%d", filter.To)
+	}
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	query += fmt.Sprintf(" ORDER BY created_at DESC, id DESC LIMIT %d OFFSET %d", limit, offset)
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		utils.LoggerFromContext(ctx).Error("Failed to fetch audit entries: ", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	entries := []models.AuditEntry{}
+	for rows.Next() {
+		var entry models.AuditEntry
+		var before, after []byte
+		if err := rows.Scan(&entry.ID, &entry.SongID, &entry.Action, &entry.Actor, &entry.RequestID, &before, &after, &entry.CreatedAt); err != nil {
+			utils.LoggerFromContext(ctx).Error("Failed to scan audit row: ", err)
+			return nil, err
+		}
+		entry.Before = before
+		entry.After = after
+		entries = append(entries, entry)
+	}
+	return entries, rows.Err()
+}
+
+// insertAuditEntry records a song mutation inside the transaction that
+// performs it, so the change and its audit entry commit or roll back together.
+func insertAuditEntry(ctx context.Context, tx *sql.Tx, songID int, action string, meta models.AuditMeta, before, after *models.Song) error {
+	beforeJSON, err := songSnapshot(before)
+	if err != nil {
+		return err
+	}
+	afterJSON, err := songSnapshot(after)
+	if err != nil {
+		return err
+	}
+	query := `
+        INSERT INTO audit_log (song_id, action, actor, request_id, before, after)
+        VALUES ($1, $2, $3, $4, $5, $6)
+    `
+	_, err = tx.ExecContext(ctx, query, songID, action, meta.Actor, meta.RequestID, beforeJSON, afterJSON)
+	if err != nil {
+		utils.LoggerFromContext(ctx).Error("Failed to write audit entry: ", err)
+	}
+	return err
+}
+
+func songSnapshot(song *models.Song) (interface{}, error) {
+	if song == nil {
+		return nil, nil
+	}
+	data, err := json.Marshal(song)
+	if err != nil {
+		return nil, err
+	}
+	return string(data), nil
+}
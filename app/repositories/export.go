@@ -0,0 +1,115 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"sync/atomic"
+
+	"github.com/lmd1e/song_library/app/models"
+	"github.com/lmd1e/song_library/app/utils"
+)
+
+const exportFetchSize = 500
+
+// exportCursors numbers the export cursors.
+var exportCursors atomic.Int64
+
+// ExportSongs passes every song matching filter to fn in id order. Rows are
+// read through a server-side cursor in a read-only transaction, so the export
+// sees a consistent snapshot without loading the whole library into memory.
+// On a repository bound to a transaction the cursor runs in that transaction.
+// The query timeout applies to each statement rather than the whole export.
+func (r *SongRepositoryImpl) ExportSongs(ctx context.Context, filter map[string]string, fn func(models.Song) error) (err error) {
+	utils.LoggerFromContext(ctx).Debug("Exporting songs from the database")
+	ctx, done := startCall(ctx, 0, "SongRepository", "ExportSongs")
+	defer done(&err)
+	tx, bound := r.db.(*sql.Tx)
+	if !bound {
+		db, ok := r.db.(*sql.DB)
+		if !ok {
+			return fmt.Errorf("unsupported database handle %T", r.db)
+		}
+		var err error
+		tx, err = db.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true})
+		if err != nil {
+			utils.LoggerFromContext(ctx).Error("Failed to begin transaction: ", err)
+			return err
+		}
+		defer tx.Rollback()
+	}
+
+	if err := r.exportCursor(ctx, tx, filter, fn); err != nil || bound {
+		return err
+	}
+	return tx.Commit()
+}
+
+// exportCursor declares a cursor for the songs matching filter in tx and
+// passes them to fn. The cursor has a name of its own, so several exports can
+// share a transaction, and is closed however the export ends.
+func (r *SongRepositoryImpl) exportCursor(ctx context.Context, tx *sql.Tx, filter map[string]string, fn func(models.Song) error) (err error) {
+	cursor := fmt.Sprintf("song_export_%d", exportCursors.Add(1))
+	conditions, args := songFilterConditions(filter)
+	query := "DECLARE " + cursor + " NO SCROLL CURSOR FOR SELECT " + songColumns +
+		" FROM songs WHERE " + strings.Join(conditions, " AND ") + " ORDER BY id"
+	if err := r.execStatement(ctx, tx, query, args...); err != nil {
+		utils.LoggerFromContext(ctx).Error("Failed to open export cursor: ", err)
+		return err
+	}
+	defer func() {
+		// The cursor is closed even when the request is cancelled, as the
+		// transaction may outlive it.
+		if closeErr := r.execStatement(context.WithoutCancel(ctx), tx, "CLOSE "+cursor); err == nil {
+			err = closeErr
+		}
+	}()
+
+	for {
+		songs, err := r.fetchSongs(ctx, tx, cursor)
+		if err != nil {
+			return err
+		}
+		for _, song := range songs {
+			if err := fn(song); err != nil {
+				return err
+			}
+		}
+		if len(songs) < exportFetchSize {
+			return nil
+		}
+	}
+}
+
+func (r *SongRepositoryImpl) execStatement(ctx context.Context, tx *sql.Tx, query string, args ...interface{}) (err error) {
+	ctx, done := withQueryTimeout(ctx, r.queryTimeout)
+	defer done(&err)
+	_, err = tx.ExecContext(ctx, query, args...)
+	return err
+}
+
+// fetchSongs reads the next rows of an export cursor. The rows are read in
+// full before they are handed on, so a slow consumer does not count against
+// the query timeout.
+func (r *SongRepositoryImpl) fetchSongs(ctx context.Context, tx *sql.Tx, cursor string) (_ []models.Song, err error) {
+	ctx, done := withQueryTimeout(ctx, r.queryTimeout)
+	defer done(&err)
+	rows, err := tx.QueryContext(ctx, fmt.Sprintf("FETCH FORWARD %d FROM %s", exportFetchSize, cursor))
+	if err != nil {
+		utils.LoggerFromContext(ctx).Error("Failed to fetch songs for export: ", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	songs := make([]models.Song, 0, exportFetchSize)
+	for rows.Next() {
+		var song models.Song
+		if err := scanSong(rows, &song); err != nil {
+			utils.LoggerFromContext(ctx).Error("Failed to scan song row: ", err)
+			return nil, err
+		}
+		songs = append(songs, song)
+	}
+	return songs, rows.Err()
+}
@@ -0,0 +1,36 @@
+package repositories
+
+import (
+	"fmt"
+	"sort"
+)
+
+// SongFilterFields are the song fields that listings and exports can be
+// filtered by, mapped to their columns.
+var SongFilterFields = map[string]string{
+	"group":        `"group"`,
+	"song":         "song",
+	"release_date": "release_date",
+	"text":         "text",
+	"link":         "link",
+}
+
+// songFilterConditions turns a filter into equality conditions on songs that
+// are not in the trash. Keys that are not filter fields are ignored.
+func songFilterConditions(filter map[string]string) ([]string, []interface{}) {
+	fields := make([]string, 0, len(filter))
+	for field := range filter {
+		if _, ok := SongFilterFields[field]; ok {
+			fields = append(fields, field)
+		}
+	}
+	sort.Strings(fields)
+
+	conditions := []string{"deleted_at IS NULL"}
+	args := make([]interface{}, 0, len(fields))
+	for i, field := range fields {
+		conditions = append(conditions, fmt.Sprintf("%s = $%d", SongFilterFields[field], i+1))
+		args = append(args, filter[field])
+	}
+	return conditions, args
+}
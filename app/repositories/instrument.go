@@ -0,0 +1,62 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/lmd1e/song_library/app/metrics"
+	"github.com/lmd1e/song_library/app/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// startCall begins a repository method: it starts a span for the call and
+// applies the query timeout. The returned function, to be deferred with the
+// method's error, releases the timeout, ends the span and records the
+// duration of the call. The statements run by the method appear as child
+// spans carrying their SQL.
+func startCall(ctx context.Context, timeout time.Duration, repository, method string) (context.Context, func(*error)) {
+	start := time.Now()
+	ctx, span := tracing.Tracer().Start(ctx, repository+"."+method,
+		trace.WithSpanKind(trace.SpanKindInternal),
+		trace.WithAttributes(
+			semconv.DBSystemPostgreSQL,
+			semconv.CodeFunction(method),
+			attribute.String("code.namespace", repository),
+		))
+	ctx, done := withQueryTimeout(ctx, timeout)
+	return ctx, func(err *error) {
+		done(err)
+		outcome := callOutcome(*err)
+		if *err != nil {
+			span.RecordError(*err)
+			if outcome != metrics.OutcomeOK {
+				span.SetStatus(codes.Error, (*err).Error())
+			}
+		}
+		span.End()
+		metrics.ObserveRepositoryCall(repository, method, outcome, time.Since(start))
+	}
+}
+
+// callOutcome classifies the error of a call. Errors such as a missing song
+// are answers from the database rather than failures and count as ok.
+func callOutcome(err error) string {
+	switch {
+	case err == nil,
+		errors.Is(err, ErrSongNotFound),
+		errors.Is(err, ErrRevisionNotFound),
+		errors.Is(err, ErrVersionConflict),
+		errors.Is(err, ErrJobNotFound),
+		errors.Is(err, ErrJobFinished),
+		errors.Is(err, ErrJobLeaseLost):
+		return metrics.OutcomeOK
+	case errors.Is(err, ErrQueryTimeout):
+		return metrics.OutcomeTimeout
+	default:
+		return metrics.OutcomeError
+	}
+}
@@ -0,0 +1,269 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/lmd1e/song_library/app/models"
+	"github.com/lmd1e/song_library/app/utils"
+)
+
+var (
+	ErrJobNotFound  = errors.New("job not found")
+	ErrJobFinished  = errors.New("job has already finished")
+	ErrJobLeaseLost = errors.New("job is no longer held by this worker")
+)
+
+type JobRepository interface {
+	CreateJob(ctx context.Context, job models.Job) (models.Job, error)
+	GetJob(ctx context.Context, jobID int64) (models.Job, error)
+	ClaimJob(ctx context.Context, lease time.Duration) (*models.Job, error)
+	HeartbeatJob(ctx context.Context, job models.Job, lease time.Duration) (cancelRequested bool, err error)
+	UpdateJobProgress(ctx context.Context, job models.Job) (cancelRequested bool, err error)
+	FinishJob(ctx context.Context, job models.Job) error
+	RequeueJob(ctx context.Context, job models.Job) error
+	CancelJob(ctx context.Context, jobID int64) (models.Job, error)
+	PurgeFinishedJobs(ctx context.Context, finishedBefore time.Time) ([]models.Job, error)
+	// WithTx runs fn with a job and a song repository bound to the same
+	// transaction, e.g. to store a batch of an import with its checkpoint.
+	WithTx(ctx context.Context, fn func(jobs JobRepository, songs SongRepository) error) error
+}
+
+type JobRepositoryImpl struct {
+	db           DBTX
+	queryTimeout time.Duration
+}
+
+func NewJobRepository(db DBTX, queryTimeout time.Duration) *JobRepositoryImpl {
+	return &JobRepositoryImpl{db: db, queryTimeout: queryTimeout}
+}
+
+const jobColumns = `id, kind, status, params, input_path, artifact_path, artifact_name, rows_processed,
+    rows_failed, errors, error, cancel_requested, attempts, actor, request_id, created_at, started_at, finished_at`
+
+func scanJob(row interface{ Scan(...interface{}) error }, job *models.Job) error {
+	var params, jobErrors []byte
+	err := row.Scan(&job.ID, &job.Kind, &job.Status, &params, &job.InputPath, &job.ArtifactPath, &job.ArtifactName,
+		&job.RowsProcessed, &job.RowsFailed, &jobErrors, &job.Error, &job.CancelRequested, &job.Attempts,
+		&job.Actor, &job.RequestID, &job.CreatedAt, &job.StartedAt, &job.FinishedAt)
+	if err != nil {
+		return err
+	}
+	job.Errors = jobErrors
+	return json.Unmarshal(params, &job.Params)
+}
+
+func (r *JobRepositoryImpl) CreateJob(ctx context.Context, job models.Job) (_ models.Job, err error) {
+	utils.LoggerFromContext(ctx).Debug("Adding job to the database")
+	ctx, done := startCall(ctx, r.queryTimeout, "JobRepository", "CreateJob")
+	defer done(&err)
+	params, err := json.Marshal(job.Params)
+	if err != nil {
+		return job, err
+	}
+	query := `
+        INSERT INTO jobs (kind, params, input_path, actor, request_id)
+        VALUES ($1, $2, $3, $4, $5)
+        RETURNING ` + jobColumns
+	err = scanJob(r.db.QueryRowContext(ctx, query, job.Kind, params, job.InputPath, job.Actor, job.RequestID), &job)
+	if err != nil {
+		utils.LoggerFromContext(ctx).Error("Failed to add job: ", err)
+	}
+	return job, err
+}
+
+func (r *JobRepositoryImpl) GetJob(ctx context.Context, jobID int64) (_ models.Job, err error) {
+	utils.LoggerFromContext(ctx).Debug("Fetching job from the database")
+	ctx, done := startCall(ctx, r.queryTimeout, "JobRepository", "GetJob")
+	defer done(&err)
+	var job models.Job
+	err = scanJob(r.db.QueryRowContext(ctx, "SELECT "+jobColumns+" FROM jobs WHERE id = $1", jobID), &job)
+	if errors.Is(err, sql.ErrNoRows) {
+		return job, ErrJobNotFound
+	}
+	if err != nil {
+		utils.LoggerFromContext(ctx).Error("Failed to fetch job: ", err)
+	}
+	return job, err
+}
+
+// ClaimJob takes the oldest queued job, or a running job whose worker stopped
+// renewing its lease, and holds it for lease. It returns nil if there is
+// nothing to run. Every claim increments attempts, which later calls use to
+// check that the job is still held by the same worker.
+func (r *JobRepositoryImpl) ClaimJob(ctx context.Context, lease time.Duration) (_ *models.Job, err error) {
+	ctx, done := startCall(ctx, r.queryTimeout, "JobRepository", "ClaimJob")
+	defer done(&err)
+	query := `
+        UPDATE jobs
+        SET status = 'running', attempts = attempts + 1,
+            started_at = COALESCE(started_at, now()),
+            locked_until = now() + make_interval(secs => $1)
+        WHERE id = (
+            SELECT id FROM jobs
+            WHERE status = 'queued' OR (status = 'running' AND locked_until < now())
+            ORDER BY id
+            FOR UPDATE SKIP LOCKED
+            LIMIT 1
+        )
+        RETURNING ` + jobColumns
+	var job models.Job
+	err = scanJob(r.db.QueryRowContext(ctx, query, lease.Seconds()), &job)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		utils.LoggerFromContext(ctx).Error("Failed to claim job: ", err)
+		return nil, err
+	}
+	return &job, nil
+}
+
+// HeartbeatJob extends the lease of a running job and reports whether its
+// cancellation has been requested.
+func (r *JobRepositoryImpl) HeartbeatJob(ctx context.Context, job models.Job, lease time.Duration) (_ bool, err error) {
+	ctx, done := startCall(ctx, r.queryTimeout, "JobRepository", "HeartbeatJob")
+	defer done(&err)
+	query := `
+        UPDATE jobs
+        SET locked_until = now() + make_interval(secs => $3)
+        WHERE id = $1 AND attempts = $2 AND status = 'running'
+        RETURNING cancel_requested
+    `
+	var cancelRequested bool
+	err = r.db.QueryRowContext(ctx, query, job.ID, job.Attempts, lease.Seconds()).Scan(&cancelRequested)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, ErrJobLeaseLost
+	}
+	if err != nil {
+		utils.LoggerFromContext(ctx).Error("Failed to renew job lease: ", err)
+	}
+	return cancelRequested, err
+}
+
+// UpdateJobProgress saves the row counts and errors of a running job and
+// reports whether its cancellation has been requested.
+func (r *JobRepositoryImpl) UpdateJobProgress(ctx context.Context, job models.Job) (_ bool, err error) {
+	ctx, done := startCall(ctx, r.queryTimeout, "JobRepository", "UpdateJobProgress")
+	defer done(&err)
+	query := `
+        UPDATE jobs
+        SET rows_processed = $3, rows_failed = $4, errors = $5
+        WHERE id = $1 AND attempts = $2 AND status = 'running'
+        RETURNING cancel_requested
+    `
+	var cancelRequested bool
+	err = r.db.QueryRowContext(ctx, query, job.ID, job.Attempts, job.RowsProcessed, job.RowsFailed, jobErrors(job)).Scan(&cancelRequested)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, ErrJobLeaseLost
+	}
+	if err != nil {
+		utils.LoggerFromContext(ctx).Error("Failed to update job progress: ", err)
+	}
+	return cancelRequested, err
+}
+
+// FinishJob stores the final status, counts and artifact of a running job.
+func (r *JobRepositoryImpl) FinishJob(ctx context.Context, job models.Job) (err error) {
+	utils.LoggerFromContext(ctx).Debug("Finishing job in the database")
+	ctx, done := startCall(ctx, r.queryTimeout, "JobRepository", "FinishJob")
+	defer done(&err)
+	query := `
+        UPDATE jobs
+        SET status = $3, rows_processed = $4, rows_failed = $5, errors = $6, error = $7,
+            artifact_path = $8, artifact_name = $9, finished_at = now(), locked_until = NULL
+        WHERE id = $1 AND attempts = $2 AND status = 'running'
+    `
+	return r.execHeld(ctx, query, job.ID, job.Attempts, job.Status, job.RowsProcessed, job.RowsFailed,
+		jobErrors(job), job.Error, job.ArtifactPath, job.ArtifactName)
+}
+
+// RequeueJob hands a running job back to the queue, e.g. on shutdown.
+func (r *JobRepositoryImpl) RequeueJob(ctx context.Context, job models.Job) (err error) {
+	utils.LoggerFromContext(ctx).Debug("Requeueing job in the database")
+	ctx, done := startCall(ctx, r.queryTimeout, "JobRepository", "RequeueJob")
+	defer done(&err)
+	query := `
+        UPDATE jobs
+        SET status = 'queued', locked_until = NULL
+        WHERE id = $1 AND attempts = $2 AND status = 'running'
+    `
+	return r.execHeld(ctx, query, job.ID, job.Attempts)
+}
+
+// CancelJob cancels a queued job at once and asks the worker of a running job
+// to stop it.
+func (r *JobRepositoryImpl) CancelJob(ctx context.Context, jobID int64) (_ models.Job, err error) {
+	utils.LoggerFromContext(ctx).Debug("Cancelling job in the database")
+	ctx, done := startCall(ctx, r.queryTimeout, "JobRepository", "CancelJob")
+	defer done(&err)
+	query := `
+        UPDATE jobs
+        SET cancel_requested = true,
+            status = CASE WHEN status = 'queued' THEN 'cancelled' ELSE status END,
+            finished_at = CASE WHEN status = 'queued' THEN now() ELSE finished_at END
+        WHERE id = $1 AND status IN ('queued', 'running')
+        RETURNING ` + jobColumns
+	var job models.Job
+	err = scanJob(r.db.QueryRowContext(ctx, query, jobID), &job)
+	if errors.Is(err, sql.ErrNoRows) {
+		if _, err := r.GetJob(ctx, jobID); err != nil {
+			return job, err
+		}
+		return job, ErrJobFinished
+	}
+	if err != nil {
+		utils.LoggerFromContext(ctx).Error("Failed to cancel job: ", err)
+	}
+	return job, err
+}
+
+// PurgeFinishedJobs removes jobs that finished before finishedBefore and
+// returns them, so that their files can be removed as well.
+func (r *JobRepositoryImpl) PurgeFinishedJobs(ctx context.Context, finishedBefore time.Time) (_ []models.Job, err error) {
+	utils.LoggerFromContext(ctx).Debug("Purging finished jobs from the database")
+	ctx, done := startCall(ctx, r.queryTimeout, "JobRepository", "PurgeFinishedJobs")
+	defer done(&err)
+	query := `
+        DELETE FROM jobs
+        WHERE finished_at < $1
+        RETURNING ` + jobColumns
+	rows, err := r.db.QueryContext(ctx, query, finishedBefore)
+	if err != nil {
+		utils.LoggerFromContext(ctx).Error("Failed to purge jobs: ", err)
+		return nil, err
+	}
+	defer rows.Close()
+	var jobs []models.Job
+	for rows.Next() {
+		var job models.Job
+		if err := scanJob(rows, &job); err != nil {
+			utils.LoggerFromContext(ctx).Error("Failed to scan job row: ", err)
+			return nil, err
+		}
+		jobs = append(jobs, job)
+	}
+	return jobs, rows.Err()
+}
+
+func (r *JobRepositoryImpl) execHeld(ctx context.Context, query string, args ...interface{}) error {
+	result, err := r.db.ExecContext(ctx, query, args...)
+	if err != nil {
+		utils.LoggerFromContext(ctx).Error("Failed to update job: ", err)
+		return err
+	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return ErrJobLeaseLost
+	}
+	return nil
+}
+
+func jobErrors(job models.Job) []byte {
+	if len(job.Errors) == 0 {
+		return []byte("[]")
+	}
+	return job.Errors
+}
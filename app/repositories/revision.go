@@ -0,0 +1,111 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/lmd1e/song_library/app/models"
+	"github.com/lmd1e/song_library/app/utils"
+)
+
+var ErrRevisionNotFound = errors.New("revision not found")
+
+const revisionColumns = "song_id, revision, \"group\", song, release_date, text, link, timings, actor, created_at"
+
+func scanRevision(row interface{ Scan(...interface{}) error }, revision *models.SongRevision) error {
+	return row.Scan(&revision.SongID, &revision.Revision, &revision.Group, &revision.Song,
+		&revision.ReleaseDate, &revision.Text, &revision.Link, &revision.Timings, &revision.Actor, &revision.CreatedAt)
+}
+
+func (r *SongRepositoryImpl) GetSongRevisions(ctx context.Context, songID, limit, offset int) (_ []models.SongRevision, err error) {
+	utils.LoggerFromContext(ctx).Debug("Fetching song revisions from the database")
+	ctx, done := startCall(ctx, r.queryTimeout, "SongRepository", "GetSongRevisions")
+	defer done(&err)
+	query := "SELECT " + revisionColumns + " FROM song_revisions WHERE song_id = $1 ORDER BY revision DESC LIMIT $2 OFFSET $3"
+	rows, err := r.db.QueryContext(ctx, query, songID, limit, offset)
+	if err != nil {
+		utils.LoggerFromContext(ctx).Error("Failed to fetch song revisions: ", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	revisions := []models.SongRevision{}
+	for rows.Next() {
+		var revision models.SongRevision
+		if err := scanRevision(rows, &revision); err != nil {
+			utils.LoggerFromContext(ctx).Error("Failed to scan revision row: ", err)
+			return nil, err
+		}
+		revisions = append(revisions, revision)
+	}
+	return revisions, rows.Err()
+}
+
+func (r *SongRepositoryImpl) GetSongRevision(ctx context.Context, songID, revision int) (_ models.SongRevision, err error) {
+	utils.LoggerFromContext(ctx).Debug("Fetching song revision from the database")
+	ctx, done := startCall(ctx, r.queryTimeout, "SongRepository", "GetSongRevision")
+	defer done(&err)
+	return getRevision(ctx, r.db, songID, revision)
+}
+
+// RestoreSongRevision overwrites a song with the content of one of its
+// revisions, including the timings of synced lyrics. The restore itself is
+// recorded as a new revision.
+func (r *SongRepositoryImpl) RestoreSongRevision(ctx context.Context, songID, revision int, meta models.AuditMeta) (_ models.Song, err error) {
+	utils.LoggerFromContext(ctx).Debug("Restoring song revision in the database")
+	ctx, done := startCall(ctx, r.queryTimeout, "SongRepository", "RestoreSongRevision")
+	defer done(&err)
+	var restored models.Song
+	err = r.inTx(ctx, func(tx *sql.Tx) error {
+		before, err := getSongForUpdate(ctx, tx, songID)
+		if err != nil {
+			return err
+		}
+		rev, err := getRevision(ctx, tx, songID, revision)
+		if err != nil {
+			return err
+		}
+		restored = models.Song{
+			ID:          songID,
+			Group:       rev.Group,
+			Song:        rev.Song,
+			ReleaseDate: rev.ReleaseDate,
+			Text:        rev.Text,
+			Link:        rev.Link,
+			Timings:     rev.Timings,
+		}
+		return saveSong(ctx, tx, &restored, before, models.AuditActionRestore, meta)
+	})
+	return restored, err
+}
+
+func getRevision(ctx context.Context, db DBTX, songID, revision int) (models.SongRevision, error) {
+	query := "SELECT " + revisionColumns + " FROM song_revisions WHERE song_id = $1 AND revision = $2"
+	var rev models.SongRevision
+	err := scanRevision(db.QueryRowContext(ctx, query, songID, revision), &rev)
+	if errors.Is(err, sql.ErrNoRows) {
+		return rev, ErrRevisionNotFound
+	}
+	if err != nil {
+		utils.LoggerFromContext(ctx).Error("Failed to fetch song revision: ", err)
+	}
+	return rev, err
+}
+
+// insertRevision stores the current state of song as its next revision.
+// Callers must hold the row lock on the song.
+func insertRevision(ctx context.Context, tx *sql.Tx, song models.Song, meta models.AuditMeta) error {
+	query := `
+        INSERT INTO song_revisions (song_id, revision, "group", song, release_date, text, link, timings, actor)
+        SELECT $1, COALESCE(MAX(revision), 0) + 1, $2, $3, $4, $5, $6, $7, $8
+        FROM song_revisions
+        WHERE song_id = $1
+    `
+	_, err := tx.ExecContext(ctx, query, song.ID, song.Group, song.Song, song.ReleaseDate, song.Text, song.Link,
+		song.Timings, meta.Actor)
+	if err != nil {
+		utils.LoggerFromContext(ctx).Error("Failed to write song revision: ", err)
+	}
+	return err
+}
@@ -1,51 +1,66 @@
 package repositories
 
 import (
+	"context"
 	"database/sql"
+	"errors"
 	"fmt"
 	"strings"
+	"time"
 
 	"github.com/lmd1e/song_library/app/models"
 	"github.com/lmd1e/song_library/app/utils"
 )
 
+var (
+	ErrSongNotFound    = errors.New("song not found")
+	ErrVersionConflict = errors.New("song version does not match")
+)
+
 type SongRepository interface {
-	GetSongs(filter map[string]string, limit, offset int) ([]models.Song, error)
-	GetSongText(songID, limit, offset int) (string, error)
-	DeleteSong(songID int) error
-	UpdateSong(song models.Song) error
-	AddSong(song models.Song) error
+	GetSongs(ctx context.Context, filter map[string]string, limit, offset int) ([]models.Song, error)
+	GetSong(ctx context.Context, songID int) (models.Song, error)
+	CountSongs(ctx context.Context) (int, error)
+	GetSongText(ctx context.Context, songID, limit, offset int) (models.Lyrics, error)
+	GetSongSections(ctx context.Context, songID int) (models.LyricStructure, error)
+	DeleteSong(ctx context.Context, songID, expectedVersion int, meta models.AuditMeta) error
+	UpdateSong(ctx context.Context, song models.Song, meta models.AuditMeta) (models.Song, error)
+	PatchSong(ctx context.Context, songID int, patch models.SongPatch, meta models.AuditMeta) (models.Song, error)
+	AddSong(ctx context.Context, song models.Song, meta models.AuditMeta) (models.Song, error)
+	AddSongs(ctx context.Context, songs []models.Song, meta models.AuditMeta) ([]models.Song, error)
+	ExportSongs(ctx context.Context, filter map[string]string, fn func(models.Song) error) error
+	GetSongRevisions(ctx context.Context, songID, limit, offset int) ([]models.SongRevision, error)
+	GetSongRevision(ctx context.Context, songID, revision int) (models.SongRevision, error)
+	RestoreSongRevision(ctx context.Context, songID, revision int, meta models.AuditMeta) (models.Song, error)
+	GetDeletedSongs(ctx context.Context, limit, offset int) ([]models.Song, error)
+	RestoreSong(ctx context.Context, songID int, meta models.AuditMeta) (models.Song, error)
+	PurgeDeletedSongs(ctx context.Context, deletedBefore time.Time, meta models.AuditMeta) (int, error)
+	WithTx(ctx context.Context, fn func(repo SongRepository) error) error
 }
 
 type SongRepositoryImpl struct {
-	db *sql.DB
+	db           DBTX
+	queryTimeout time.Duration
 }
 
-func NewSongRepository(db *sql.DB) *SongRepositoryImpl {
-	return &SongRepositoryImpl{db: db}
+// NewSongRepository returns a repository on a database or, given a *sql.Tx,
+// one whose methods all run in that transaction. Each method call is limited
+// to queryTimeout; zero means no limit.
+func NewSongRepository(db DBTX, queryTimeout time.Duration) *SongRepositoryImpl {
+	return &SongRepositoryImpl{db: db, queryTimeout: queryTimeout}
 }
 
-func (r *SongRepositoryImpl) GetSongs(filter map[string]string, limit, offset int) ([]models.Song, error) {
-	utils.Logger.Info("Fetching songs from the database")
-	query := "SELECT id, \"group\", song, release_date, text, link FROM songs"
-	var conditions []string
-	var args []interface{}
-	i := 1
-	for k, v := range filter {
-		if k == "group" {
-			k = `"group"`
-		}
-		conditions = append(conditions, fmt.Sprintf("%s = $%d", k, i))
-		args = append(args, v)
-		i++
-	}
-	if len(conditions) > 0 {
-		query += " WHERE " + strings.Join(conditions, " AND ")
-	}
+func (r *SongRepositoryImpl) GetSongs(ctx context.Context, filter map[string]string, limit, offset int) (_ []models.Song, err error) {
+	utils.LoggerFromContext(ctx).Debug("Fetching songs from the database")
+	ctx, done := startCall(ctx, r.queryTimeout, "SongRepository", "GetSongs")
+	defer done(&err)
+	query := "SELECT " + songColumns + " FROM songs"
+	conditions, args := songFilterConditions(filter)
+	query += " WHERE " + strings.Join(conditions, " AND ")
 	query += fmt.Sprintf(" LIMIT %d OFFSET %d", limit, offset)
-	rows, err := r.db.Query(query, args...)
+	rows, err := r.db.QueryContext(ctx, query, args...)
 	if err != nil {
-		utils.Logger.Error("Failed to fetch songs: ", err)
+		utils.LoggerFromContext(ctx).Error("Failed to fetch songs: ", err)
 		return nil, err
 	}
 	defer rows.Close()
@@ -53,8 +68,8 @@ func (r *SongRepositoryImpl) GetSongs(filter map[string]string, limit, offset in
 	var songs []models.Song
 	for rows.Next() {
 		var song models.Song
-		if err := rows.Scan(&song.ID, &song.Group, &song.Song, &song.ReleaseDate, &song.Text, &song.Link); err != nil {
-			utils.Logger.Error("Failed to scan song row: ", err)
+		if err := scanSong(rows, &song); err != nil {
+			utils.LoggerFromContext(ctx).Error("Failed to scan song row: ", err)
 			return nil, err
 		}
 		songs = append(songs, song)
@@ -62,60 +77,321 @@ func (r *SongRepositoryImpl) GetSongs(filter map[string]string, limit, offset in
 	return songs, nil
 }
 
-func (r *SongRepositoryImpl) GetSongText(songID, limit, offset int) (string, error) {
-	utils.Logger.Info("Fetching song text from the database")
-	query := "SELECT text FROM songs WHERE id = $1"
+// GetSongText returns limit lines of the text of a song starting at offset,
+// with their timings when the song has synced lyrics.
+func (r *SongRepositoryImpl) GetSongText(ctx context.Context, songID, limit, offset int) (_ models.Lyrics, err error) {
+	utils.LoggerFromContext(ctx).Debug("Fetching song text from the database")
+	ctx, done := startCall(ctx, r.queryTimeout, "SongRepository", "GetSongText")
+	defer done(&err)
+	query := "SELECT text, timings FROM songs WHERE id = $1 AND deleted_at IS NULL"
 	var text string
-	err := r.db.QueryRow(query, songID).Scan(&text)
+	var timings models.LyricTimings
+	err = r.db.QueryRowContext(ctx, query, songID).Scan(&text, &timings)
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.Lyrics{}, ErrSongNotFound
+	}
 	if err != nil {
-		utils.Logger.Error("Failed to fetch song text: ", err)
-		return "", err
+		utils.LoggerFromContext(ctx).Error("Failed to fetch song text: ", err)
+		return models.Lyrics{}, err
 	}
+	return models.NewLyrics(text, timings).Page(offset, limit), nil
+}
 
-	lines := strings.Split(text, "\n")
-	if offset >= len(lines) {
-		return "", nil
+// GetSongSections returns the lines of the text of a song with its
+// sections. Sections of songs written before they were stored are parsed
+// from the text.
+func (r *SongRepositoryImpl) GetSongSections(ctx context.Context, songID int) (_ models.LyricStructure, err error) {
+	utils.LoggerFromContext(ctx).Debug("Fetching song sections from the database")
+	ctx, done := startCall(ctx, r.queryTimeout, "SongRepository", "GetSongSections")
+	defer done(&err)
+	query := "SELECT text, sections FROM songs WHERE id = $1 AND deleted_at IS NULL"
+	var text string
+	var sections models.LyricSections
+	err = r.db.QueryRowContext(ctx, query, songID).Scan(&text, &sections)
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.LyricStructure{}, ErrSongNotFound
 	}
-	end := offset + limit
-	if end > len(lines) {
-		end = len(lines)
+	if err != nil {
+		utils.LoggerFromContext(ctx).Error("Failed to fetch song sections: ", err)
+		return models.LyricStructure{}, err
 	}
-	return strings.Join(lines[offset:end], "\n"), nil
+	if sections == nil {
+		sections = models.ParseLyricSections(text)
+	}
+	return models.LyricStructure{Lines: strings.Split(text, "\n"), Sections: sections}, nil
+}
+
+func (r *SongRepositoryImpl) GetSong(ctx context.Context, songID int) (_ models.Song, err error) {
+	utils.LoggerFromContext(ctx).Debug("Fetching song from the database")
+	ctx, done := startCall(ctx, r.queryTimeout, "SongRepository", "GetSong")
+	defer done(&err)
+	query := "SELECT " + songColumns + " FROM songs WHERE id = $1 AND deleted_at IS NULL"
+	var song models.Song
+	err = scanSong(r.db.QueryRowContext(ctx, query, songID), &song)
+	if errors.Is(err, sql.ErrNoRows) {
+		return song, ErrSongNotFound
+	}
+	if err != nil {
+		utils.LoggerFromContext(ctx).Error("Failed to fetch song: ", err)
+	}
+	return song, err
+}
+
+// CountSongs returns the number of songs, not counting the trash.
+func (r *SongRepositoryImpl) CountSongs(ctx context.Context) (_ int, err error) {
+	ctx, done := startCall(ctx, r.queryTimeout, "SongRepository", "CountSongs")
+	defer done(&err)
+	var count int
+	err = r.db.QueryRowContext(ctx, "SELECT count(*) FROM songs WHERE deleted_at IS NULL").Scan(&count)
+	if err != nil {
+		utils.LoggerFromContext(ctx).Error("Failed to count songs: ", err)
+	}
+	return count, err
+}
+
+// DeleteSong moves a song to the trash. Trashed songs are hidden from reads
+// until they are restored or purged. A non-zero expectedVersion must match
+// the current version of the song.
+func (r *SongRepositoryImpl) DeleteSong(ctx context.Context, songID, expectedVersion int, meta models.AuditMeta) (err error) {
+	utils.LoggerFromContext(ctx).Debug("Deleting song from the database")
+	ctx, done := startCall(ctx, r.queryTimeout, "SongRepository", "DeleteSong")
+	defer done(&err)
+	return r.inTx(ctx, func(tx *sql.Tx) error {
+		before, err := getSongForUpdate(ctx, tx, songID)
+		if err != nil {
+			return err
+		}
+		if err := checkVersion(before, expectedVersion); err != nil {
+			return err
+		}
+		after := *before
+		query := `
+            UPDATE songs
+            SET deleted_at = now(), version = version + 1, updated_at = now()
+            WHERE id = $1
+            RETURNING deleted_at, version, updated_at
+        `
+		if err := tx.QueryRowContext(ctx, query, songID).Scan(&after.DeletedAt, &after.Version, &after.UpdatedAt); err != nil {
+			utils.LoggerFromContext(ctx).Error("Failed to delete song: ", err)
+			return err
+		}
+		return insertAuditEntry(ctx, tx, songID, models.AuditActionDelete, meta, before, &after)
+	})
+}
+
+// UpdateSong replaces the data of a song. A non-zero song.Version must match
+// the current version of the song.
+func (r *SongRepositoryImpl) UpdateSong(ctx context.Context, song models.Song, meta models.AuditMeta) (_ models.Song, err error) {
+	utils.LoggerFromContext(ctx).Debug("Updating song in the database")
+	ctx, done := startCall(ctx, r.queryTimeout, "SongRepository", "UpdateSong")
+	defer done(&err)
+	err = r.inTx(ctx, func(tx *sql.Tx) error {
+		before, err := getSongForUpdate(ctx, tx, song.ID)
+		if err != nil {
+			return err
+		}
+		if err := checkVersion(before, song.Version); err != nil {
+			return err
+		}
+		return saveSong(ctx, tx, &song, before, models.AuditActionUpdate, meta)
+	})
+	return song, err
+}
+
+// PatchSong updates the fields of a song that are set in patch. A non-zero
+// patch.Version must match the current version of the song.
+func (r *SongRepositoryImpl) PatchSong(ctx context.Context, songID int, patch models.SongPatch, meta models.AuditMeta) (_ models.Song, err error) {
+	utils.LoggerFromContext(ctx).Debug("Patching song in the database")
+	ctx, done := startCall(ctx, r.queryTimeout, "SongRepository", "PatchSong")
+	defer done(&err)
+	var song models.Song
+	err = r.inTx(ctx, func(tx *sql.Tx) error {
+		before, err := getSongForUpdate(ctx, tx, songID)
+		if err != nil {
+			return err
+		}
+		if err := checkVersion(before, patch.Version); err != nil {
+			return err
+		}
+		song = patch.Apply(*before)
+		return saveSong(ctx, tx, &song, before, models.AuditActionUpdate, meta)
+	})
+	return song, err
+}
+
+func (r *SongRepositoryImpl) AddSong(ctx context.Context, song models.Song, meta models.AuditMeta) (_ models.Song, err error) {
+	utils.LoggerFromContext(ctx).Debug("Adding song to the database")
+	ctx, done := startCall(ctx, r.queryTimeout, "SongRepository", "AddSong")
+	defer done(&err)
+	err = r.inTx(ctx, func(tx *sql.Tx) error {
+		query := `
+            INSERT INTO songs ("group", song, release_date, text, link, sections)
+            VALUES ($1, $2, $3, $4, $5, $6)
+            RETURNING id, version, updated_at
+        `
+		err := tx.QueryRowContext(ctx, query, song.Group, song.Song, song.ReleaseDate, song.Text, song.Link, models.ParseLyricSections(song.Text)).
+			Scan(&song.ID, &song.Version, &song.UpdatedAt)
+		if err != nil {
+			utils.LoggerFromContext(ctx).Error("Failed to add song: ", err)
+			return err
+		}
+		if err := insertRevision(ctx, tx, song, meta); err != nil {
+			return err
+		}
+		return insertAuditEntry(ctx, tx, song.ID, models.AuditActionCreate, meta, nil, &song)
+	})
+	return song, err
 }
 
-func (r *SongRepositoryImpl) DeleteSong(songID int) error {
-	utils.Logger.Info("Deleting song from the database")
-	query := "DELETE FROM songs WHERE id = $1"
-	_, err := r.db.Exec(query, songID)
+// AddSongs inserts songs with multi-row statements in a single transaction,
+// recording their first revisions and audit entries alongside.
+func (r *SongRepositoryImpl) AddSongs(ctx context.Context, songs []models.Song, meta models.AuditMeta) (_ []models.Song, err error) {
+	utils.LoggerFromContext(ctx).Debug("Adding a batch of songs to the database")
+	ctx, done := startCall(ctx, r.queryTimeout, "SongRepository", "AddSongs")
+	defer done(&err)
+	if len(songs) == 0 {
+		return nil, nil
+	}
+	var added []models.Song
+	err = r.inTx(ctx, func(tx *sql.Tx) error {
+		args := make([]interface{}, 0, len(songs)*6)
+		for _, song := range songs {
+			args = append(args, song.Group, song.Song, song.ReleaseDate, song.Text, song.Link, models.ParseLyricSections(song.Text))
+		}
+		query := `INSERT INTO songs ("group", song, release_date, text, link, sections) VALUES ` +
+			valuesPlaceholders(len(songs), 6) + " RETURNING " + songColumns
+		rows, err := tx.QueryContext(ctx, query, args...)
+		if err != nil {
+			utils.LoggerFromContext(ctx).Error("Failed to add songs: ", err)
+			return err
+		}
+		added = make([]models.Song, 0, len(songs))
+		for rows.Next() {
+			var song models.Song
+			if err := scanSong(rows, &song); err != nil {
+				rows.Close()
+				utils.LoggerFromContext(ctx).Error("Failed to scan song row: ", err)
+				return err
+			}
+			added = append(added, song)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+
+		revisionArgs := make([]interface{}, 0, len(added)*7)
+		auditArgs := make([]interface{}, 0, len(added)*5)
+		for i := range added {
+			song := &added[i]
+			snapshot, err := songSnapshot(song)
+			if err != nil {
+				return err
+			}
+			revisionArgs = append(revisionArgs, song.ID, song.Group, song.Song, song.ReleaseDate, song.Text, song.Link, meta.Actor)
+			auditArgs = append(auditArgs, song.ID, models.AuditActionCreate, meta.Actor, meta.RequestID, snapshot)
+		}
+		query = `INSERT INTO song_revisions (song_id, "group", song, release_date, text, link, actor, revision) VALUES ` +
+			valuesPlaceholders(len(added), 7, "1")
+		if _, err := tx.ExecContext(ctx, query, revisionArgs...); err != nil {
+			utils.LoggerFromContext(ctx).Error("Failed to write song revisions: ", err)
+			return err
+		}
+		query = "INSERT INTO audit_log (song_id, action, actor, request_id, after) VALUES " +
+			valuesPlaceholders(len(added), 5)
+		if _, err := tx.ExecContext(ctx, query, auditArgs...); err != nil {
+			utils.LoggerFromContext(ctx).Error("Failed to write audit entries: ", err)
+			return err
+		}
+		return nil
+	})
 	if err != nil {
-		utils.Logger.Error("Failed to delete song: ", err)
+		return nil, err
 	}
-	return err
+	return added, nil
 }
 
-func (r *SongRepositoryImpl) UpdateSong(song models.Song) error {
-	utils.Logger.Info("Updating song in the database")
+// valuesPlaceholders builds the VALUES list of a multi-row insert with
+// columns parameters per row, followed by the given literal values.
+func valuesPlaceholders(rows, columns int, literals ...string) string {
+	var sb strings.Builder
+	for i := 0; i < rows; i++ {
+		if i > 0 {
+			sb.WriteString(", ")
+		}
+		sb.WriteString("(")
+		for j := 0; j < columns; j++ {
+			if j > 0 {
+				sb.WriteString(", ")
+			}
+			fmt.Fprintf(&sb, "$%d", i*columns+j+1)
+		}
+		for _, literal := range literals {
+			sb.WriteString(", " + literal)
+		}
+		sb.WriteString(")")
+	}
+	return sb.String()
+}
+
+// saveSong writes the data of a locked song with the sections of its text,
+// bumps its version and records the change as a new revision and audit
+// entry. Timings of synced lyrics are kept when the text is unchanged and
+// song.Timings is nil.
+func saveSong(ctx context.Context, tx *sql.Tx, song, before *models.Song, action string, meta models.AuditMeta) error {
+	if song.Timings == nil && song.Text == before.Text {
+		song.Timings = before.Timings
+	}
 	query := `
         UPDATE songs
-        SET "group" = $1, song = $2, release_date = $3, text = $4, link = $5
-        WHERE id = $6
+        SET "group" = $1, song = $2, release_date = $3, text = $4, link = $5, timings = $6, sections = $7,
+            version = version + 1, updated_at = now()
+        WHERE id = $8
+        RETURNING version, updated_at
     `
-	_, err := r.db.Exec(query, song.Group, song.Song, song.ReleaseDate, song.Text, song.Link, song.ID)
+	err := tx.QueryRowContext(ctx, query, song.Group, song.Song, song.ReleaseDate, song.Text, song.Link, song.Timings,
+		models.ParseLyricSections(song.Text), song.ID).Scan(&song.Version, &song.UpdatedAt)
 	if err != nil {
-		utils.Logger.Error("Failed to update song: ", err)
+		utils.LoggerFromContext(ctx).Error("Failed to update song: ", err)
+		return err
+	}
+	song.DeletedAt = nil
+	if err := insertRevision(ctx, tx, *song, meta); err != nil {
+		return err
 	}
-	return err
+	return insertAuditEntry(ctx, tx, song.ID, action, meta, before, song)
 }
 
-func (r *SongRepositoryImpl) AddSong(song models.Song) error {
-	utils.Logger.Info("Adding song to the database")
-	query := `
-        INSERT INTO songs ("group", song, release_date, text, link)
-        VALUES ($1, $2, $3, $4, $5)
-    `
-	_, err := r.db.Exec(query, song.Group, song.Song, song.ReleaseDate, song.Text, song.Link)
+func checkVersion(song *models.Song, expectedVersion int) error {
+	if expectedVersion != 0 && song.Version != expectedVersion {
+		return ErrVersionConflict
+	}
+	return nil
+}
+
+const songColumns = "id, \"group\", song, release_date, text, link, timings, deleted_at, version, updated_at"
+
+func scanSong(row interface{ Scan(...interface{}) error }, song *models.Song) error {
+	return row.Scan(&song.ID, &song.Group, &song.Song, &song.ReleaseDate, &song.Text, &song.Link,
+		&song.Timings, &song.DeletedAt, &song.Version, &song.UpdatedAt)
+}
+
+// getSongForUpdate loads a song that is not in the trash and locks its row
+// until the end of tx.
+func getSongForUpdate(ctx context.Context, tx *sql.Tx, songID int) (*models.Song, error) {
+	return lockSong(ctx, tx, "id = $1 AND deleted_at IS NULL", songID)
+}
+
+func lockSong(ctx context.Context, tx *sql.Tx, condition string, songID int) (*models.Song, error) {
+	query := "SELECT " + songColumns + " FROM songs WHERE " + condition + " FOR UPDATE"
+	var song models.Song
+	err := scanSong(tx.QueryRowContext(ctx, query, songID), &song)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrSongNotFound
+	}
 	if err != nil {
-		utils.Logger.Error("Failed to add song: ", err)
+		utils.LoggerFromContext(ctx).Error("Failed to fetch song: ", err)
+		return nil, err
 	}
-	return err
+	return &song, nil
 }
@@ -0,0 +1,27 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrQueryTimeout is returned when a repository call runs longer than its
+// query timeout.
+var ErrQueryTimeout = errors.New("database query timed out")
+
+// withQueryTimeout bounds ctx by timeout. The returned function releases the
+// context and replaces an error caused by the timeout with ErrQueryTimeout.
+func withQueryTimeout(ctx context.Context, timeout time.Duration) (context.Context, func(*error)) {
+	if timeout <= 0 {
+		return ctx, func(*error) {}
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	return ctx, func(err *error) {
+		if *err != nil && !errors.Is(*err, ErrQueryTimeout) && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			*err = fmt.Errorf("%w: %w", ErrQueryTimeout, *err)
+		}
+		cancel()
+	}
+}
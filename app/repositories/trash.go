@@ -0,0 +1,125 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/lmd1e/song_library/app/models"
+	"github.com/lmd1e/song_library/app/utils"
+)
+
+func (r *SongRepositoryImpl) GetDeletedSongs(ctx context.Context, limit, offset int) (_ []models.Song, err error) {
+	utils.LoggerFromContext(ctx).Debug("Fetching deleted songs from the database")
+	ctx, done := startCall(ctx, r.queryTimeout, "SongRepository", "GetDeletedSongs")
+	defer done(&err)
+	query := `
+        SELECT ` + songColumns + `
+        FROM songs
+        WHERE deleted_at IS NOT NULL
+        ORDER BY deleted_at DESC
+        LIMIT $1 OFFSET $2
+    `
+	rows, err := r.db.QueryContext(ctx, query, limit, offset)
+	if err != nil {
+		utils.LoggerFromContext(ctx).Error("Failed to fetch deleted songs: ", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	songs := []models.Song{}
+	for rows.Next() {
+		var song models.Song
+		if err := scanSong(rows, &song); err != nil {
+			utils.LoggerFromContext(ctx).Error("Failed to scan song row: ", err)
+			return nil, err
+		}
+		songs = append(songs, song)
+	}
+	return songs, rows.Err()
+}
+
+// RestoreSong takes a song out of the trash.
+func (r *SongRepositoryImpl) RestoreSong(ctx context.Context, songID int, meta models.AuditMeta) (_ models.Song, err error) {
+	utils.LoggerFromContext(ctx).Debug("Restoring deleted song in the database")
+	ctx, done := startCall(ctx, r.queryTimeout, "SongRepository", "RestoreSong")
+	defer done(&err)
+	var restored models.Song
+	err = r.inTx(ctx, func(tx *sql.Tx) error {
+		before, err := lockSong(ctx, tx, "id = $1 AND deleted_at IS NOT NULL", songID)
+		if err != nil {
+			return err
+		}
+		restored = *before
+		restored.DeletedAt = nil
+		query := `
+            UPDATE songs
+            SET deleted_at = NULL, version = version + 1, updated_at = now()
+            WHERE id = $1
+            RETURNING version, updated_at
+        `
+		if err := tx.QueryRowContext(ctx, query, songID).Scan(&restored.Version, &restored.UpdatedAt); err != nil {
+			utils.LoggerFromContext(ctx).Error("Failed to restore song: ", err)
+			return err
+		}
+		return insertAuditEntry(ctx, tx, songID, models.AuditActionUndelete, meta, before, &restored)
+	})
+	return restored, err
+}
+
+// PurgeDeletedSongs permanently removes songs that were moved to the trash
+// before deletedBefore and returns how many were removed.
+func (r *SongRepositoryImpl) PurgeDeletedSongs(ctx context.Context, deletedBefore time.Time, meta models.AuditMeta) (_ int, err error) {
+	utils.LoggerFromContext(ctx).Debug("Purging expired songs from the trash")
+	ctx, done := startCall(ctx, r.queryTimeout, "SongRepository", "PurgeDeletedSongs")
+	defer done(&err)
+	var purged int
+	err = r.inTx(ctx, func(tx *sql.Tx) error {
+		query := `
+            SELECT ` + songColumns + `
+            FROM songs
+            WHERE deleted_at < $1
+            FOR UPDATE
+        `
+		rows, err := tx.QueryContext(ctx, query, deletedBefore)
+		if err != nil {
+			utils.LoggerFromContext(ctx).Error("Failed to fetch expired songs: ", err)
+			return err
+		}
+		var songs []models.Song
+		for rows.Next() {
+			var song models.Song
+			if err := scanSong(rows, &song); err != nil {
+				rows.Close()
+				utils.LoggerFromContext(ctx).Error("Failed to scan song row: ", err)
+				return err
+			}
+			songs = append(songs, song)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+
+		for i := range songs {
+			if err := purgeSong(ctx, tx, &songs[i], meta); err != nil {
+				return err
+			}
+		}
+		purged = len(songs)
+		return nil
+	})
+	return purged, err
+}
+
+func purgeSong(ctx context.Context, tx *sql.Tx, song *models.Song, meta models.AuditMeta) error {
+	if _, err := tx.ExecContext(ctx, "DELETE FROM song_revisions WHERE song_id = $1", song.ID); err != nil {
+		utils.LoggerFromContext(ctx).Error("Failed to purge song revisions: ", err)
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, "DELETE FROM songs WHERE id = $1", song.ID); err != nil {
+		utils.LoggerFromContext(ctx).Error("Failed to purge song: ", err)
+		return err
+	}
+	return insertAuditEntry(ctx, tx, song.ID, models.AuditActionPurge, meta, song, nil)
+}
@@ -0,0 +1,97 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sync/atomic"
+
+	"github.com/lmd1e/song_library/app/utils"
+)
+
+// DBTX is implemented by both *sql.DB and *sql.Tx, so repositories can run
+// on their own or as part of a transaction.
+type DBTX interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+var savepointSeq atomic.Int64
+
+// WithTx runs fn in a transaction on db. If db is already a transaction, fn
+// runs in a savepoint of it instead, so units of work nest. The transaction
+// or savepoint is committed if fn returns nil and rolled back if fn returns
+// an error or panics; the panic is then raised again.
+func WithTx(ctx context.Context, db DBTX, fn func(tx *sql.Tx) error) (err error) {
+	switch db := db.(type) {
+	case *sql.DB:
+		tx, err := db.BeginTx(ctx, nil)
+		if err != nil {
+			utils.LoggerFromContext(ctx).Error("Failed to begin transaction: ", err)
+			return err
+		}
+		defer func() {
+			if p := recover(); p != nil {
+				tx.Rollback()
+				panic(p)
+			}
+		}()
+		if err := fn(tx); err != nil {
+			tx.Rollback()
+			return err
+		}
+		return tx.Commit()
+	case *sql.Tx:
+		return withSavepoint(ctx, db, fn)
+	default:
+		return fmt.Errorf("unsupported database handle %T", db)
+	}
+}
+
+func withSavepoint(ctx context.Context, tx *sql.Tx, fn func(tx *sql.Tx) error) error {
+	name := fmt.Sprintf("sp_%d", savepointSeq.Add(1))
+	if _, err := tx.ExecContext(ctx, "SAVEPOINT "+name); err != nil {
+		utils.LoggerFromContext(ctx).Error("Failed to create savepoint: ", err)
+		return err
+	}
+	rollback := func() {
+		if _, err := tx.ExecContext(context.WithoutCancel(ctx), "ROLLBACK TO SAVEPOINT "+name); err != nil {
+			utils.LoggerFromContext(ctx).Error("Failed to roll back to savepoint: ", err)
+		}
+	}
+	defer func() {
+		if p := recover(); p != nil {
+			rollback()
+			panic(p)
+		}
+	}()
+	if err := fn(tx); err != nil {
+		rollback()
+		return err
+	}
+	_, err := tx.ExecContext(ctx, "RELEASE SAVEPOINT "+name)
+	return err
+}
+
+// WithTx runs fn with a repository bound to a transaction (or, inside one,
+// a savepoint); see the package-level WithTx.
+func (r *SongRepositoryImpl) WithTx(ctx context.Context, fn func(repo SongRepository) error) error {
+	return WithTx(ctx, r.db, func(tx *sql.Tx) error {
+		return fn(NewSongRepository(tx, r.queryTimeout))
+	})
+}
+
+// WithTx runs fn with repositories bound to a transaction (or, inside one, a
+// savepoint); the song repository uses the query timeout of r.
+func (r *JobRepositoryImpl) WithTx(ctx context.Context, fn func(jobs JobRepository, songs SongRepository) error) error {
+	return WithTx(ctx, r.db, func(tx *sql.Tx) error {
+		return fn(NewJobRepository(tx, r.queryTimeout), NewSongRepository(tx, r.queryTimeout))
+	})
+}
+
+// inTx runs a single write atomically: in its own transaction, or in a
+// savepoint when the repository is bound to a transaction.
+func (r *SongRepositoryImpl) inTx(ctx context.Context, fn func(tx *sql.Tx) error) error {
+	return WithTx(ctx, r.db, fn)
+}
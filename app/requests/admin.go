@@ -0,0 +1,8 @@
+package requests
+
+type LoggingSettings struct {
+	// Level is one of trace, debug, info, warn, error.
+	Level string `json:"level" example:"info"`
+	// Format is json or text.
+	Format string `json:"format" example:"json"`
+}
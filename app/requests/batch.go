@@ -0,0 +1,43 @@
+package requests
+
+const (
+	BatchModeAllOrNothing = "all_or_nothing"
+	BatchModeBestEffort   = "best_effort"
+
+	BatchOpCreate = "create"
+	BatchOpUpdate = "update"
+	BatchOpPatch  = "patch"
+	BatchOpDelete = "delete"
+)
+
+type BatchRequest struct {
+	// Mode is all_or_nothing (default) or best_effort.
+	Mode       string           `json:"mode" binding:"omitempty,oneof=all_or_nothing best_effort" example:"all_or_nothing"`
+	Operations []BatchOperation `json:"operations" binding:"required,min=1,max=1000"`
+}
+
+type BatchOperation struct {
+	Op string `json:"op" example:"update"`
+	// ID of the song to update, patch or delete.
+	ID int `json:"id,omitempty"`
+	// Version, if set, must match the current version of the song.
+	Version int               `json:"version,omitempty"`
+	Song    *SongRequest      `json:"song,omitempty"`
+	Patch   *PatchSongRequest `json:"patch,omitempty"`
+}
+
+type BatchResponse struct {
+	Mode      string                 `json:"mode"`
+	Committed bool                   `json:"committed"`
+	Results   []BatchOperationResult `json:"results"`
+}
+
+type BatchOperationResult struct {
+	Index  int           `json:"index"`
+	Op     string        `json:"op"`
+	Status int           `json:"status"`
+	Song   *SongResponse `json:"song,omitempty"`
+	// Code identifies the error, like the code of a problem response.
+	Code  string `json:"code,omitempty" example:"version_conflict"`
+	Error string `json:"error,omitempty"`
+}
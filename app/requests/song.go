@@ -1,15 +1,20 @@
 package requests
 
 import (
+	"context"
 	"encoding/json"
+	"fmt"
 	"net/http"
-	"os"
+	"net/url"
 	"time"
+
+	"github.com/lmd1e/song_library/app/metrics"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
 )
 
 type AddSongRequest struct {
-	Group string `json:"group"`
-	Song  string `json:"song"`
+	Group string `json:"group" binding:"required,max=255" maxLength:"255" example:"Muse"`
+	Song  string `json:"song" binding:"required,max=255" maxLength:"255" example:"Supermassive Black Hole"`
 }
 
 type SongDetail struct {
@@ -18,13 +23,54 @@ type SongDetail struct {
 	Link        string    `json:"link"`
 }
 
-func GetSongDetail(group, song string) (*SongDetail, error) {
-	externalAPIURL := os.Getenv("EXTERNAL_API_URL")
-	resp, err := http.Get(externalAPIURL + group + "&song=" + song)
+// SongDetailClient looks up release date, lyrics and link of a song in the
+// external music info API.
+type SongDetailClient interface {
+	GetSongDetail(ctx context.Context, group, song string) (*SongDetail, error)
+}
+
+type HTTPSongDetailClient struct {
+	baseURL string
+	client  *http.Client
+}
+
+// NewSongDetailClient returns a client whose requests are traced and carry
+// the trace context of the caller to the external API.
+func NewSongDetailClient(baseURL string) *HTTPSongDetailClient {
+	return &HTTPSongDetailClient{
+		baseURL: baseURL,
+		client: &http.Client{
+			Timeout:   10 * time.Second,
+			Transport: otelhttp.NewTransport(http.DefaultTransport),
+		},
+	}
+}
+
+func (c *HTTPSongDetailClient) GetSongDetail(ctx context.Context, group, song string) (_ *SongDetail, err error) {
+	start := time.Now()
+	defer func() { metrics.ObserveExternalCall("song_detail", err, time.Since(start)) }()
+
+	u, err := url.Parse(c.baseURL)
+	if err != nil {
+		return nil, err
+	}
+	query := u.Query()
+	query.Set("group", group)
+	query.Set("song", song)
+	u.RawQuery = query.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.client.Do(req)
 	if err != nil {
 		return nil, err
 	}
 	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("external API responded with status %d", resp.StatusCode)
+	}
 
 	var songDetail SongDetail
 	if err := json.NewDecoder(resp.Body).Decode(&songDetail); err != nil {
@@ -33,3 +79,21 @@ func GetSongDetail(group, song string) (*SongDetail, error) {
 
 	return &songDetail, nil
 }
+
+// Ping checks that the external API can be reached. Any response below 500
+// counts, as the API has no dedicated health endpoint.
+func (c *HTTPSongDetailClient) Ping(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.baseURL, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= http.StatusInternalServerError {
+		return fmt.Errorf("external API responded with status %d", resp.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,236 @@
+package requests
+
+import (
+	"encoding/xml"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lmd1e/song_library/app/models"
+	"github.com/lmd1e/song_library/app/utils"
+)
+
+// SongRequest holds the writable fields of a song, used to replace a song
+// and by the create and update operations of a batch. Identity and version
+// come from the path and the If-Match header, never from the body.
+type SongRequest struct {
+	Group       string    `json:"group" binding:"required,max=255" maxLength:"255" example:"Muse"`
+	Song        string    `json:"song" binding:"required,max=255" maxLength:"255" example:"Supermassive Black Hole"`
+	ReleaseDate time.Time `json:"release_date" binding:"required,release_date" example:"2006-07-16T00:00:00Z"`
+	Text        string    `json:"text" binding:"max=20000" maxLength:"20000"`
+	Link        string    `json:"link" binding:"max=255,song_link" maxLength:"255" example:"https://www.youtube.com/watch?v=Xsp3_a-PMTw"`
+}
+
+// ToSong maps the request to the song with the given id and expected
+// version, 0 meaning any.
+func (r SongRequest) ToSong(id, version int) models.Song {
+	return models.Song{
+		ID:          id,
+		Group:       r.Group,
+		Song:        r.Song,
+		ReleaseDate: r.ReleaseDate,
+		Text:        r.Text,
+		Link:        r.Link,
+		Version:     version,
+	}
+}
+
+// PatchSongRequest holds a partial update of a song; omitted fields are left
+// unchanged and an empty link removes it.
+type PatchSongRequest struct {
+	Group       *string    `json:"group" binding:"omitnil,min=1,max=255" maxLength:"255"`
+	Song        *string    `json:"song" binding:"omitnil,min=1,max=255" maxLength:"255"`
+	ReleaseDate *time.Time `json:"release_date" binding:"omitnil,release_date"`
+	Text        *string    `json:"text" binding:"omitnil,max=20000" maxLength:"20000"`
+	Link        *string    `json:"link" binding:"omitnil,max=255,song_link" maxLength:"255"`
+}
+
+// ToPatch maps the request to a patch expecting the given version, 0
+// meaning any.
+func (r PatchSongRequest) ToPatch(version int) models.SongPatch {
+	return models.SongPatch{
+		Group:       r.Group,
+		Song:        r.Song,
+		ReleaseDate: r.ReleaseDate,
+		Text:        r.Text,
+		Link:        r.Link,
+		Version:     version,
+	}
+}
+
+// SongResponse is a song as returned by the API.
+type SongResponse struct {
+	XMLName     xml.Name   `json:"-" xml:"song" yaml:"-"`
+	ID          int        `json:"id" xml:"id" yaml:"id" example:"1"`
+	Group       string     `json:"group" xml:"group" yaml:"group" example:"Muse"`
+	Song        string     `json:"song" xml:"song" yaml:"song" example:"Supermassive Black Hole"`
+	ReleaseDate time.Time  `json:"release_date" xml:"release_date" yaml:"release_date" example:"2006-07-16T00:00:00Z"`
+	Text        string     `json:"text" xml:"text" yaml:"text"`
+	Link        string     `json:"link" xml:"link" yaml:"link" example:"https://www.youtube.com/watch?v=Xsp3_a-PMTw"`
+	DeletedAt   *time.Time `json:"deleted_at,omitempty" xml:"deleted_at,omitempty" yaml:"deleted_at,omitempty"`
+	Version     int        `json:"version" xml:"version" yaml:"version" example:"3"`
+	UpdatedAt   time.Time  `json:"updated_at" xml:"updated_at" yaml:"updated_at"`
+}
+
+func NewSongResponse(song models.Song) SongResponse {
+	return SongResponse{
+		ID:          song.ID,
+		Group:       song.Group,
+		Song:        song.Song,
+		ReleaseDate: song.ReleaseDate,
+		Text:        song.Text,
+		Link:        song.Link,
+		DeletedAt:   song.DeletedAt,
+		Version:     song.Version,
+		UpdatedAt:   song.UpdatedAt,
+	}
+}
+
+var songCSVHeader = []string{"id", "group", "song", "release_date", "text", "link", "deleted_at", "version", "updated_at"}
+
+// MarshalCSV renders the song as a single CSV record. Release dates are
+// written as dates, as in exports.
+func (r SongResponse) MarshalCSV() ([]string, [][]string) {
+	return songCSVHeader, [][]string{r.csvRecord()}
+}
+
+func (r SongResponse) csvRecord() []string {
+	var deletedAt string
+	if r.DeletedAt != nil {
+		deletedAt = r.DeletedAt.Format(time.RFC3339)
+	}
+	return []string{
+		strconv.Itoa(r.ID),
+		r.Group,
+		r.Song,
+		r.ReleaseDate.Format(time.DateOnly),
+		r.Text,
+		r.Link,
+		deletedAt,
+		strconv.Itoa(r.Version),
+		r.UpdatedAt.Format(time.RFC3339),
+	}
+}
+
+// SongResponses is a list of songs as returned by the API.
+type SongResponses []SongResponse
+
+func NewSongResponses(songs []models.Song) SongResponses {
+	responses := make(SongResponses, len(songs))
+	for i, song := range songs {
+		responses[i] = NewSongResponse(song)
+	}
+	return responses
+}
+
+// MarshalXML wraps the songs in a <songs> root element.
+func (r SongResponses) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	start.Name.Local = "songs"
+	return e.EncodeElement(struct {
+		Songs []SongResponse `xml:"song"`
+	}{r}, start)
+}
+
+// MarshalCSV renders one CSV record per song.
+func (r SongResponses) MarshalCSV() ([]string, [][]string) {
+	records := make([][]string, len(r))
+	for i, song := range r {
+		records[i] = song.csvRecord()
+	}
+	return songCSVHeader, records
+}
+
+// SongTextResponse is a page of the lyrics of a song. Lines carries the
+// start of every line when the song has synced lyrics.
+type SongTextResponse struct {
+	XMLName xml.Name            `json:"-" xml:"song_text" yaml:"-"`
+	Text    string              `json:"text" xml:"text" yaml:"text"`
+	Lines   []LyricLineResponse `json:"lines,omitempty" xml:"lines>line,omitempty" yaml:"lines,omitempty"`
+
+	lyrics models.Lyrics
+}
+
+// LyricLineResponse is a line of synced lyrics.
+type LyricLineResponse struct {
+	StartMS int64  `json:"start_ms" xml:"start_ms" yaml:"start_ms" example:"12500"`
+	Text    string `json:"text" xml:"text" yaml:"text"`
+}
+
+func NewSongTextResponse(lyrics models.Lyrics) SongTextResponse {
+	resp := SongTextResponse{Text: lyrics.Text(), lyrics: lyrics}
+	for i, start := range lyrics.Timings {
+		resp.Lines = append(resp.Lines, LyricLineResponse{StartMS: start.Milliseconds(), Text: lyrics.Lines[i]})
+	}
+	return resp
+}
+
+// MarshalCSV renders the text as a single CSV record.
+func (r SongTextResponse) MarshalCSV() ([]string, [][]string) {
+	return []string{"text"}, [][]string{{r.Text}}
+}
+
+func (r SongTextResponse) MarshalPlainText() []byte {
+	if r.Text == "" {
+		return nil
+	}
+	return []byte(r.Text + "\n")
+}
+
+// MarshalLRC and MarshalWebVTT render synced lyrics; they are only called
+// for songs that have timings.
+func (r SongTextResponse) MarshalLRC() []byte {
+	return utils.FormatLRC(r.lyrics.Lines, r.lyrics.Timings)
+}
+
+func (r SongTextResponse) MarshalWebVTT() []byte {
+	return utils.FormatWebVTT(r.lyrics.Lines, r.lyrics.Timings, r.lyrics.End)
+}
+
+// SongSectionsResponse is the text of a song split into its sections.
+type SongSectionsResponse struct {
+	XMLName  xml.Name               `json:"-" xml:"song_sections" yaml:"-"`
+	SongID   int                    `json:"song_id" xml:"song_id" yaml:"song_id" example:"1"`
+	Sections []LyricSectionResponse `json:"sections" xml:"sections>section" yaml:"sections"`
+}
+
+// LyricSectionResponse is a section of a song's text. Repeat is set when
+// the text only names the section again and the lines are those it had
+// before.
+type LyricSectionResponse struct {
+	Type   string   `json:"type" xml:"type" yaml:"type" example:"chorus"`
+	Label  string   `json:"label,omitempty" xml:"label,omitempty" yaml:"label,omitempty" example:"Chorus"`
+	Repeat bool     `json:"repeat,omitempty" xml:"repeat,omitempty" yaml:"repeat,omitempty"`
+	Lines  []string `json:"lines" xml:"lines>line" yaml:"lines"`
+}
+
+// NewSongSectionsResponse returns the sections of a song, only those of
+// sectionType unless it is empty.
+func NewSongSectionsResponse(songID int, structure models.LyricStructure, sectionType string) SongSectionsResponse {
+	resp := SongSectionsResponse{SongID: songID, Sections: []LyricSectionResponse{}}
+	for _, section := range structure.Resolve() {
+		if sectionType != "" && section.Type != sectionType {
+			continue
+		}
+		lines := section.Lines
+		if lines == nil {
+			lines = []string{}
+		}
+		resp.Sections = append(resp.Sections, LyricSectionResponse{
+			Type:   section.Type,
+			Label:  section.Label,
+			Repeat: section.Repeat,
+			Lines:  lines,
+		})
+	}
+	return resp
+}
+
+// MarshalCSV renders one record per section with its lines joined by line
+// breaks.
+func (r SongSectionsResponse) MarshalCSV() ([]string, [][]string) {
+	records := make([][]string, len(r.Sections))
+	for i, section := range r.Sections {
+		records[i] = []string{section.Type, section.Label, strconv.FormatBool(section.Repeat), strings.Join(section.Lines, "\n")}
+	}
+	return []string{"type", "label", "repeat", "text"}, records
+}
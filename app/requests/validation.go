@@ -0,0 +1,125 @@
+package requests
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+	"github.com/lmd1e/song_library/app/utils"
+)
+
+// Release dates are accepted from the start of recorded music up to a year
+// ahead, for announced releases.
+var earliestReleaseDate = time.Date(1860, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+const releaseDateLookahead = 1
+
+// The rules are registered with gin's validator, so that request bodies,
+// import rows and song details from the external API share them.
+func init() {
+	v, ok := binding.Validator.Engine().(*validator.Validate)
+	if !ok {
+		return
+	}
+	// Field errors name fields as they appear in the JSON body.
+	v.RegisterTagNameFunc(func(field reflect.StructField) string {
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" {
+			return ""
+		}
+		if name == "" {
+			return field.Name
+		}
+		return name
+	})
+	v.RegisterValidation("release_date", func(fl validator.FieldLevel) bool {
+		date, ok := fl.Field().Interface().(time.Time)
+		return ok && validReleaseDate(date)
+	})
+	// A link is optional, so an empty one is valid.
+	v.RegisterValidation("song_link", func(fl validator.FieldLevel) bool {
+		link := fl.Field().String()
+		if link == "" {
+			return true
+		}
+		u, err := url.Parse(link)
+		return err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+	})
+}
+
+func validReleaseDate(date time.Time) bool {
+	return !date.Before(earliestReleaseDate) && !date.After(time.Now().AddDate(releaseDateLookahead, 0, 0))
+}
+
+// Validate normalises the strings of obj, a pointer, and only then checks it
+// against the binding rules of its type, so that the rules see the values
+// that will be stored. All violations are returned together as
+// validator.ValidationErrors.
+func Validate(obj any) error {
+	utils.NormalizeStrings(obj)
+	return binding.Validator.ValidateStruct(obj)
+}
+
+// FieldErrors describes the violations in an error returned by Validate, or
+// returns nil for any other error.
+func FieldErrors(err error) []utils.FieldError {
+	var validationErrs validator.ValidationErrors
+	if !errors.As(err, &validationErrs) {
+		return nil
+	}
+	fields := make([]utils.FieldError, 0, len(validationErrs))
+	for _, fe := range validationErrs {
+		fields = append(fields, utils.FieldError{
+			Field:   fieldPath(fe.Namespace()),
+			Code:    fe.Tag(),
+			Message: fieldMessage(fe),
+		})
+	}
+	return fields
+}
+
+// fieldMessage describes a failed rule in the words of the importer's row
+// errors, e.g. "is required".
+func fieldMessage(fe validator.FieldError) string {
+	isString := fe.Kind() == reflect.String
+	switch fe.Tag() {
+	case "required":
+		return "is required"
+	case "max":
+		if isString {
+			return fmt.Sprintf("must be at most %s characters long", fe.Param())
+		}
+		return fmt.Sprintf("must contain at most %s items", fe.Param())
+	case "min":
+		if isString && fe.Param() == "1" {
+			return "must not be empty"
+		}
+		if isString {
+			return fmt.Sprintf("must be at least %s characters long", fe.Param())
+		}
+		return fmt.Sprintf("must contain at least %s items", fe.Param())
+	case "oneof":
+		return "must be one of " + strings.ReplaceAll(fe.Param(), " ", ", ")
+	case "song_link":
+		return "must be an http or https URL"
+	case "release_date":
+		return fmt.Sprintf("must be between %s and %d year from now", earliestReleaseDate.Format(time.DateOnly), releaseDateLookahead)
+	default:
+		return "failed the " + fe.Tag() + " rule"
+	}
+}
+
+// fieldPath drops the name of the request type from a validator namespace
+// such as "AddSongRequest.group".
+func fieldPath(namespace string) string {
+	_, path, ok := strings.Cut(namespace, ".")
+	if !ok {
+		return namespace
+	}
+	return path
+}
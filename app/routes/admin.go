@@ -0,0 +1,22 @@
+package routes
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/lmd1e/song_library/app/controllers"
+	"github.com/lmd1e/song_library/app/middleware"
+)
+
+// RegisterAdminRoutes mounts the admin endpoints behind the admin token. They
+// can change how the service logs and show how it is deployed, so without a
+// token they are not registered at all and answer 404. It reports whether
+// the endpoints were registered.
+func RegisterAdminRoutes(router *gin.Engine, controller *controllers.AdminController, token string) bool {
+	if token == "" {
+		return false
+	}
+	admin := router.Group("/admin", middleware.RequireAdminToken(token))
+	admin.GET("/config", controller.GetConfig)
+	admin.GET("/logging", controller.GetLogging)
+	admin.PATCH("/logging", controller.UpdateLogging)
+	return true
+}
@@ -0,0 +1,26 @@
+package routes
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/lmd1e/song_library/app/controllers"
+)
+
+// APIV1Prefix is the path every version 1 route is mounted under.
+const APIV1Prefix = "/api/v1"
+
+// APIControllers are the controllers serving the public API. A new API
+// version registers its own routes next to RegisterAPIV1, reusing the
+// controllers whose contract it does not change.
+type APIControllers struct {
+	Songs *controllers.SongController
+	Audit *controllers.AuditController
+	Jobs  *controllers.JobController
+}
+
+// RegisterAPIV1 mounts version 1 of the API on the group, which is either
+// the /api/v1 group or the deprecated unversioned alias at the root.
+func RegisterAPIV1(group *gin.RouterGroup, c APIControllers) {
+	RegisterSongRoutes(group, c.Songs)
+	RegisterAuditRoutes(group, c.Audit)
+	RegisterJobRoutes(group, c.Jobs)
+}
@@ -0,0 +1,10 @@
+package routes
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/lmd1e/song_library/app/controllers"
+)
+
+func RegisterAuditRoutes(router gin.IRoutes, controller *controllers.AuditController) {
+	router.GET("/audit", controller.GetAudit)
+}
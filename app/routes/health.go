@@ -0,0 +1,11 @@
+package routes
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/lmd1e/song_library/app/controllers"
+)
+
+func RegisterHealthRoutes(router *gin.Engine, controller *controllers.HealthController) {
+	router.GET("/healthz", controller.Liveness)
+	router.GET("/readyz", controller.Readiness)
+}
@@ -0,0 +1,14 @@
+package routes
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/lmd1e/song_library/app/controllers"
+)
+
+func RegisterJobRoutes(router gin.IRoutes, controller *controllers.JobController) {
+	router.POST("/jobs/import", controller.SubmitImportJob)
+	router.POST("/jobs/export", controller.SubmitExportJob)
+	router.GET("/jobs/:id", controller.GetJob)
+	router.POST("/jobs/:id/cancel", controller.CancelJob)
+	router.GET("/jobs/:id/artifact", controller.DownloadJobArtifact)
+}
@@ -1,14 +1,36 @@
 package routes
 
 import (
+	"slices"
+
 	"github.com/gin-gonic/gin"
 	"github.com/lmd1e/song_library/app/controllers"
+	"github.com/lmd1e/song_library/app/middleware"
+	"github.com/lmd1e/song_library/app/utils"
 )
 
-func RegisterSongRoutes(router *gin.Engine, controller *controllers.SongController) {
-	router.GET("/songs", controller.GetSongs)
-	router.GET("/songs/:id/text", controller.GetSongText)
+func RegisterSongRoutes(router gin.IRoutes, controller *controllers.SongController) {
+	// Routes returning songs or lyrics render them in the media type the
+	// client accepts.
+	negotiate := middleware.Negotiate(utils.RenderMediaTypes...)
+	negotiateLyrics := middleware.Negotiate(slices.Concat(utils.RenderMediaTypes, utils.LyricMediaTypes)...)
+
+	router.GET("/songs", negotiate, controller.GetSongs)
+	router.GET("/songs/:id", negotiate, controller.GetSong)
+	router.GET("/songs/:id/text", negotiateLyrics, controller.GetSongText)
+	router.PUT("/songs/:id/text", negotiate, controller.UpdateSongText)
+	router.GET("/songs/:id/sections", negotiate, controller.GetSongSections)
 	router.DELETE("/songs/:id", controller.DeleteSong)
-	router.PUT("/songs/:id", controller.UpdateSong)
-	router.POST("/songs", controller.AddSong)
+	router.PUT("/songs/:id", negotiate, controller.UpdateSong)
+	router.PATCH("/songs/:id", negotiate, controller.PatchSong)
+	router.POST("/songs", negotiate, controller.AddSong)
+	router.POST("/songs/batch", controller.BatchSongs)
+	router.POST("/songs/import", controller.ImportSongs)
+	router.GET("/songs/export", controller.ExportSongs)
+	router.GET("/songs/trash", negotiate, controller.GetDeletedSongs)
+	router.POST("/songs/:id/restore", negotiate, controller.RestoreSong)
+	router.GET("/songs/:id/revisions", controller.GetSongRevisions)
+	router.GET("/songs/:id/revisions/diff", controller.DiffSongRevisions)
+	router.GET("/songs/:id/revisions/:rev", controller.GetSongRevision)
+	router.POST("/songs/:id/revisions/:rev/restore", negotiate, controller.RestoreSongRevision)
 }
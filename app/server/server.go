@@ -0,0 +1,57 @@
+// Package server configures the HTTP server of the service.
+package server
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lmd1e/song_library/app/config"
+)
+
+// New returns a server for handler with the address and limits of cfg.
+// Imports extend and exports lift the read and write timeouts for their own
+// requests, so the timeouts only need to suit ordinary API calls.
+func New(cfg config.Server, handler http.Handler) *http.Server {
+	return &http.Server{
+		Addr:              cfg.Addr,
+		Handler:           handler,
+		ReadTimeout:       cfg.ReadTimeout,
+		ReadHeaderTimeout: cfg.ReadHeaderTimeout,
+		WriteTimeout:      cfg.WriteTimeout,
+		IdleTimeout:       cfg.IdleTimeout,
+		MaxHeaderBytes:    cfg.MaxHeaderBytes,
+	}
+}
+
+// NewRouter returns an empty router that takes the client IP from
+// X-Forwarded-For only when the request comes from one of the trusted
+// proxies of cfg, so that clients cannot choose the IP they are rate limited
+// and audited by.
+func NewRouter(cfg config.Server) (*gin.Engine, error) {
+	router := gin.New()
+	router.HandleMethodNotAllowed = true
+	if err := router.SetTrustedProxies(cfg.TrustedProxyList()); err != nil {
+		return nil, err
+	}
+	return router, nil
+}
+
+// ExtendDeadlines replaces the read and write deadlines of a request with one
+// timeout from now, for uploads that need longer than ordinary API calls but
+// must still end.
+func ExtendDeadlines(w http.ResponseWriter, timeout time.Duration) {
+	rc := http.NewResponseController(w)
+	deadline := time.Now().Add(timeout)
+	rc.SetReadDeadline(deadline)
+	rc.SetWriteDeadline(deadline)
+}
+
+// LiftDeadlines removes the read and write deadlines of a request, for
+// streaming endpoints whose duration depends on the size of the data rather
+// than on the server.
+func LiftDeadlines(w http.ResponseWriter) {
+	rc := http.NewResponseController(w)
+	rc.SetReadDeadline(time.Time{})
+	rc.SetWriteDeadline(time.Time{})
+}
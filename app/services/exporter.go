@@ -0,0 +1,225 @@
+package services
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/lmd1e/song_library/app/models"
+	"github.com/lmd1e/song_library/app/repositories"
+)
+
+const FormatJSON = "json"
+
+const exportDateLayout = "2006-01-02"
+
+// ExportColumns are the CSV columns of an export; the file can be imported
+// back as is.
+var ExportColumns = []string{"id", "group", "song", "release_date", "text", "link"}
+
+// exportedSong is a song as written to NDJSON and JSON exports. It has the
+// fields of ExportColumns, so that the file can be imported back as is and
+// does not expose bookkeeping such as versions or deletion times.
+type exportedSong struct {
+	ID          int       `json:"id"`
+	Group       string    `json:"group"`
+	Song        string    `json:"song"`
+	ReleaseDate time.Time `json:"release_date"`
+	Text        string    `json:"text"`
+	Link        string    `json:"link"`
+}
+
+func newExportedSong(song models.Song) exportedSong {
+	return exportedSong{
+		ID:          song.ID,
+		Group:       song.Group,
+		Song:        song.Song,
+		ReleaseDate: song.ReleaseDate,
+		Text:        song.Text,
+		Link:        song.Link,
+	}
+}
+
+// ExportContentTypes maps export formats to their media types.
+var ExportContentTypes = map[string]string{
+	FormatCSV:    "text/csv; charset=utf-8",
+	FormatNDJSON: "application/x-ndjson",
+	FormatJSON:   "application/json; charset=utf-8",
+}
+
+const exportProgressInterval = 1000
+
+type ExportOptions struct {
+	Format string
+	Filter map[string]string
+	Gzip   bool
+	// Progress, if set, is called with the number of songs written every
+	// 1000 songs. An error stops the export.
+	Progress func(count int) error
+}
+
+// ContentType returns the media type of the exported file.
+func (o ExportOptions) ContentType() string {
+	if o.Gzip {
+		return "application/gzip"
+	}
+	return ExportContentTypes[o.Format]
+}
+
+// Filename returns the name of a file exported at t.
+func (o ExportOptions) Filename(t time.Time) string {
+	name := fmt.Sprintf("songs-%s.%s", t.UTC().Format("20060102-150405"), o.Format)
+	if o.Gzip {
+		name += ".gz"
+	}
+	return name
+}
+
+// Exporter writes songs from the repository to a stream as they are read.
+type Exporter struct {
+	repo repositories.SongRepository
+}
+
+func NewExporter(repo repositories.SongRepository) *Exporter {
+	return &Exporter{repo: repo}
+}
+
+// Export writes the songs matching the filter to w and returns the number of
+// songs written. Nothing is written to w before the first song is read, so a
+// failing query leaves w untouched; a failure midway leaves a gzip stream
+// unterminated.
+func (e *Exporter) Export(ctx context.Context, w io.Writer, opts ExportOptions) (int, error) {
+	var gz *gzip.Writer
+	if opts.Gzip {
+		gz = gzip.NewWriter(w)
+		w = gz
+	}
+	writer, err := newSongWriter(w, opts.Format)
+	if err != nil {
+		return 0, err
+	}
+	count := 0
+	err = e.repo.ExportSongs(ctx, opts.Filter, func(song models.Song) error {
+		if err := writer.Write(song); err != nil {
+			return err
+		}
+		count++
+		if opts.Progress != nil && count%exportProgressInterval == 0 {
+			return opts.Progress(count)
+		}
+		return nil
+	})
+	if err != nil {
+		return count, err
+	}
+	if err := writer.Close(); err != nil {
+		return count, err
+	}
+	if gz != nil {
+		if err := gz.Close(); err != nil {
+			return count, err
+		}
+	}
+	if opts.Progress != nil {
+		return count, opts.Progress(count)
+	}
+	return count, nil
+}
+
+type songWriter interface {
+	Write(song models.Song) error
+	Close() error
+}
+
+func newSongWriter(w io.Writer, format string) (songWriter, error) {
+	switch format {
+	case FormatCSV:
+		return &csvSongWriter{w: csv.NewWriter(w)}, nil
+	case FormatNDJSON:
+		return &ndjsonSongWriter{enc: json.NewEncoder(w)}, nil
+	case FormatJSON:
+		return &jsonSongWriter{w: w}, nil
+	default:
+		return nil, fmt.Errorf("unsupported export format %q", format)
+	}
+}
+
+type csvSongWriter struct {
+	w             *csv.Writer
+	headerWritten bool
+}
+
+func (c *csvSongWriter) writeHeader() error {
+	if c.headerWritten {
+		return nil
+	}
+	c.headerWritten = true
+	return c.w.Write(ExportColumns)
+}
+
+func (c *csvSongWriter) Write(song models.Song) error {
+	if err := c.writeHeader(); err != nil {
+		return err
+	}
+	return c.w.Write([]string{
+		strconv.Itoa(song.ID),
+		song.Group,
+		song.Song,
+		song.ReleaseDate.Format(exportDateLayout),
+		song.Text,
+		song.Link,
+	})
+}
+
+func (c *csvSongWriter) Close() error {
+	if err := c.writeHeader(); err != nil {
+		return err
+	}
+	c.w.Flush()
+	return c.w.Error()
+}
+
+type ndjsonSongWriter struct {
+	enc *json.Encoder
+}
+
+func (n *ndjsonSongWriter) Write(song models.Song) error { return n.enc.Encode(newExportedSong(song)) }
+
+func (n *ndjsonSongWriter) Close() error { return nil }
+
+// jsonSongWriter writes a JSON array one element at a time.
+type jsonSongWriter struct {
+	w       io.Writer
+	started bool
+}
+
+func (j *jsonSongWriter) Write(song models.Song) error {
+	data, err := json.Marshal(newExportedSong(song))
+	if err != nil {
+		return err
+	}
+	sep := ",\n"
+	if !j.started {
+		sep = "[\n"
+		j.started = true
+	}
+	if _, err := io.WriteString(j.w, sep); err != nil {
+		return err
+	}
+	_, err = j.w.Write(data)
+	return err
+}
+
+func (j *jsonSongWriter) Close() error {
+	closing := "\n]\n"
+	if !j.started {
+		closing = "[]\n"
+	}
+	_, err := io.WriteString(j.w, closing)
+	return err
+}
@@ -0,0 +1,100 @@
+package services
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	HealthStatusUp   = "up"
+	HealthStatusDown = "down"
+
+	defaultHealthCheckTimeout = 2 * time.Second
+)
+
+// HealthCheck probes one dependency of the service; a nil error means the
+// dependency is usable.
+type HealthCheck struct {
+	Name  string
+	Check func(ctx context.Context) error
+}
+
+type HealthCheckResult struct {
+	Status    string  `json:"status" example:"up"`
+	LatencyMs float64 `json:"latency_ms" example:"1.25"`
+	Error     string  `json:"error,omitempty"`
+}
+
+type HealthReport struct {
+	Status string                       `json:"status" example:"up"`
+	Checks map[string]HealthCheckResult `json:"checks"`
+}
+
+// HealthChecker runs the readiness checks of the service. Once it is
+// draining, during a graceful shutdown, the service reports itself as not
+// ready so that no new traffic is routed to it.
+type HealthChecker struct {
+	checks   []HealthCheck
+	timeout  time.Duration
+	draining atomic.Bool
+}
+
+func NewHealthChecker(checks ...HealthCheck) *HealthChecker {
+	return &HealthChecker{checks: checks, timeout: defaultHealthCheckTimeout}
+}
+
+// SetDraining marks the service as shutting down.
+func (h *HealthChecker) SetDraining() {
+	h.draining.Store(true)
+}
+
+// Draining reports whether the service is shutting down.
+func (h *HealthChecker) Draining() bool {
+	return h.draining.Load()
+}
+
+// Check runs all checks concurrently, each limited to the check timeout. The
+// report is up only if every check is up and the service is not draining.
+func (h *HealthChecker) Check(ctx context.Context) HealthReport {
+	report := HealthReport{Status: HealthStatusUp, Checks: make(map[string]HealthCheckResult, len(h.checks)+1)}
+	results := make([]HealthCheckResult, len(h.checks))
+	var wg sync.WaitGroup
+	for i, check := range h.checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i] = h.run(ctx, check)
+		}()
+	}
+	wg.Wait()
+
+	for i, check := range h.checks {
+		report.Checks[check.Name] = results[i]
+		if results[i].Status != HealthStatusUp {
+			report.Status = HealthStatusDown
+		}
+	}
+	if h.Draining() {
+		report.Status = HealthStatusDown
+		report.Checks["shutdown"] = HealthCheckResult{Status: HealthStatusDown, Error: "service is shutting down"}
+	}
+	return report
+}
+
+func (h *HealthChecker) run(ctx context.Context, check HealthCheck) HealthCheckResult {
+	ctx, cancel := context.WithTimeout(ctx, h.timeout)
+	defer cancel()
+	start := time.Now()
+	err := check.Check(ctx)
+	result := HealthCheckResult{
+		Status:    HealthStatusUp,
+		LatencyMs: float64(time.Since(start).Microseconds()) / 1000,
+	}
+	if err != nil {
+		result.Status = HealthStatusDown
+		result.Error = err.Error()
+	}
+	return result
+}
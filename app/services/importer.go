@@ -0,0 +1,379 @@
+package services
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lmd1e/song_library/app/models"
+	"github.com/lmd1e/song_library/app/repositories"
+	"github.com/lmd1e/song_library/app/requests"
+	"github.com/lmd1e/song_library/app/utils"
+)
+
+const (
+	FormatCSV    = "csv"
+	FormatNDJSON = "ndjson"
+
+	defaultImportBatchSize = 500
+	maxReportedRowErrors   = 1000
+	maxNDJSONLineSize      = 4 << 20
+)
+
+// ImportFields are the song fields that can be read from an import file.
+var ImportFields = []string{"group", "song", "release_date", "text", "link"}
+
+var releaseDateLayouts = []string{time.RFC3339, "2006-01-02", "02.01.2006"}
+
+type ImportOptions struct {
+	Format string
+	// Mapping maps song fields to source columns (CSV) or keys (NDJSON);
+	// unmapped fields are read from the column of the same name.
+	Mapping   map[string]string
+	Enrich    bool
+	DryRun    bool
+	BatchSize int
+	// Checkpoint continues an interrupted import: the rows it covers are
+	// skipped and its counts are carried over.
+	Checkpoint *ImportResult
+	// Store, if set, stores every batch in place of the importer's
+	// repository. It gets the valid songs of the batch, none for a dry run,
+	// and the running totals that count them, so that it can save both in
+	// one transaction. An error stops the import.
+	Store func(songs []models.Song, result ImportResult) error
+	// Progress, if set, is called with the running totals every BatchSize
+	// rows, once the rows read so far are stored. An error stops the import.
+	Progress func(ImportResult) error
+}
+
+type ImportRowError struct {
+	Row   int    `json:"row"`
+	Field string `json:"field,omitempty"`
+	Error string `json:"error"`
+}
+
+type ImportResult struct {
+	Rows     int              `json:"rows"`
+	Imported int              `json:"imported"`
+	Failed   int              `json:"failed"`
+	DryRun   bool             `json:"dry_run"`
+	Errors   []ImportRowError `json:"errors"`
+}
+
+// ParseColumnMapping parses a mapping written as "field:column,...", e.g.
+// "group:artist,song:title".
+func ParseColumnMapping(spec string) (map[string]string, error) {
+	mapping := make(map[string]string)
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		field, column, ok := strings.Cut(entry, ":")
+		if !ok || !isImportField(field) || column == "" {
+			return nil, fmt.Errorf("invalid column mapping %q: expected field:column with field one of %s", entry, strings.Join(ImportFields, ", "))
+		}
+		mapping[field] = column
+	}
+	return mapping, nil
+}
+
+func isImportField(field string) bool {
+	for _, f := range ImportFields {
+		if f == field {
+			return true
+		}
+	}
+	return false
+}
+
+// Importer reads songs from CSV or NDJSON streams and stores them in batches.
+type Importer struct {
+	repo    repositories.SongRepository
+	details requests.SongDetailClient
+}
+
+func NewImporter(repo repositories.SongRepository, details requests.SongDetailClient) *Importer {
+	return &Importer{repo: repo, details: details}
+}
+
+// Import reads the whole stream row by row. Invalid rows are reported in the
+// result and skipped; an error is returned only if reading the stream or
+// storing a batch fails.
+func (i *Importer) Import(ctx context.Context, r io.Reader, opts ImportOptions, meta models.AuditMeta) (ImportResult, error) {
+	result := ImportResult{DryRun: opts.DryRun, Errors: []ImportRowError{}}
+	if opts.Checkpoint != nil {
+		result.Rows = opts.Checkpoint.Rows
+		result.Imported = opts.Checkpoint.Imported
+		result.Failed = opts.Checkpoint.Failed
+		result.Errors = append(result.Errors, opts.Checkpoint.Errors...)
+	}
+	skip := result.Rows
+	batchSize := opts.BatchSize
+	if batchSize <= 0 {
+		batchSize = defaultImportBatchSize
+	}
+
+	var batch []models.Song
+	checkpoint := result.Rows
+	flush := func() error {
+		stored := result
+		stored.Imported += len(batch)
+		if opts.DryRun {
+			batch = batch[:0]
+		}
+		switch {
+		case opts.Store != nil:
+			if err := opts.Store(batch, stored); err != nil {
+				return err
+			}
+		case len(batch) > 0:
+			if _, err := i.repo.AddSongs(ctx, batch, meta); err != nil {
+				return err
+			}
+		}
+		result = stored
+		batch = batch[:0]
+		checkpoint = result.Rows
+		if opts.Progress != nil {
+			return opts.Progress(result)
+		}
+		return nil
+	}
+
+	err := readRecords(r, opts.Format, func(row int, record map[string]string, readErr error) error {
+		if row <= skip {
+			return nil
+		}
+		result.Rows++
+		song, err := models.Song{}, readErr
+		if err == nil {
+			song, err = i.songFromRecord(ctx, record, opts)
+		}
+		if err != nil {
+			result.Failed++
+			for _, rowErr := range rowErrors(row, err) {
+				if len(result.Errors) < maxReportedRowErrors {
+					result.Errors = append(result.Errors, rowErr)
+				}
+			}
+		} else {
+			batch = append(batch, song)
+		}
+		if result.Rows-checkpoint >= batchSize {
+			return flush()
+		}
+		return nil
+	})
+	if err != nil {
+		return result, err
+	}
+	return result, flush()
+}
+
+type fieldError struct {
+	field string
+	err   error
+}
+
+func (e *fieldError) Error() string { return e.field + ": " + e.err.Error() }
+
+// invalidRow lists the rules of a song body that a row breaks.
+type invalidRow struct {
+	fields []utils.FieldError
+}
+
+func (e *invalidRow) Error() string {
+	problems := make([]string, len(e.fields))
+	for i, field := range e.fields {
+		problems[i] = field.Field + " " + field.Message
+	}
+	return strings.Join(problems, "; ")
+}
+
+// rowErrors reports err for a row, one entry for every field it names.
+func rowErrors(row int, err error) []ImportRowError {
+	var fe *fieldError
+	var invalid *invalidRow
+	switch {
+	case errors.As(err, &fe):
+		return []ImportRowError{{Row: row, Field: fe.field, Error: fe.err.Error()}}
+	case errors.As(err, &invalid):
+		rowErrs := make([]ImportRowError, len(invalid.fields))
+		for i, field := range invalid.fields {
+			rowErrs[i] = ImportRowError{Row: row, Field: field.Field, Error: field.Message}
+		}
+		return rowErrs
+	default:
+		return []ImportRowError{{Row: row, Error: err.Error()}}
+	}
+}
+
+// songFromRecord reads a song from a row and checks it with the rules of a
+// song body, so that rows the API would reject never reach the database.
+func (i *Importer) songFromRecord(ctx context.Context, record map[string]string, opts ImportOptions) (models.Song, error) {
+	value := func(field string) string {
+		column := field
+		if mapped, ok := opts.Mapping[field]; ok {
+			column = mapped
+		}
+		return strings.TrimSpace(record[column])
+	}
+
+	req := requests.SongRequest{
+		Group: value("group"),
+		Song:  value("song"),
+		Text:  value("text"),
+		Link:  value("link"),
+	}
+	if releaseDate := value("release_date"); releaseDate != "" {
+		parsed, err := parseReleaseDate(releaseDate)
+		if err != nil {
+			return models.Song{}, &fieldError{"release_date", err}
+		}
+		req.ReleaseDate = parsed
+	}
+
+	// Rows without a group or song are not looked up.
+	needsDetails := req.Group != "" && req.Song != "" &&
+		(req.ReleaseDate.IsZero() || req.Text == "" || req.Link == "")
+	// A dry run does not call the external API; the missing fields would be
+	// filled in by a real import.
+	deferDetails := needsDetails && opts.Enrich && opts.DryRun
+	if needsDetails && opts.Enrich && !opts.DryRun {
+		detail, err := i.details.GetSongDetail(ctx, req.Group, req.Song)
+		if err != nil {
+			return models.Song{}, fmt.Errorf("failed to fetch song details: %w", err)
+		}
+		if req.ReleaseDate.IsZero() {
+			req.ReleaseDate = detail.ReleaseDate
+		}
+		if req.Text == "" {
+			req.Text = detail.Text
+		}
+		if req.Link == "" {
+			req.Link = detail.Link
+		}
+	}
+
+	if err := requests.Validate(&req); err != nil {
+		var fields []utils.FieldError
+		for _, field := range requests.FieldErrors(err) {
+			if deferDetails && field.Field == "release_date" && field.Code == "required" {
+				continue
+			}
+			fields = append(fields, field)
+		}
+		if len(fields) > 0 {
+			return models.Song{}, &invalidRow{fields: fields}
+		}
+	}
+	return req.ToSong(0, 0), nil
+}
+
+func parseReleaseDate(value string) (time.Time, error) {
+	for _, layout := range releaseDateLayouts {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid date %q, expected YYYY-MM-DD, DD.MM.YYYY or RFC3339", value)
+}
+
+// readRecords streams records to fn, numbering data rows from 1. Malformed
+// rows are passed with a non-nil readErr instead of stopping the import.
+func readRecords(r io.Reader, format string, fn func(row int, record map[string]string, readErr error) error) error {
+	switch format {
+	case FormatCSV:
+		return readCSV(r, fn)
+	case FormatNDJSON:
+		return readNDJSON(r, fn)
+	default:
+		return fmt.Errorf("unsupported import format %q", format)
+	}
+}
+
+func readCSV(r io.Reader, fn func(int, map[string]string, error) error) error {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	header, err := reader.Read()
+	if err == io.EOF {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read CSV header: %w", err)
+	}
+	for i := range header {
+		header[i] = strings.TrimSpace(strings.TrimPrefix(header[i], "\ufeff"))
+	}
+
+	for row := 1; ; row++ {
+		values, err := reader.Read()
+		if err == io.EOF {
+			return nil
+		}
+		var parseErr *csv.ParseError
+		if errors.As(err, &parseErr) {
+			if err := fn(row, nil, parseErr.Err); err != nil {
+				return err
+			}
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		record := make(map[string]string, len(header))
+		for i, column := range header {
+			if i < len(values) {
+				record[column] = values[i]
+			}
+		}
+		if err := fn(row, record, nil); err != nil {
+			return err
+		}
+	}
+}
+
+func readNDJSON(r io.Reader, fn func(int, map[string]string, error) error) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), maxNDJSONLineSize)
+	row := 0
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		row++
+		var raw map[string]interface{}
+		if err := json.Unmarshal([]byte(line), &raw); err != nil {
+			if err := fn(row, nil, fmt.Errorf("invalid JSON: %w", err)); err != nil {
+				return err
+			}
+			continue
+		}
+		record := make(map[string]string, len(raw))
+		for key, value := range raw {
+			switch v := value.(type) {
+			case string:
+				record[key] = v
+			case float64:
+				record[key] = strconv.FormatFloat(v, 'f', -1, 64)
+			case nil:
+			default:
+				encoded, _ := json.Marshal(v)
+				record[key] = string(encoded)
+			}
+		}
+		if err := fn(row, record, nil); err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
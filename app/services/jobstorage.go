@@ -0,0 +1,74 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/lmd1e/song_library/app/models"
+)
+
+// JobStorage keeps job uploads and result artifacts on the local disk.
+type JobStorage struct {
+	dir string
+}
+
+func NewJobStorage(dir string) (*JobStorage, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	return &JobStorage{dir: dir}, nil
+}
+
+// SaveUpload copies r to a new file and returns its path.
+func (s *JobStorage) SaveUpload(r io.Reader, format string) (string, error) {
+	f, err := os.CreateTemp(s.dir, "upload-*."+format)
+	if err != nil {
+		return "", err
+	}
+	if _, err := io.Copy(f, r); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return "", err
+	}
+	return f.Name(), f.Close()
+}
+
+// Remove deletes the upload and artifact of a job, if they are still there.
+func (s *JobStorage) Remove(job models.Job) error {
+	var errs []error
+	for _, path := range []string{job.InputPath, job.ArtifactPath} {
+		if path == "" {
+			continue
+		}
+		if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// artifactPath returns where the result of a job is stored.
+func (s *JobStorage) artifactPath(jobID int64, name string) string {
+	return filepath.Join(s.dir, fmt.Sprintf("job-%d-%s", jobID, name))
+}
+
+// CreateArtifact opens a temporary file for the result of a job. commit
+// moves it into place; until then a partial artifact is never visible.
+func (s *JobStorage) CreateArtifact(jobID int64, name string) (f *os.File, commit func() (string, error), err error) {
+	path := s.artifactPath(jobID, name)
+	f, err = os.Create(path + ".part")
+	if err != nil {
+		return nil, nil, err
+	}
+	commit = func() (string, error) {
+		if err := f.Close(); err != nil {
+			return "", err
+		}
+		return path, os.Rename(f.Name(), path)
+	}
+	return f, commit, nil
+}
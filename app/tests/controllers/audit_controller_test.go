@@ -0,0 +1,61 @@
+package controllers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lmd1e/song_library/app/controllers"
+	"github.com/lmd1e/song_library/app/models"
+	"github.com/lmd1e/song_library/app/tests/mocks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestGetAudit(t *testing.T) {
+	mockRepo := new(mocks.MockAuditRepository)
+	auditController := controllers.NewAuditController(mockRepo)
+
+	from := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	expectedFilter := models.AuditFilter{SongID: 7, Actor: "editor", From: from}
+	expectedEntries := []models.AuditEntry{
+		{ID: 1, SongID: 7, Action: models.AuditActionDelete, Actor: "editor", Before: json.RawMessage(`{"id":7}`)},
+	}
+	mockRepo.On("GetAuditEntries", mock.Anything, expectedFilter, 10, 0).Return(expectedEntries, nil)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/audit?song_id=7&actor=editor&from=2024-01-01T00:00:00Z", nil)
+
+	router := gin.Default()
+	router.GET("/audit", auditController.GetAudit)
+
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, 200, w.Code)
+	var entries []models.AuditEntry
+	json.Unmarshal(w.Body.Bytes(), &entries)
+	assert.Equal(t, 1, len(entries))
+	assert.Equal(t, models.AuditActionDelete, entries[0].Action)
+	assert.JSONEq(t, `{"id":7}`, string(entries[0].Before))
+
+	mockRepo.AssertExpectations(t)
+}
+
+func TestGetAuditInvalidTime(t *testing.T) {
+	mockRepo := new(mocks.MockAuditRepository)
+	auditController := controllers.NewAuditController(mockRepo)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/audit?from=yesterday", nil)
+
+	router := gin.Default()
+	router.GET("/audit", auditController.GetAudit)
+
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, 400, w.Code)
+	mockRepo.AssertNotCalled(t, "GetAuditEntries")
+}
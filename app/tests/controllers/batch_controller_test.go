@@ -0,0 +1,148 @@
+package controllers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lmd1e/song_library/app/controllers"
+	"github.com/lmd1e/song_library/app/models"
+	"github.com/lmd1e/song_library/app/repositories"
+	"github.com/lmd1e/song_library/app/requests"
+	"github.com/lmd1e/song_library/app/tests/mocks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func serveBatch(repo *mocks.MockSongRepository, body string) *httptest.ResponseRecorder {
+	songController := controllers.NewSongController(repo, new(mocks.MockSongRequest))
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/songs/batch", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+
+	router := gin.Default()
+	router.POST("/songs/batch", songController.BatchSongs)
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestBatchSongsCommits(t *testing.T) {
+	mockRepo := new(mocks.MockSongRepository)
+	mockTx := new(mocks.MockSongRepository)
+	mockRepo.On("WithTx", mock.Anything).Return(mockTx, nil)
+	mockTx.On("AddSong", mock.Anything, mock.MatchedBy(func(song models.Song) bool { return song.Song == "New" }), mock.AnythingOfType("models.AuditMeta")).
+		Return(models.Song{ID: 10, Song: "New", Version: 1}, nil)
+	mockTx.On("PatchSong", mock.Anything, 3, mock.MatchedBy(func(patch models.SongPatch) bool { return patch.Version == 2 && *patch.Text == "la" }), mock.AnythingOfType("models.AuditMeta")).
+		Return(models.Song{ID: 3, Text: "la", Version: 3}, nil)
+	mockTx.On("DeleteSong", mock.Anything, 4, 0, mock.AnythingOfType("models.AuditMeta")).Return(nil)
+
+	w := serveBatch(mockRepo, `{"operations": [
+		{"op": "create", "song": {"group": "G", "song": "New", "release_date": "2006-07-16T00:00:00Z"}},
+		{"op": "patch", "id": 3, "version": 2, "patch": {"text": "la"}},
+		{"op": "delete", "id": 4}
+	]}`)
+
+	assert.Equal(t, 200, w.Code)
+	var resp requests.BatchResponse
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	assert.True(t, resp.Committed)
+	assert.Equal(t, requests.BatchModeAllOrNothing, resp.Mode)
+	assert.Equal(t, []int{201, 200, 200}, []int{resp.Results[0].Status, resp.Results[1].Status, resp.Results[2].Status})
+	assert.Equal(t, 10, resp.Results[0].Song.ID)
+	assert.Equal(t, 3, resp.Results[1].Song.Version)
+	mockTx.AssertExpectations(t)
+}
+
+func TestBatchSongsAllOrNothingRollsBack(t *testing.T) {
+	mockRepo := new(mocks.MockSongRepository)
+	mockTx := new(mocks.MockSongRepository)
+	mockRepo.On("WithTx", mock.Anything).Return(mockTx, nil)
+	mockTx.On("DeleteSong", mock.Anything, 1, 0, mock.AnythingOfType("models.AuditMeta")).Return(nil)
+	mockTx.On("UpdateSong", mock.Anything, mock.AnythingOfType("models.Song"), mock.AnythingOfType("models.AuditMeta")).
+		Return(models.Song{}, repositories.ErrVersionConflict)
+
+	w := serveBatch(mockRepo, `{"mode": "all_or_nothing", "operations": [
+		{"op": "delete", "id": 1},
+		{"op": "update", "id": 2, "version": 5, "song": {"group": "G", "song": "S", "release_date": "2006-07-16T00:00:00Z"}},
+		{"op": "delete", "id": 3}
+	]}`)
+
+	assert.Equal(t, 409, w.Code)
+	var resp requests.BatchResponse
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	assert.False(t, resp.Committed)
+	assert.Equal(t, 424, resp.Results[0].Status)
+	assert.Equal(t, 412, resp.Results[1].Status)
+	assert.Equal(t, "version_conflict", resp.Results[1].Code)
+	assert.Equal(t, 424, resp.Results[2].Status)
+	mockTx.AssertNotCalled(t, "DeleteSong", 3, mock.Anything, mock.Anything)
+}
+
+func TestBatchSongsBestEffort(t *testing.T) {
+	mockRepo := new(mocks.MockSongRepository)
+	mockTx := new(mocks.MockSongRepository)
+	mockRepo.On("WithTx", mock.Anything).Return(mockTx, nil)
+	mockTx.On("DeleteSong", mock.Anything, 1, 0, mock.AnythingOfType("models.AuditMeta")).Return(repositories.ErrSongNotFound)
+	mockTx.On("DeleteSong", mock.Anything, 2, 0, mock.AnythingOfType("models.AuditMeta")).Return(nil)
+
+	w := serveBatch(mockRepo, `{"mode": "best_effort", "operations": [
+		{"op": "delete", "id": 1},
+		{"op": "rename", "id": 1},
+		{"op": "delete", "id": 2}
+	]}`)
+
+	assert.Equal(t, 200, w.Code)
+	var resp requests.BatchResponse
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	assert.True(t, resp.Committed)
+	assert.Equal(t, 404, resp.Results[0].Status)
+	assert.Equal(t, 400, resp.Results[1].Status)
+	assert.Contains(t, resp.Results[1].Error, "op must be one of")
+	assert.Equal(t, 200, resp.Results[2].Status)
+	mockTx.AssertExpectations(t)
+}
+
+func TestBatchSongsInvalidRequest(t *testing.T) {
+	mockRepo := new(mocks.MockSongRepository)
+
+	assert.Equal(t, 400, serveBatch(mockRepo, `{"operations": []}`).Code)
+	assert.Equal(t, 400, serveBatch(mockRepo, `{"mode": "sometimes", "operations": [{"op": "delete", "id": 1}]}`).Code)
+	mockRepo.AssertNotCalled(t, "WithTx", mock.Anything)
+}
+
+func TestBatchSongsRequiresVersion(t *testing.T) {
+	mockRepo := new(mocks.MockSongRepository)
+	mockTx := new(mocks.MockSongRepository)
+	mockRepo.On("WithTx", mock.Anything).Return(mockTx, nil)
+	mockTx.On("AddSong", mock.Anything, mock.AnythingOfType("models.Song"), mock.AnythingOfType("models.AuditMeta")).
+		Return(models.Song{ID: 10, Song: "New", Version: 1}, nil)
+	mockTx.On("DeleteSong", mock.Anything, 2, 3, mock.AnythingOfType("models.AuditMeta")).Return(nil)
+	songController := controllers.NewSongController(mockRepo, new(mocks.MockSongRequest))
+	songController.SetRequireIfMatch(true)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/songs/batch", strings.NewReader(`{"mode": "best_effort", "operations": [
+		{"op": "create", "song": {"group": "G", "song": "New", "release_date": "2006-07-16T00:00:00Z"}},
+		{"op": "delete", "id": 1},
+		{"op": "patch", "id": 1, "version": 0, "patch": {"text": "la"}},
+		{"op": "delete", "id": 2, "version": 3}
+	]}`))
+	req.Header.Set("Content-Type", "application/json")
+	router := gin.Default()
+	router.POST("/songs/batch", songController.BatchSongs)
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, 200, w.Code)
+	var resp requests.BatchResponse
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	assert.Equal(t, []int{201, 428, 428, 200}, []int{resp.Results[0].Status, resp.Results[1].Status, resp.Results[2].Status, resp.Results[3].Status})
+	assert.Equal(t, "precondition_required", resp.Results[1].Code)
+	assert.Equal(t, "precondition_required", resp.Results[2].Code)
+	mockTx.AssertExpectations(t)
+	mockTx.AssertNotCalled(t, "DeleteSong", mock.Anything, 1, mock.Anything, mock.Anything)
+	mockTx.AssertNotCalled(t, "PatchSong", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
@@ -0,0 +1,216 @@
+package controllers
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lmd1e/song_library/app/controllers"
+	"github.com/lmd1e/song_library/app/middleware"
+	"github.com/lmd1e/song_library/app/models"
+	"github.com/lmd1e/song_library/app/repositories"
+	"github.com/lmd1e/song_library/app/tests/mocks"
+	"github.com/lmd1e/song_library/app/utils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestGetSongETag(t *testing.T) {
+	mockRepo := new(mocks.MockSongRepository)
+	songController := controllers.NewSongController(mockRepo, new(mocks.MockSongRequest))
+
+	mockRepo.On("GetSong", mock.Anything, 1).Return(models.Song{ID: 1, Group: "Test Group", Version: 4}, nil)
+
+	router := gin.Default()
+	router.GET("/songs/:id", songController.GetSong)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/songs/1", nil)
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, 200, w.Code)
+	assert.Equal(t, `"4"`, w.Header().Get("ETag"))
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/songs/1", nil)
+	req.Header.Set("If-None-Match", `"4"`)
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, 304, w.Code)
+	assert.Empty(t, w.Body.String())
+}
+
+func TestGetSongETagPerMediaType(t *testing.T) {
+	mockRepo := new(mocks.MockSongRepository)
+	songController := controllers.NewSongController(mockRepo, new(mocks.MockSongRequest))
+
+	mockRepo.On("GetSong", mock.Anything, 1).Return(models.Song{ID: 1, Group: "Test Group", Version: 4}, nil)
+
+	router := gin.Default()
+	router.GET("/songs/:id", middleware.Negotiate(utils.RenderMediaTypes...), songController.GetSong)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/songs/1", nil)
+	req.Header.Set("Accept", "application/xml")
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, 200, w.Code)
+	assert.Equal(t, `"4-xml"`, w.Header().Get("ETag"))
+
+	// A JSON tag does not validate the XML representation.
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/songs/1", nil)
+	req.Header.Set("Accept", "application/xml")
+	req.Header.Set("If-None-Match", `"4"`)
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, 200, w.Code)
+	assert.Contains(t, w.Body.String(), "<song>")
+}
+
+func TestPatchSongWithIfMatchOfOtherMediaType(t *testing.T) {
+	mockRepo := new(mocks.MockSongRepository)
+	songController := controllers.NewSongController(mockRepo, new(mocks.MockSongRequest))
+
+	mockRepo.On("PatchSong", mock.Anything, 1, mock.MatchedBy(func(patch models.SongPatch) bool {
+		return patch.Version == 4
+	}), mock.AnythingOfType("models.AuditMeta")).Return(models.Song{ID: 1, Version: 5}, nil)
+
+	router := gin.Default()
+	router.PATCH("/songs/:id", songController.PatchSong)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("PATCH", "/songs/1", bytes.NewBufferString(`{"text": "New text"}`))
+	req.Header.Set("If-Match", `"4-yaml"`)
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, 200, w.Code)
+	assert.Equal(t, `"5"`, w.Header().Get("ETag"))
+	mockRepo.AssertExpectations(t)
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("PATCH", "/songs/1", bytes.NewBufferString(`{"text": "New text"}`))
+	req.Header.Set("If-Match", `"4-pdf"`)
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, 412, w.Code)
+}
+
+func TestGetSongsNotModified(t *testing.T) {
+	mockRepo := new(mocks.MockSongRepository)
+	songController := controllers.NewSongController(mockRepo, new(mocks.MockSongRequest))
+
+	mockRepo.On("GetSongs", mock.Anything, mock.AnythingOfType("map[string]string"), 10, 0).Return([]models.Song{{ID: 1, Version: 1}}, nil)
+
+	router := gin.Default()
+	router.GET("/songs", songController.GetSongs)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/songs", nil)
+	router.ServeHTTP(w, req)
+	etag := w.Header().Get("ETag")
+	assert.NotEmpty(t, etag)
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/songs", nil)
+	req.Header.Set("If-None-Match", etag)
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, 304, w.Code)
+}
+
+func TestPatchSongWithStaleIfMatch(t *testing.T) {
+	mockRepo := new(mocks.MockSongRepository)
+	songController := controllers.NewSongController(mockRepo, new(mocks.MockSongRequest))
+
+	mockRepo.On("PatchSong", mock.Anything, 1, mock.MatchedBy(func(patch models.SongPatch) bool {
+		return patch.Version == 2 && *patch.Text == "New text" && patch.Group == nil
+	}), mock.AnythingOfType("models.AuditMeta")).Return(models.Song{}, repositories.ErrVersionConflict)
+
+	router := gin.Default()
+	router.PATCH("/songs/:id", songController.PatchSong)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("PATCH", "/songs/1", bytes.NewBufferString(`{"text": "New text"}`))
+	req.Header.Set("If-Match", `"2"`)
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, 412, w.Code)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestUpdateSongWithUnknownIfMatch(t *testing.T) {
+	mockRepo := new(mocks.MockSongRepository)
+	songController := controllers.NewSongController(mockRepo, new(mocks.MockSongRequest))
+
+	router := gin.Default()
+	router.PUT("/songs/:id", songController.UpdateSong)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("PUT", "/songs/1", bytes.NewBufferString(`{"group": "Group"}`))
+	req.Header.Set("If-Match", `"abc"`)
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, 412, w.Code)
+	mockRepo.AssertNotCalled(t, "UpdateSong", mock.Anything, mock.Anything)
+}
+
+func TestPatchSongWithIfMatchList(t *testing.T) {
+	mockRepo := new(mocks.MockSongRepository)
+	songController := controllers.NewSongController(mockRepo, new(mocks.MockSongRequest))
+
+	mockRepo.On("GetSong", mock.Anything, 1).Return(models.Song{ID: 1, Version: 4}, nil)
+	mockRepo.On("PatchSong", mock.Anything, 1, mock.MatchedBy(func(patch models.SongPatch) bool {
+		return patch.Version == 4
+	}), mock.AnythingOfType("models.AuditMeta")).Return(models.Song{ID: 1, Version: 5}, nil)
+
+	router := gin.Default()
+	router.PATCH("/songs/:id", songController.PatchSong)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("PATCH", "/songs/1", bytes.NewBufferString(`{"text": "New text"}`))
+	req.Header.Set("If-Match", `"3", W/"4", "4"`)
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, 200, w.Code)
+	assert.Equal(t, `"5"`, w.Header().Get("ETag"))
+	mockRepo.AssertExpectations(t)
+}
+
+func TestPatchSongWithIfMatchListWithoutCurrentVersion(t *testing.T) {
+	mockRepo := new(mocks.MockSongRepository)
+	songController := controllers.NewSongController(mockRepo, new(mocks.MockSongRequest))
+
+	mockRepo.On("GetSong", mock.Anything, 1).Return(models.Song{ID: 1, Version: 5}, nil)
+
+	router := gin.Default()
+	router.PATCH("/songs/:id", songController.PatchSong)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("PATCH", "/songs/1", bytes.NewBufferString(`{"text": "New text"}`))
+	req.Header.Set("If-Match", `"3", "4"`)
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, 412, w.Code)
+	assert.Contains(t, w.Body.String(), "version_conflict")
+	mockRepo.AssertNotCalled(t, "PatchSong", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestDeleteSongWithWeakIfMatch(t *testing.T) {
+	mockRepo := new(mocks.MockSongRepository)
+	songController := controllers.NewSongController(mockRepo, new(mocks.MockSongRequest))
+
+	router := gin.Default()
+	router.DELETE("/songs/:id", songController.DeleteSong)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("DELETE", "/songs/1", nil)
+	req.Header.Set("If-Match", `W/"3"`)
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, 412, w.Code)
+	assert.Contains(t, w.Body.String(), "weak_etag")
+	mockRepo.AssertNotCalled(t, "DeleteSong", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
@@ -0,0 +1,82 @@
+package controllers
+
+import (
+	"compress/gzip"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lmd1e/song_library/app/controllers"
+	"github.com/lmd1e/song_library/app/models"
+	"github.com/lmd1e/song_library/app/tests/mocks"
+	"github.com/lmd1e/song_library/app/utils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestExportSongsGzipCSV(t *testing.T) {
+	mockRepo := new(mocks.MockSongRepository)
+	songController := controllers.NewSongController(mockRepo, new(mocks.MockSongRequest))
+
+	songs := []models.Song{
+		{ID: 1, Group: "Test Group", Song: "Test Song", ReleaseDate: time.Date(2020, 1, 2, 0, 0, 0, 0, time.UTC), Text: "Test song text", Link: "https://example.com/test-song"},
+	}
+	mockRepo.On("ExportSongs", mock.Anything, map[string]string{"group": "Test Group"}, mock.Anything).Return(songs, nil)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/songs/export?format=csv&gzip=true&group=Test%20Group&limit=5", nil)
+
+	router := gin.Default()
+	router.GET("/songs/export", songController.ExportSongs)
+
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, 200, w.Code)
+	assert.Equal(t, "application/gzip", w.Header().Get("Content-Type"))
+	assert.Regexp(t, `^attachment; filename="songs-\d{8}-\d{6}\.csv\.gz"$`, w.Header().Get("Content-Disposition"))
+	gz, err := gzip.NewReader(w.Body)
+	assert.NoError(t, err)
+	body, err := io.ReadAll(gz)
+	assert.NoError(t, err)
+	assert.Equal(t, "id,group,song,release_date,text,link\n1,Test Group,Test Song,2020-01-02,Test song text,https://example.com/test-song\n", string(body))
+
+	mockRepo.AssertExpectations(t)
+}
+
+func TestExportSongsInvalidFormat(t *testing.T) {
+	mockRepo := new(mocks.MockSongRepository)
+	songController := controllers.NewSongController(mockRepo, new(mocks.MockSongRequest))
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/songs/export?format=xlsx", nil)
+
+	router := gin.Default()
+	router.GET("/songs/export", songController.ExportSongs)
+
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, 400, w.Code)
+	mockRepo.AssertNotCalled(t, "ExportSongs")
+}
+
+func TestExportSongsQueryFailure(t *testing.T) {
+	mockRepo := new(mocks.MockSongRepository)
+	songController := controllers.NewSongController(mockRepo, new(mocks.MockSongRequest))
+	mockRepo.On("ExportSongs", mock.Anything, map[string]string{}, mock.Anything).Return(nil, errors.New("db error"))
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/songs/export", nil)
+
+	router := gin.Default()
+	router.GET("/songs/export", songController.ExportSongs)
+
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, 500, w.Code)
+	assert.Empty(t, w.Header().Get("Content-Disposition"))
+	assert.Equal(t, utils.ProblemContentType, w.Header().Get("Content-Type"))
+}
@@ -0,0 +1,47 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lmd1e/song_library/app/controllers"
+	"github.com/lmd1e/song_library/app/services"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReadiness(t *testing.T) {
+	var dbErr error
+	checker := services.NewHealthChecker(services.HealthCheck{Name: "database", Check: func(context.Context) error { return dbErr }})
+	healthController := controllers.NewHealthController(checker)
+
+	router := gin.Default()
+	router.GET("/healthz", healthController.Liveness)
+	router.GET("/readyz", healthController.Readiness)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/readyz", nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, 200, w.Code)
+	var report services.HealthReport
+	json.Unmarshal(w.Body.Bytes(), &report)
+	assert.Equal(t, services.HealthStatusUp, report.Checks["database"].Status)
+
+	dbErr = errors.New("connection refused")
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/readyz", nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, 503, w.Code)
+	json.Unmarshal(w.Body.Bytes(), &report)
+	assert.Equal(t, "connection refused", report.Checks["database"].Error)
+
+	// Liveness does not depend on the checks.
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/healthz", nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, 200, w.Code)
+}
@@ -0,0 +1,178 @@
+package controllers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lmd1e/song_library/app/controllers"
+	"github.com/lmd1e/song_library/app/models"
+	"github.com/lmd1e/song_library/app/repositories"
+	"github.com/lmd1e/song_library/app/services"
+	"github.com/lmd1e/song_library/app/tests/mocks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func newJobRouter(t *testing.T, mockRepo *mocks.MockJobRepository) (*gin.Engine, string) {
+	dir := t.TempDir()
+	storage, err := services.NewJobStorage(dir)
+	assert.NoError(t, err)
+	jobController := controllers.NewJobController(mockRepo, storage, controllers.UploadLimits{MaxSize: 1 << 20, Timeout: time.Minute})
+
+	router := gin.Default()
+	router.POST("/jobs/import", jobController.SubmitImportJob)
+	router.POST("/jobs/export", jobController.SubmitExportJob)
+	router.GET("/jobs/:id", jobController.GetJob)
+	router.POST("/jobs/:id/cancel", jobController.CancelJob)
+	router.GET("/jobs/:id/artifact", jobController.DownloadJobArtifact)
+	return router, dir
+}
+
+func TestSubmitExportJobLinksIntoAPIVersion(t *testing.T) {
+	mockRepo := new(mocks.MockJobRepository)
+	jobController := controllers.NewJobController(mockRepo, nil, controllers.UploadLimits{MaxSize: 1 << 20, Timeout: time.Minute})
+	mockRepo.On("CreateJob", mock.Anything, mock.AnythingOfType("models.Job")).
+		Return(models.Job{ID: 8, Kind: models.JobKindExport, Status: models.JobStatusQueued, ArtifactPath: "songs.csv"}, nil)
+
+	router := gin.Default()
+	router.POST("/api/v1/jobs/export", jobController.SubmitExportJob)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/api/v1/jobs/export?format=csv", nil)
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, 202, w.Code)
+	assert.Equal(t, "/api/v1/jobs/8", w.Header().Get("Location"))
+	var body map[string]interface{}
+	json.Unmarshal(w.Body.Bytes(), &body)
+	assert.Equal(t, "/api/v1/jobs/8/artifact", body["artifact_url"])
+}
+
+func TestSubmitImportJobRejectsLargeUpload(t *testing.T) {
+	mockRepo := new(mocks.MockJobRepository)
+	router, dir := newJobRouter(t, mockRepo)
+
+	w := httptest.NewRecorder()
+	body := "group,song\n" + strings.Repeat("Muse,Uprising\n", 100000)
+	req, _ := http.NewRequest("POST", "/jobs/import", strings.NewReader(body))
+	req.Header.Set("Content-Type", "text/csv")
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, 413, w.Code)
+	assert.Contains(t, w.Body.String(), "payload_too_large")
+	files, _ := os.ReadDir(dir)
+	assert.Empty(t, files)
+	mockRepo.AssertNotCalled(t, "CreateJob", mock.Anything, mock.Anything)
+}
+
+func TestSubmitImportJob(t *testing.T) {
+	mockRepo := new(mocks.MockJobRepository)
+	router, dir := newJobRouter(t, mockRepo)
+
+	mockRepo.On("CreateJob", mock.Anything, mock.MatchedBy(func(job models.Job) bool {
+		data, err := os.ReadFile(job.InputPath)
+		return job.Kind == models.JobKindImport &&
+			job.Params.Format == services.FormatCSV &&
+			job.Params.Mapping["group"] == "artist" &&
+			job.Actor == "editor" &&
+			filepath.Dir(job.InputPath) == dir &&
+			err == nil && string(data) == "artist,song\nMuse,Uprising\n"
+	})).Return(models.Job{ID: 5, Kind: models.JobKindImport, Status: models.JobStatusQueued}, nil)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/jobs/import?map=group:artist", strings.NewReader("artist,song\nMuse,Uprising\n"))
+	req.Header.Set("Content-Type", "text/csv")
+	req.Header.Set("X-Actor", "editor")
+
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, 202, w.Code)
+	assert.Equal(t, "/jobs/5", w.Header().Get("Location"))
+	mockRepo.AssertExpectations(t)
+}
+
+func TestSubmitExportJob(t *testing.T) {
+	mockRepo := new(mocks.MockJobRepository)
+	router, _ := newJobRouter(t, mockRepo)
+
+	expectedJob := models.Job{
+		Kind:   models.JobKindExport,
+		Params: models.JobParams{Format: "csv", Filter: map[string]string{"group": "Muse"}, Gzip: true},
+		Actor:  "192.0.2.1",
+	}
+	mockRepo.On("CreateJob", mock.Anything, expectedJob).Return(models.Job{ID: 6, Kind: models.JobKindExport, Status: models.JobStatusQueued}, nil)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/jobs/export?format=csv&gzip=true&group=Muse", nil)
+	req.RemoteAddr = "192.0.2.1:1234"
+
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, 202, w.Code)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestGetJobWithArtifact(t *testing.T) {
+	mockRepo := new(mocks.MockJobRepository)
+	router, dir := newJobRouter(t, mockRepo)
+
+	artifact := filepath.Join(dir, "job-7-songs.csv")
+	assert.NoError(t, os.WriteFile(artifact, []byte("id,group\n"), 0o644))
+	job := models.Job{ID: 7, Kind: models.JobKindExport, Status: models.JobStatusSucceeded, RowsProcessed: 3,
+		ArtifactPath: artifact, ArtifactName: "songs.csv"}
+	mockRepo.On("GetJob", mock.Anything, int64(7)).Return(job, nil)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/jobs/7", nil)
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, 200, w.Code)
+	var body map[string]interface{}
+	json.Unmarshal(w.Body.Bytes(), &body)
+	assert.Equal(t, "/jobs/7/artifact", body["artifact_url"])
+	assert.Equal(t, float64(3), body["rows_processed"])
+	assert.NotContains(t, body, "ArtifactPath")
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/jobs/7/artifact", nil)
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, 200, w.Code)
+	assert.Equal(t, "id,group\n", w.Body.String())
+	assert.Contains(t, w.Header().Get("Content-Disposition"), `filename="songs.csv"`)
+}
+
+func TestDownloadArtifactOfRunningJob(t *testing.T) {
+	mockRepo := new(mocks.MockJobRepository)
+	router, _ := newJobRouter(t, mockRepo)
+	mockRepo.On("GetJob", mock.Anything, int64(8)).Return(models.Job{ID: 8, Status: models.JobStatusRunning}, nil)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/jobs/8/artifact", nil)
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, 409, w.Code)
+}
+
+func TestCancelJob(t *testing.T) {
+	mockRepo := new(mocks.MockJobRepository)
+	router, _ := newJobRouter(t, mockRepo)
+	mockRepo.On("CancelJob", mock.Anything, int64(9)).Return(models.Job{ID: 9, Status: models.JobStatusRunning, CancelRequested: true}, nil)
+	mockRepo.On("CancelJob", mock.Anything, int64(10)).Return(models.Job{}, repositories.ErrJobFinished)
+	mockRepo.On("CancelJob", mock.Anything, int64(11)).Return(models.Job{}, repositories.ErrJobNotFound)
+
+	for id, code := range map[string]int{"9": 202, "10": 409, "11": 404} {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/jobs/"+id+"/cancel", nil)
+		router.ServeHTTP(w, req)
+		assert.Equal(t, code, w.Code, "job %s", id)
+	}
+	mockRepo.AssertExpectations(t)
+}
@@ -0,0 +1,166 @@
+package controllers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lmd1e/song_library/app/controllers"
+	"github.com/lmd1e/song_library/app/middleware"
+	"github.com/lmd1e/song_library/app/models"
+	"github.com/lmd1e/song_library/app/tests/mocks"
+	"github.com/lmd1e/song_library/app/utils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func newLyricsRouter(mockRepo *mocks.MockSongRepository) *gin.Engine {
+	songController := controllers.NewSongController(mockRepo, new(mocks.MockSongRequest))
+	router := gin.Default()
+	router.GET("/songs/:id/text", middleware.Negotiate(slices.Concat(utils.RenderMediaTypes, utils.LyricMediaTypes)...), songController.GetSongText)
+	router.PUT("/songs/:id/text", middleware.Negotiate(utils.RenderMediaTypes...), songController.UpdateSongText)
+	return router
+}
+
+func getSongText(router *gin.Engine, accept string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/songs/1/text", nil)
+	req.Header.Set("Accept", accept)
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestGetSongTextFormats(t *testing.T) {
+	mockRepo := new(mocks.MockSongRepository)
+	lyrics := models.NewLyrics("One\nTwo", models.LyricTimings{time.Second, 2500 * time.Millisecond})
+	lyrics.End = 6 * time.Second
+	mockRepo.On("GetSongText", mock.Anything, 1, 10, 0).Return(lyrics, nil)
+	router := newLyricsRouter(mockRepo)
+
+	w := getSongText(router, "text/plain")
+	assert.Equal(t, 200, w.Code)
+	assert.Equal(t, "text/plain; charset=utf-8", w.Header().Get("Content-Type"))
+	assert.Equal(t, "One\nTwo\n", w.Body.String())
+
+	w = getSongText(router, "text/x-lrc")
+	assert.Equal(t, "text/x-lrc; charset=utf-8", w.Header().Get("Content-Type"))
+	assert.Equal(t, "[00:01.00]One\n[00:02.50]Two\n", w.Body.String())
+
+	w = getSongText(router, "text/vtt")
+	assert.Equal(t, "WEBVTT\n\n00:00:01.000 --> 00:00:02.500\nOne\n\n00:00:02.500 --> 00:00:06.000\nTwo\n", w.Body.String())
+
+	w = getSongText(router, "application/json")
+	assert.JSONEq(t, `{"text": "One\nTwo", "lines": [{"start_ms": 1000, "text": "One"}, {"start_ms": 2500, "text": "Two"}]}`, w.Body.String())
+}
+
+func TestGetSongTextNotSynced(t *testing.T) {
+	mockRepo := new(mocks.MockSongRepository)
+	mockRepo.On("GetSongText", mock.Anything, 1, 10, 0).Return(models.NewLyrics("One", nil), nil)
+	router := newLyricsRouter(mockRepo)
+
+	w := getSongText(router, "text/vtt")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Contains(t, w.Body.String(), `"code":"lyrics_not_synced"`)
+}
+
+func TestGetSongTextSyncedPastTheEnd(t *testing.T) {
+	mockRepo := new(mocks.MockSongRepository)
+	lyrics := models.NewLyrics("One\nTwo", models.LyricTimings{time.Second, 2500 * time.Millisecond})
+	mockRepo.On("GetSongText", mock.Anything, 1, 10, 0).Return(lyrics.Page(5, 10), nil)
+	router := newLyricsRouter(mockRepo)
+
+	w := getSongText(router, "text/x-lrc")
+	assert.Equal(t, 200, w.Code)
+	assert.Empty(t, w.Body.String())
+
+	w = getSongText(router, "text/vtt")
+	assert.Equal(t, 200, w.Code)
+	assert.Equal(t, "WEBVTT\n", w.Body.String())
+}
+
+func TestUpdateSongTextFromLRC(t *testing.T) {
+	mockRepo := new(mocks.MockSongRepository)
+	mockRepo.On("PatchSong", mock.Anything, 1, mock.MatchedBy(func(patch models.SongPatch) bool {
+		return *patch.Text == "One\nTwo" && patch.Version == 3 &&
+			slices.Equal(*patch.Timings, models.LyricTimings{time.Second, 2 * time.Second})
+	}), mock.AnythingOfType("models.AuditMeta")).Return(models.Song{ID: 1, Text: "One\nTwo", Version: 4}, nil)
+	router := newLyricsRouter(mockRepo)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("PUT", "/songs/1/text", strings.NewReader("[00:02.00]Two\r\n[00:01.00]One\r\n"))
+	req.Header.Set("Content-Type", "text/x-lrc")
+	req.Header.Set("If-Match", `"3"`)
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, 200, w.Code)
+	assert.Equal(t, `"4"`, w.Header().Get("ETag"))
+	mockRepo.AssertExpectations(t)
+}
+
+func TestUpdateSongTextPlainDropsTimings(t *testing.T) {
+	mockRepo := new(mocks.MockSongRepository)
+	mockRepo.On("PatchSong", mock.Anything, 1, mock.MatchedBy(func(patch models.SongPatch) bool {
+		return *patch.Text == "One\nTwo" && patch.Timings != nil && len(*patch.Timings) == 0
+	}), mock.AnythingOfType("models.AuditMeta")).Return(models.Song{ID: 1, Text: "One\nTwo", Version: 2}, nil)
+	router := newLyricsRouter(mockRepo)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("PUT", "/songs/1/text", strings.NewReader("One\nTwo\n"))
+	req.Header.Set("Content-Type", "text/plain; charset=utf-8")
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, 200, w.Code)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestUpdateSongTextRejectsBadUploads(t *testing.T) {
+	mockRepo := new(mocks.MockSongRepository)
+	router := newLyricsRouter(mockRepo)
+	put := func(contentType, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("PUT", "/songs/1/text", strings.NewReader(body))
+		req.Header.Set("Content-Type", contentType)
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	assert.Equal(t, http.StatusUnsupportedMediaType, put("application/json", `{"text": "One"}`).Code)
+	w := put("text/x-lrc", "[00:01.00]One\nTwo\n")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "line 2: expected a [mm:ss.xx] time tag")
+	assert.Equal(t, http.StatusBadRequest, put("text/plain", strings.Repeat("a", 20001)).Code)
+	mockRepo.AssertNotCalled(t, "PatchSong")
+}
+
+func TestGetSongSectionsFiltersByType(t *testing.T) {
+	mockRepo := new(mocks.MockSongRepository)
+	text := "[Verse 1]\nHello\n[Chorus]\nLa la\n[Chorus]"
+	mockRepo.On("GetSongSections", mock.Anything, 1).Return(models.LyricStructure{
+		Lines:    strings.Split(text, "\n"),
+		Sections: models.ParseLyricSections(text),
+	}, nil)
+	songController := controllers.NewSongController(mockRepo, new(mocks.MockSongRequest))
+	router := gin.Default()
+	router.GET("/songs/:id/sections", middleware.Negotiate(utils.RenderMediaTypes...), songController.GetSongSections)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/songs/1/sections?type=Chorus", nil)
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, 200, w.Code)
+	assert.JSONEq(t, `{"song_id": 1, "sections": [
+		{"type": "chorus", "label": "Chorus", "lines": ["La la"]},
+		{"type": "chorus", "label": "Chorus", "repeat": true, "lines": ["La la"]}
+	]}`, w.Body.String())
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/songs/1/sections?type=solo", nil)
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	mockRepo.AssertNumberOfCalls(t, "GetSongSections", 1)
+}
@@ -0,0 +1,77 @@
+package controllers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lmd1e/song_library/app/controllers"
+	"github.com/lmd1e/song_library/app/models"
+	"github.com/lmd1e/song_library/app/repositories"
+	"github.com/lmd1e/song_library/app/tests/mocks"
+	"github.com/lmd1e/song_library/app/utils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestDiffSongRevisions(t *testing.T) {
+	mockRepo := new(mocks.MockSongRepository)
+	songController := controllers.NewSongController(mockRepo, new(mocks.MockSongRequest))
+
+	mockRepo.On("GetSongRevision", mock.Anything, 1, 1).Return(models.SongRevision{SongID: 1, Revision: 1, Text: "verse 1\n\nchorus"}, nil)
+	mockRepo.On("GetSongRevision", mock.Anything, 1, 2).Return(models.SongRevision{SongID: 1, Revision: 2, Text: "verse 1\n\nverse 2\n\nchorus"}, nil)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/songs/1/revisions/diff?from=1&to=2&granularity=verse", nil)
+
+	router := gin.Default()
+	router.GET("/songs/:id/revisions/diff", songController.DiffSongRevisions)
+
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, 200, w.Code)
+	var diff models.RevisionDiff
+	json.Unmarshal(w.Body.Bytes(), &diff)
+	assert.Equal(t, "verse", diff.Granularity)
+	assert.Equal(t, []utils.DiffChange{
+		{Op: utils.DiffEqual, Text: "verse 1"},
+		{Op: utils.DiffInsert, Text: "verse 2"},
+		{Op: utils.DiffEqual, Text: "chorus"},
+	}, diff.Changes)
+
+	mockRepo.AssertExpectations(t)
+}
+
+func TestRestoreSongRevision(t *testing.T) {
+	mockRepo := new(mocks.MockSongRepository)
+	songController := controllers.NewSongController(mockRepo, new(mocks.MockSongRequest))
+
+	restored := models.Song{ID: 1, Group: "Old Group", Song: "Old Song", Text: "Old text"}
+	mockRepo.On("RestoreSongRevision", mock.Anything, 1, 3, mock.MatchedBy(func(meta models.AuditMeta) bool {
+		return meta.Actor == "editor"
+	})).Return(restored, nil)
+	mockRepo.On("RestoreSongRevision", mock.Anything, 1, 9, mock.AnythingOfType("models.AuditMeta")).Return(models.Song{}, repositories.ErrRevisionNotFound)
+
+	router := gin.Default()
+	router.POST("/songs/:id/revisions/:rev/restore", songController.RestoreSongRevision)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/songs/1/revisions/3/restore", nil)
+	req.Header.Set("X-Actor", "editor")
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, 200, w.Code)
+	var responseSong models.Song
+	json.Unmarshal(w.Body.Bytes(), &responseSong)
+	assert.Equal(t, restored.Text, responseSong.Text)
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("POST", "/songs/1/revisions/9/restore", nil)
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, 404, w.Code)
+
+	mockRepo.AssertExpectations(t)
+}
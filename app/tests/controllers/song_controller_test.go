@@ -2,29 +2,35 @@ package controllers
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/lmd1e/song_library/app/controllers"
+	"github.com/lmd1e/song_library/app/middleware"
 	"github.com/lmd1e/song_library/app/models"
+	"github.com/lmd1e/song_library/app/repositories"
 	"github.com/lmd1e/song_library/app/requests"
 	"github.com/lmd1e/song_library/app/tests/mocks"
+	"github.com/lmd1e/song_library/app/utils"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 )
 
 func TestGetSongs(t *testing.T) {
 	mockRepo := new(mocks.MockSongRepository)
-	songController := controllers.NewSongController(mockRepo)
+	songController := controllers.NewSongController(mockRepo, new(mocks.MockSongRequest))
 
 	expectedSongs := []models.Song{
 		{ID: 1, Group: "Test Group", Song: "Test Song", ReleaseDate: time.Now(), Text: "Test song text", Link: "https://example.com/test-song"},
 	}
-	mockRepo.On("GetSongs", mock.AnythingOfType("map[string]string"), 10, 0).Return(expectedSongs, nil)
+	mockRepo.On("GetSongs", mock.Anything, mock.AnythingOfType("map[string]string"), 10, 0).Return(expectedSongs, nil)
 
 	w := httptest.NewRecorder()
 	req, _ := http.NewRequest("GET", "/songs?group=Test%20Group", nil)
@@ -44,13 +50,93 @@ func TestGetSongs(t *testing.T) {
 	mockRepo.AssertExpectations(t)
 }
 
+func TestGetSongsAsCSV(t *testing.T) {
+	mockRepo := new(mocks.MockSongRepository)
+	songController := controllers.NewSongController(mockRepo, new(mocks.MockSongRequest))
+
+	songs := []models.Song{
+		{ID: 1, Group: "Muse", Song: "Uprising", ReleaseDate: time.Date(2009, 9, 7, 0, 0, 0, 0, time.UTC), Version: 1},
+	}
+	mockRepo.On("GetSongs", mock.Anything, mock.Anything, 10, 0).Return(songs, nil)
+
+	router := gin.Default()
+	router.GET("/songs", middleware.Negotiate(utils.RenderMediaTypes...), songController.GetSongs)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/songs", nil)
+	req.Header.Set("Accept", "text/csv")
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, 200, w.Code)
+	assert.Equal(t, "text/csv; charset=utf-8", w.Header().Get("Content-Type"))
+	assert.Contains(t, w.Body.String(), "1,Muse,Uprising,2009-09-07,")
+	csvETag := w.Header().Get("ETag")
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/songs", nil)
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, "application/json; charset=utf-8", w.Header().Get("Content-Type"))
+	assert.NotEqual(t, csvETag, w.Header().Get("ETag"))
+}
+
+func TestGetSongsQueryTimeout(t *testing.T) {
+	mockRepo := new(mocks.MockSongRepository)
+	songController := controllers.NewSongController(mockRepo, new(mocks.MockSongRequest))
+
+	timeoutErr := fmt.Errorf("%w: %w", repositories.ErrQueryTimeout, context.DeadlineExceeded)
+	mockRepo.On("GetSongs", mock.Anything, mock.Anything, 10, 0).Return([]models.Song(nil), timeoutErr)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/songs", nil)
+
+	router := gin.Default()
+	router.GET("/songs", songController.GetSongs)
+
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusGatewayTimeout, w.Code)
+	assert.Equal(t, utils.ProblemContentType, w.Header().Get("Content-Type"))
+	var problem utils.Problem
+	json.Unmarshal(w.Body.Bytes(), &problem)
+	assert.Equal(t, utils.Problem{
+		Type:     "about:blank",
+		Title:    "Gateway Timeout",
+		Status:   http.StatusGatewayTimeout,
+		Detail:   "Database query timed out",
+		Instance: "/songs",
+		Code:     "query_timeout",
+	}, problem)
+}
+
+func TestPatchSongInvalidPayloadListsField(t *testing.T) {
+	mockRepo := new(mocks.MockSongRepository)
+	songController := controllers.NewSongController(mockRepo, new(mocks.MockSongRequest))
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("PATCH", "/songs/1", bytes.NewBufferString(`{"group": 42}`))
+	req.Header.Set("Content-Type", "application/json")
+
+	router := gin.Default()
+	router.PATCH("/songs/:id", songController.PatchSong)
+
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	var problem utils.Problem
+	json.Unmarshal(w.Body.Bytes(), &problem)
+	assert.Equal(t, "invalid_payload", problem.Code)
+	assert.Equal(t, []utils.FieldError{{Field: "group", Code: "type", Message: "must be a string"}}, problem.Errors)
+	mockRepo.AssertNotCalled(t, "PatchSong")
+}
+
 func TestGetSongText(t *testing.T) {
 
 	mockRepo := new(mocks.MockSongRepository)
-	songController := controllers.NewSongController(mockRepo)
+	songController := controllers.NewSongController(mockRepo, new(mocks.MockSongRequest))
 
 	expectedText := "Test song text"
-	mockRepo.On("GetSongText", 1, 10, 0).Return(expectedText, nil)
+	mockRepo.On("GetSongText", mock.Anything, 1, 10, 0).Return(models.NewLyrics(expectedText, nil), nil)
 
 	w := httptest.NewRecorder()
 	req, _ := http.NewRequest("GET", "/songs/1/text", nil)
@@ -71,9 +157,9 @@ func TestGetSongText(t *testing.T) {
 func TestDeleteSong(t *testing.T) {
 
 	mockRepo := new(mocks.MockSongRepository)
-	songController := controllers.NewSongController(mockRepo)
+	songController := controllers.NewSongController(mockRepo, new(mocks.MockSongRequest))
 
-	mockRepo.On("DeleteSong", 1).Return(nil)
+	mockRepo.On("DeleteSong", mock.Anything, 1, 0, mock.AnythingOfType("models.AuditMeta")).Return(nil)
 
 	w := httptest.NewRecorder()
 	req, _ := http.NewRequest("DELETE", "/songs/1", nil)
@@ -94,7 +180,7 @@ func TestDeleteSong(t *testing.T) {
 func TestUpdateSong(t *testing.T) {
 
 	mockRepo := new(mocks.MockSongRepository)
-	songController := controllers.NewSongController(mockRepo)
+	songController := controllers.NewSongController(mockRepo, new(mocks.MockSongRequest))
 
 	updatedSong := models.Song{
 		ID:          1,
@@ -104,13 +190,13 @@ func TestUpdateSong(t *testing.T) {
 		Text:        "Updated song text",
 		Link:        "https://example.com/updated-song",
 	}
-	mockRepo.On("UpdateSong", mock.MatchedBy(func(song models.Song) bool {
+	mockRepo.On("UpdateSong", mock.Anything, mock.MatchedBy(func(song models.Song) bool {
 		return song.ID == updatedSong.ID &&
 			song.Group == updatedSong.Group &&
 			song.Song == updatedSong.Song &&
 			song.Text == updatedSong.Text &&
 			song.Link == updatedSong.Link
-	})).Return(nil)
+	}), mock.AnythingOfType("models.AuditMeta")).Return(updatedSong, nil)
 
 	w := httptest.NewRecorder()
 	jsonBody, _ := json.Marshal(updatedSong)
@@ -133,7 +219,8 @@ func TestUpdateSong(t *testing.T) {
 func TestAddSong(t *testing.T) {
 
 	mockRepo := new(mocks.MockSongRepository)
-	songController := controllers.NewSongController(mockRepo)
+	mockDetails := new(mocks.MockSongRequest)
+	songController := controllers.NewSongController(mockRepo, mockDetails)
 
 	newSong := models.Song{
 		Group:       "New Group",
@@ -142,12 +229,17 @@ func TestAddSong(t *testing.T) {
 		Text:        "New song text",
 		Link:        "https://example.com/new-song",
 	}
-	mockRepo.On("AddSong", mock.MatchedBy(func(song models.Song) bool {
+	mockRepo.On("AddSong", mock.Anything, mock.MatchedBy(func(song models.Song) bool {
 		return song.Group == newSong.Group &&
 			song.Song == newSong.Song &&
 			song.Text == newSong.Text &&
 			song.Link == newSong.Link
-	})).Return(nil)
+	}), mock.AnythingOfType("models.AuditMeta")).Return(newSong, nil)
+	mockDetails.On("GetSongDetail", mock.Anything, newSong.Group, newSong.Song).Return(&requests.SongDetail{
+		ReleaseDate: newSong.ReleaseDate,
+		Text:        newSong.Text,
+		Link:        newSong.Link,
+	}, nil)
 
 	w := httptest.NewRecorder()
 	songRequest := requests.AddSongRequest{
@@ -169,4 +261,49 @@ func TestAddSong(t *testing.T) {
 	assert.Equal(t, newSong.Song, responseSong.Song)
 
 	mockRepo.AssertExpectations(t)
+	mockDetails.AssertExpectations(t)
+}
+
+func TestAddSongRejectsInvalidSongDetails(t *testing.T) {
+	mockRepo := new(mocks.MockSongRepository)
+	mockDetails := new(mocks.MockSongRequest)
+	songController := controllers.NewSongController(mockRepo, mockDetails)
+
+	mockDetails.On("GetSongDetail", mock.Anything, "New Group", "New Song").Return(&requests.SongDetail{
+		ReleaseDate: time.Date(2006, 7, 16, 0, 0, 0, 0, time.UTC),
+		Link:        "ftp://example.com/" + strings.Repeat("a", 300),
+	}, nil)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/songs", bytes.NewBufferString(`{"group": "New Group", "song": "New Song"}`))
+
+	router := gin.Default()
+	router.POST("/songs", songController.AddSong)
+
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, 500, w.Code)
+	assert.Contains(t, w.Body.String(), "external_api_error")
+	assert.Contains(t, w.Body.String(), `"field":"link"`)
+	mockRepo.AssertNotCalled(t, "AddSong", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestImportSongsRejectsLargeUpload(t *testing.T) {
+	mockRepo := new(mocks.MockSongRepository)
+	songController := controllers.NewSongController(mockRepo, new(mocks.MockSongRequest))
+	songController.SetImportLimits(controllers.UploadLimits{MaxSize: 4 << 10, Timeout: time.Minute})
+
+	router := gin.Default()
+	router.POST("/songs/import", songController.ImportSongs)
+
+	w := httptest.NewRecorder()
+	body := "group,song\n" + strings.Repeat("Muse,Uprising\n", 100000)
+	req, _ := http.NewRequest("POST", "/songs/import", strings.NewReader(body))
+	req.Header.Set("Content-Type", "text/csv")
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, 413, w.Code)
+	assert.Equal(t, "application/problem+json", w.Header().Get("Content-Type"))
+	assert.Contains(t, w.Body.String(), "payload_too_large")
+	mockRepo.AssertNotCalled(t, "AddSongs", mock.Anything, mock.Anything, mock.Anything)
 }
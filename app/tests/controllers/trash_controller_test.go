@@ -0,0 +1,77 @@
+package controllers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lmd1e/song_library/app/controllers"
+	"github.com/lmd1e/song_library/app/models"
+	"github.com/lmd1e/song_library/app/repositories"
+	"github.com/lmd1e/song_library/app/tests/mocks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestDeleteSongAlwaysMovesToTrash(t *testing.T) {
+	mockRepo := new(mocks.MockSongRepository)
+	songController := controllers.NewSongController(mockRepo, new(mocks.MockSongRequest))
+
+	mockRepo.On("DeleteSong", mock.Anything, 1, 0, mock.AnythingOfType("models.AuditMeta")).Return(nil)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("DELETE", "/songs/1?permanent=true", nil)
+
+	router := gin.Default()
+	router.DELETE("/songs/:id", songController.DeleteSong)
+
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, 200, w.Code)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestGetDeletedSongs(t *testing.T) {
+	mockRepo := new(mocks.MockSongRepository)
+	songController := controllers.NewSongController(mockRepo, new(mocks.MockSongRequest))
+
+	deletedAt := time.Now()
+	mockRepo.On("GetDeletedSongs", mock.Anything, 10, 0).Return([]models.Song{{ID: 2, Group: "Trashed", DeletedAt: &deletedAt}}, nil)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/songs/trash", nil)
+
+	router := gin.Default()
+	router.GET("/songs/trash", songController.GetDeletedSongs)
+
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, 200, w.Code)
+	var songs []models.Song
+	json.Unmarshal(w.Body.Bytes(), &songs)
+	assert.Equal(t, 1, len(songs))
+	assert.NotNil(t, songs[0].DeletedAt)
+
+	mockRepo.AssertExpectations(t)
+}
+
+func TestRestoreSongNotInTrash(t *testing.T) {
+	mockRepo := new(mocks.MockSongRepository)
+	songController := controllers.NewSongController(mockRepo, new(mocks.MockSongRequest))
+
+	mockRepo.On("RestoreSong", mock.Anything, 3, mock.AnythingOfType("models.AuditMeta")).Return(models.Song{}, repositories.ErrSongNotFound)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/songs/3/restore", nil)
+
+	router := gin.Default()
+	router.POST("/songs/:id/restore", songController.RestoreSong)
+
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, 404, w.Code)
+	mockRepo.AssertExpectations(t)
+}
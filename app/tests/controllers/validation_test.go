@@ -0,0 +1,101 @@
+package controllers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lmd1e/song_library/app/controllers"
+	"github.com/lmd1e/song_library/app/models"
+	"github.com/lmd1e/song_library/app/requests"
+	"github.com/lmd1e/song_library/app/tests/mocks"
+	"github.com/lmd1e/song_library/app/utils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func serveSongBody(controller *controllers.SongController, method, body string) (*httptest.ResponseRecorder, utils.Problem) {
+	router := gin.Default()
+	router.POST("/songs", controller.AddSong)
+	router.PUT("/songs/:id", controller.UpdateSong)
+	router.PATCH("/songs/:id", controller.PatchSong)
+
+	path := "/songs/1"
+	if method == "POST" {
+		path = "/songs"
+	}
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w, req)
+
+	var problem utils.Problem
+	json.Unmarshal(w.Body.Bytes(), &problem)
+	return w, problem
+}
+
+func TestAddSongReportsAllViolations(t *testing.T) {
+	mockDetails := new(mocks.MockSongRequest)
+	controller := controllers.NewSongController(new(mocks.MockSongRepository), mockDetails)
+
+	w, problem := serveSongBody(controller, "POST", `{"group": "   ", "song": "`+strings.Repeat("я", 256)+`"}`)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Equal(t, []utils.FieldError{
+		{Field: "group", Code: "required", Message: "is required"},
+		{Field: "song", Code: "max", Message: "must be at most 255 characters long"},
+	}, problem.Errors)
+	mockDetails.AssertNotCalled(t, "GetSongDetail")
+}
+
+func TestAddSongNormalizesStrings(t *testing.T) {
+	mockRepo := new(mocks.MockSongRepository)
+	mockDetails := new(mocks.MockSongRequest)
+	controller := controllers.NewSongController(mockRepo, mockDetails)
+	// A decomposed "e\u0301" arrives composed as "\u00e9".
+	mockDetails.On("GetSongDetail", mock.Anything, "Beyonc\u00e9", "Halo").
+		Return(&requests.SongDetail{ReleaseDate: time.Date(2008, 1, 20, 0, 0, 0, 0, time.UTC)}, nil)
+	mockRepo.On("AddSong", mock.Anything, mock.AnythingOfType("models.Song"), mock.AnythingOfType("models.AuditMeta")).
+		Return(models.Song{ID: 1, Version: 1}, nil)
+
+	w, _ := serveSongBody(controller, "POST", `{"group": " Beyonce\u0301 ", "song": "Halo\n"}`)
+
+	assert.Equal(t, http.StatusCreated, w.Code)
+	mockDetails.AssertExpectations(t)
+}
+
+func TestUpdateSongValidation(t *testing.T) {
+	mockRepo := new(mocks.MockSongRepository)
+	controller := controllers.NewSongController(mockRepo, new(mocks.MockSongRequest))
+
+	w, problem := serveSongBody(controller, "PUT", `{"group": "Muse", "song": "Uprising", "link": "youtube", "release_date": "1700-01-01T00:00:00Z"}`)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Equal(t, "invalid_payload", problem.Code)
+	assert.Equal(t, []string{"release_date", "link"}, []string{problem.Errors[0].Field, problem.Errors[1].Field})
+	assert.Equal(t, "must be an http or https URL", problem.Errors[1].Message)
+	mockRepo.AssertNotCalled(t, "UpdateSong")
+}
+
+func TestPatchSongValidation(t *testing.T) {
+	mockRepo := new(mocks.MockSongRepository)
+	controller := controllers.NewSongController(mockRepo, new(mocks.MockSongRequest))
+
+	w, problem := serveSongBody(controller, "PATCH", `{"song": "", "text": "`+strings.Repeat("a", 20001)+`"}`)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Equal(t, []utils.FieldError{
+		{Field: "song", Code: "min", Message: "must not be empty"},
+		{Field: "text", Code: "max", Message: "must be at most 20000 characters long"},
+	}, problem.Errors)
+
+	// An empty link clears it.
+	mockRepo.On("PatchSong", mock.Anything, 1, mock.AnythingOfType("models.SongPatch"), mock.AnythingOfType("models.AuditMeta")).
+		Return(models.Song{ID: 1, Version: 2}, nil)
+	w, _ = serveSongBody(controller, "PATCH", `{"link": ""}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+}
@@ -0,0 +1,65 @@
+package database
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/lmd1e/song_library/app/database"
+	"github.com/lmd1e/song_library/app/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+// flakyDB fails the pings whose number is in down.
+type flakyDB struct {
+	mu    sync.Mutex
+	pings int
+	down  func(ping int) bool
+}
+
+func (db *flakyDB) PingContext(ctx context.Context) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	db.pings++
+	if db.down(db.pings) {
+		return errors.New("connection refused")
+	}
+	return nil
+}
+
+func TestConnectRetries(t *testing.T) {
+	db := &flakyDB{down: func(ping int) bool { return ping < 3 }}
+
+	err := database.Connect(context.Background(), db, 5*time.Second)
+
+	assert.NoError(t, err)
+	assert.Equal(t, 3, db.pings)
+}
+
+func TestConnectGivesUpAfterTimeout(t *testing.T) {
+	db := &flakyDB{down: func(int) bool { return true }}
+
+	start := time.Now()
+	err := database.Connect(context.Background(), db, 600*time.Millisecond)
+
+	assert.ErrorContains(t, err, "connection refused")
+	assert.Less(t, time.Since(start), 2*time.Second)
+}
+
+func TestMonitorLogsRecovery(t *testing.T) {
+	var buf bytes.Buffer
+	utils.Logger.SetOutput(&buf)
+	defer utils.Logger.SetOutput(os.Stdout)
+	db := &flakyDB{down: func(ping int) bool { return ping == 2 || ping == 3 }}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 130*time.Millisecond)
+	defer cancel()
+	database.Monitor(ctx, db, 20*time.Millisecond)
+
+	assert.Equal(t, 1, bytes.Count(buf.Bytes(), []byte("Lost connection to the database")))
+	assert.Equal(t, 1, bytes.Count(buf.Bytes(), []byte("Database connection restored")))
+}
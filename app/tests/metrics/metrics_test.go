@@ -0,0 +1,26 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/lmd1e/song_library/app/metrics"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSongCountCollector(t *testing.T) {
+	collector := metrics.NewSongCountCollector(func(context.Context) (int, error) { return 42, nil })
+	assert.Equal(t, float64(42), testutil.ToFloat64(collector))
+
+	failing := metrics.NewSongCountCollector(func(context.Context) (int, error) { return 0, errors.New("db down") })
+	_, err := testutil.CollectAndLint(failing)
+	assert.Error(t, err)
+}
+
+func TestOutcome(t *testing.T) {
+	assert.Equal(t, metrics.OutcomeOK, metrics.Outcome(nil))
+	assert.Equal(t, metrics.OutcomeTimeout, metrics.Outcome(context.DeadlineExceeded))
+	assert.Equal(t, metrics.OutcomeError, metrics.Outcome(errors.New("boom")))
+}
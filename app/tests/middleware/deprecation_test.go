@@ -0,0 +1,39 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lmd1e/song_library/app/middleware"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDeprecatedSetsHeaders(t *testing.T) {
+	deprecatedAt := time.Date(2026, 10, 19, 0, 0, 0, 0, time.UTC)
+	sunset := time.Date(2027, 4, 30, 0, 0, 0, 0, time.UTC)
+
+	router := gin.New()
+	handler := func(ctx *gin.Context) { ctx.Status(http.StatusOK) }
+	router.GET("/api/v1/songs/:id", handler)
+	router.Group("/", middleware.Deprecated(deprecatedAt, sunset, "/api/v1")).GET("/songs/:id", handler)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/songs/42", nil)
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, 200, w.Code)
+	assert.Equal(t, "@1792368000", w.Header().Get("Deprecation"))
+	assert.Equal(t, "Fri, 30 Apr 2027 00:00:00 GMT", w.Header().Get("Sunset"))
+	assert.Equal(t, `</api/v1/songs/42>; rel="successor-version"`, w.Header().Get("Link"))
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/api/v1/songs/42", nil)
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, 200, w.Code)
+	assert.Empty(t, w.Header().Get("Deprecation"))
+	assert.Empty(t, w.Header().Get("Sunset"))
+}
@@ -0,0 +1,106 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lmd1e/song_library/app/middleware"
+	"github.com/lmd1e/song_library/app/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRequestID(t *testing.T) {
+	router := gin.New()
+	router.Use(middleware.RequestID())
+	var seen string
+	router.GET("/songs/:id", func(ctx *gin.Context) {
+		seen = utils.RequestIDFromContext(ctx.Request.Context())
+		ctx.Status(http.StatusOK)
+	})
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/songs/1", nil)
+	req.Header.Set("X-Request-ID", "abc-123")
+	router.ServeHTTP(w, req)
+	assert.Equal(t, "abc-123", w.Header().Get("X-Request-ID"))
+	assert.Equal(t, "abc-123", seen)
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/songs/1", nil)
+	router.ServeHTTP(w, req)
+	assert.Len(t, w.Header().Get("X-Request-ID"), 32)
+	assert.Equal(t, w.Header().Get("X-Request-ID"), seen)
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/songs/1", nil)
+	req.Header.Set("X-Request-ID", "has spaces\n")
+	router.ServeHTTP(w, req)
+	assert.NotEqual(t, "has spaces\n", w.Header().Get("X-Request-ID"))
+	assert.Len(t, w.Header().Get("X-Request-ID"), 32)
+}
+
+func TestAccessLog(t *testing.T) {
+	var buf bytes.Buffer
+	utils.Logger.SetOutput(&buf)
+	defer utils.Logger.SetOutput(os.Stdout)
+
+	router := gin.New()
+	router.Use(middleware.RequestID(), middleware.AccessLog())
+	router.GET("/songs/:id", func(ctx *gin.Context) {
+		utils.LoggerFromContext(ctx.Request.Context()).Info("Handling song")
+		ctx.JSON(http.StatusNotFound, gin.H{"error": "Song not found"})
+	})
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/songs/7", nil)
+	req.Header.Set("X-Request-ID", "req-1")
+	req.Header.Set("X-Actor", "alice")
+	router.ServeHTTP(w, req)
+
+	lines := bytes.Split(bytes.TrimSpace(buf.Bytes()), []byte("\n"))
+	assert.Len(t, lines, 2)
+	var handler, access map[string]interface{}
+	assert.NoError(t, json.Unmarshal(lines[0], &handler))
+	assert.NoError(t, json.Unmarshal(lines[1], &access))
+
+	assert.Equal(t, "req-1", handler["request_id"])
+	assert.Equal(t, "/songs/:id", handler["route"])
+
+	assert.Equal(t, "Request served", access["msg"])
+	assert.Equal(t, "warning", access["level"])
+	assert.Equal(t, "req-1", access["request_id"])
+	assert.Equal(t, "GET", access["method"])
+	assert.Equal(t, "/songs/:id", access["route"])
+	assert.Equal(t, "/songs/7", access["path"])
+	assert.Equal(t, float64(404), access["status"])
+	assert.Equal(t, "alice", access["actor"])
+	assert.Contains(t, access, "latency_ms")
+}
+
+func TestRequireAdminToken(t *testing.T) {
+	router := gin.New()
+	router.Use(middleware.RequireAdminToken("secret"))
+	router.GET("/admin/logging", func(ctx *gin.Context) { ctx.Status(http.StatusOK) })
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/admin/logging", nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, 401, w.Code)
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/admin/logging", nil)
+	req.Header.Set("Authorization", "Bearer wrong")
+	router.ServeHTTP(w, req)
+	assert.Equal(t, 401, w.Code)
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/admin/logging", nil)
+	req.Header.Set("Authorization", "Bearer secret")
+	router.ServeHTTP(w, req)
+	assert.Equal(t, 200, w.Code)
+}
@@ -0,0 +1,34 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lmd1e/song_library/app/metrics"
+	"github.com/lmd1e/song_library/app/middleware"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMetrics(t *testing.T) {
+	router := gin.New()
+	router.Use(middleware.Metrics())
+	router.GET("/songs/:id", func(ctx *gin.Context) { ctx.Status(http.StatusTeapot) })
+	router.GET("/metrics", gin.WrapH(metrics.Handler()))
+
+	for _, path := range []string{"/songs/1", "/songs/2", "/missing"} {
+		req, _ := http.NewRequest("GET", path, nil)
+		router.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/metrics", nil)
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, 200, w.Code)
+	body := w.Body.String()
+	assert.Contains(t, body, `http_requests_total{method="GET",route="/songs/:id",status="418"} 2`)
+	assert.Contains(t, body, `http_requests_total{method="GET",route="unmatched",status="404"} 1`)
+	assert.Contains(t, body, `http_request_duration_seconds_count{method="GET",route="/songs/:id",status="418"} 2`)
+}
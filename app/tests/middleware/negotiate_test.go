@@ -0,0 +1,36 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lmd1e/song_library/app/middleware"
+	"github.com/lmd1e/song_library/app/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNegotiate(t *testing.T) {
+	router := gin.New()
+	router.GET("/songs", middleware.Negotiate(utils.RenderMediaTypes...), func(ctx *gin.Context) {
+		ctx.String(http.StatusOK, utils.MediaTypeFromContext(ctx.Request.Context()))
+	})
+	get := func(accept string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/songs", nil)
+		req.Header.Set("Accept", accept)
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	w := get("application/yaml, */*;q=0.1")
+	assert.Equal(t, 200, w.Code)
+	assert.Equal(t, utils.MediaTypeYAML, w.Body.String())
+	assert.Equal(t, "Accept", w.Header().Get("Vary"))
+
+	w = get("text/html")
+	assert.Equal(t, http.StatusNotAcceptable, w.Code)
+	assert.Equal(t, utils.ProblemContentType, w.Header().Get("Content-Type"))
+	assert.Contains(t, w.Body.String(), `"code":"not_acceptable"`)
+}
@@ -0,0 +1,34 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lmd1e/song_library/app/middleware"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRequireIfMatch(t *testing.T) {
+	router := gin.New()
+	router.Use(middleware.RequireIfMatch())
+	router.GET("/songs/:id", func(ctx *gin.Context) { ctx.Status(http.StatusOK) })
+	router.PUT("/songs/:id", func(ctx *gin.Context) { ctx.Status(http.StatusOK) })
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("PUT", "/songs/1", nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, 428, w.Code)
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("PUT", "/songs/1", nil)
+	req.Header.Set("If-Match", `"1"`)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, 200, w.Code)
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/songs/1", nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, 200, w.Code)
+}
@@ -0,0 +1,60 @@
+package middleware
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lmd1e/song_library/app/middleware"
+	"github.com/lmd1e/song_library/app/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+func newProblemRouter() *gin.Engine {
+	router := gin.New()
+	router.HandleMethodNotAllowed = true
+	router.Use(middleware.RequestID(), middleware.Recovery())
+	router.NoRoute(middleware.NoRoute())
+	router.NoMethod(middleware.NoMethod())
+	router.GET("/songs", func(ctx *gin.Context) { ctx.Status(http.StatusOK) })
+	router.GET("/panic", func(ctx *gin.Context) { panic("boom") })
+	return router
+}
+
+func serveProblem(t *testing.T, router *gin.Engine, method, path string) (*httptest.ResponseRecorder, utils.Problem) {
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(method, path, nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, utils.ProblemContentType, w.Header().Get("Content-Type"))
+	var problem utils.Problem
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &problem))
+	return w, problem
+}
+
+func TestNoRouteProblem(t *testing.T) {
+	w, problem := serveProblem(t, newProblemRouter(), "GET", "/nowhere")
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Equal(t, http.StatusNotFound, problem.Status)
+	assert.Equal(t, "route_not_found", problem.Code)
+	assert.Equal(t, "/nowhere", problem.Instance)
+	assert.Equal(t, w.Header().Get(middleware.RequestIDHeader), problem.RequestID)
+}
+
+func TestNoMethodProblem(t *testing.T) {
+	w, problem := serveProblem(t, newProblemRouter(), "DELETE", "/songs")
+
+	assert.Equal(t, http.StatusMethodNotAllowed, w.Code)
+	assert.Equal(t, "GET", w.Header().Get("Allow"))
+	assert.Equal(t, "method_not_allowed", problem.Code)
+}
+
+func TestRecoveryProblem(t *testing.T) {
+	w, problem := serveProblem(t, newProblemRouter(), "GET", "/panic")
+
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	assert.Equal(t, "internal_error", problem.Code)
+	assert.NotEmpty(t, problem.RequestID)
+}
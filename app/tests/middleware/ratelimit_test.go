@@ -0,0 +1,112 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lmd1e/song_library/app/middleware"
+	"github.com/stretchr/testify/assert"
+)
+
+func newRateLimitedRouter(config middleware.RateLimitConfig) *gin.Engine {
+	router := gin.New()
+	router.Use(middleware.RateLimit(middleware.NewMemoryRateLimitStore(), config))
+	router.GET("/songs", func(ctx *gin.Context) { ctx.Status(http.StatusOK) })
+	router.POST("/songs", func(ctx *gin.Context) { ctx.Status(http.StatusCreated) })
+	return router
+}
+
+func doRequest(router *gin.Engine, method, clientIP string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(method, "/songs", nil)
+	if clientIP != "" {
+		req.RemoteAddr = clientIP + ":1234"
+	}
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestRateLimitRejectsWhenBucketIsEmpty(t *testing.T) {
+	router := newRateLimitedRouter(middleware.RateLimitConfig{
+		Default: middleware.Limit{Burst: 2, Period: time.Minute},
+	})
+
+	w := doRequest(router, "GET", "")
+	assert.Equal(t, 200, w.Code)
+	assert.Equal(t, "2", w.Header().Get("RateLimit-Limit"))
+	assert.Equal(t, "1", w.Header().Get("RateLimit-Remaining"))
+
+	assert.Equal(t, 200, doRequest(router, "GET", "").Code)
+
+	w = doRequest(router, "GET", "")
+	assert.Equal(t, 429, w.Code)
+	assert.Equal(t, "0", w.Header().Get("RateLimit-Remaining"))
+	assert.Equal(t, "30", w.Header().Get("Retry-After"))
+}
+
+func TestRateLimitUsesRouteLimitsAndClientKeys(t *testing.T) {
+	routes, err := middleware.ParseRouteLimits("POST /songs=1/1h")
+	assert.NoError(t, err)
+	router := newRateLimitedRouter(middleware.RateLimitConfig{Routes: routes})
+
+	assert.Equal(t, 201, doRequest(router, "POST", "10.0.0.1").Code)
+	assert.Equal(t, 429, doRequest(router, "POST", "10.0.0.1").Code)
+	assert.Equal(t, 201, doRequest(router, "POST", "10.0.0.2").Code)
+
+	w := doRequest(router, "GET", "10.0.0.1")
+	assert.Equal(t, 200, w.Code)
+	assert.Empty(t, w.Header().Get("RateLimit-Limit"))
+}
+
+func TestRateLimitIgnoresUnauthenticatedAPIKeys(t *testing.T) {
+	router := newRateLimitedRouter(middleware.RateLimitConfig{
+		Default: middleware.Limit{Burst: 1, Period: time.Minute},
+	})
+	post := func(apiKey string) int {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/songs", nil)
+		req.Header.Set("X-API-Key", apiKey)
+		router.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	assert.Equal(t, 201, post("first"))
+	assert.Equal(t, 429, post("second"))
+}
+
+func TestRateLimitSharesRouteLimitAcrossVersions(t *testing.T) {
+	routes, err := middleware.ParseRouteLimits("POST /songs=1/1h;POST /api/v2/songs=5/1h")
+	assert.NoError(t, err)
+	router := gin.New()
+	router.Use(middleware.RateLimit(middleware.NewMemoryRateLimitStore(), middleware.RateLimitConfig{Routes: routes}))
+	for _, path := range []string{"/songs", "/api/v1/songs", "/api/v2/songs"} {
+		router.POST(path, func(ctx *gin.Context) { ctx.Status(http.StatusCreated) })
+	}
+	post := func(path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", path, nil)
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	assert.Equal(t, 201, post("/api/v1/songs").Code)
+	assert.Equal(t, 429, post("/songs").Code)
+
+	w := post("/api/v2/songs")
+	assert.Equal(t, 201, w.Code)
+	assert.Equal(t, "5", w.Header().Get("RateLimit-Limit"))
+}
+
+func TestParseLimit(t *testing.T) {
+	limit, err := middleware.ParseLimit("60/1m")
+	assert.NoError(t, err)
+	assert.Equal(t, middleware.Limit{Burst: 60, Period: time.Minute}, limit)
+
+	_, err = middleware.ParseLimit("60")
+	assert.Error(t, err)
+	_, err = middleware.ParseLimit("many/1m")
+	assert.Error(t, err)
+}
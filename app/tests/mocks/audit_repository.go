@@ -0,0 +1,16 @@
+package mocks
+
+import (
+	"context"
+	"github.com/lmd1e/song_library/app/models"
+	"github.com/stretchr/testify/mock"
+)
+
+type MockAuditRepository struct {
+	mock.Mock
+}
+
+func (m *MockAuditRepository) GetAuditEntries(ctx context.Context, filter models.AuditFilter, limit, offset int) ([]models.AuditEntry, error) {
+	args := m.Called(ctx, filter, limit, offset)
+	return args.Get(0).([]models.AuditEntry), args.Error(1)
+}
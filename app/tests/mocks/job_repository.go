@@ -0,0 +1,71 @@
+package mocks
+
+import (
+	"context"
+	"time"
+
+	"github.com/lmd1e/song_library/app/models"
+	"github.com/lmd1e/song_library/app/repositories"
+	"github.com/stretchr/testify/mock"
+)
+
+type MockJobRepository struct {
+	mock.Mock
+}
+
+func (m *MockJobRepository) CreateJob(ctx context.Context, job models.Job) (models.Job, error) {
+	args := m.Called(ctx, job)
+	return args.Get(0).(models.Job), args.Error(1)
+}
+
+func (m *MockJobRepository) GetJob(ctx context.Context, jobID int64) (models.Job, error) {
+	args := m.Called(ctx, jobID)
+	return args.Get(0).(models.Job), args.Error(1)
+}
+
+func (m *MockJobRepository) ClaimJob(ctx context.Context, lease time.Duration) (*models.Job, error) {
+	args := m.Called(ctx, lease)
+	job, _ := args.Get(0).(*models.Job)
+	return job, args.Error(1)
+}
+
+func (m *MockJobRepository) HeartbeatJob(ctx context.Context, job models.Job, lease time.Duration) (bool, error) {
+	args := m.Called(ctx, job, lease)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockJobRepository) UpdateJobProgress(ctx context.Context, job models.Job) (bool, error) {
+	args := m.Called(ctx, job)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockJobRepository) FinishJob(ctx context.Context, job models.Job) error {
+	args := m.Called(ctx, job)
+	return args.Error(0)
+}
+
+func (m *MockJobRepository) RequeueJob(ctx context.Context, job models.Job) error {
+	args := m.Called(ctx, job)
+	return args.Error(0)
+}
+
+func (m *MockJobRepository) CancelJob(ctx context.Context, jobID int64) (models.Job, error) {
+	args := m.Called(ctx, jobID)
+	return args.Get(0).(models.Job), args.Error(1)
+}
+
+func (m *MockJobRepository) PurgeFinishedJobs(ctx context.Context, finishedBefore time.Time) ([]models.Job, error) {
+	args := m.Called(ctx, finishedBefore)
+	jobs, _ := args.Get(0).([]models.Job)
+	return jobs, args.Error(1)
+}
+
+// WithTx runs fn with the mock itself and the song repository returned for
+// the call.
+func (m *MockJobRepository) WithTx(ctx context.Context, fn func(jobs repositories.JobRepository, songs repositories.SongRepository) error) error {
+	args := m.Called(ctx)
+	if err := args.Error(1); err != nil {
+		return err
+	}
+	return fn(m, args.Get(0).(repositories.SongRepository))
+}
@@ -1,7 +1,11 @@
 package mocks
 
 import (
+	"context"
+	"time"
+
 	"github.com/lmd1e/song_library/app/models"
+	"github.com/lmd1e/song_library/app/repositories"
 	"github.com/stretchr/testify/mock"
 )
 
@@ -9,27 +13,108 @@ type MockSongRepository struct {
 	mock.Mock
 }
 
-func (m *MockSongRepository) GetSongs(filter map[string]string, limit, offset int) ([]models.Song, error) {
-	args := m.Called(filter, limit, offset)
+func (m *MockSongRepository) GetSongs(ctx context.Context, filter map[string]string, limit, offset int) ([]models.Song, error) {
+	args := m.Called(ctx, filter, limit, offset)
 	return args.Get(0).([]models.Song), args.Error(1)
 }
 
-func (m *MockSongRepository) GetSongText(songID, limit, offset int) (string, error) {
-	args := m.Called(songID, limit, offset)
-	return args.String(0), args.Error(1)
+func (m *MockSongRepository) GetSongText(ctx context.Context, songID, limit, offset int) (models.Lyrics, error) {
+	args := m.Called(ctx, songID, limit, offset)
+	return args.Get(0).(models.Lyrics), args.Error(1)
 }
 
-func (m *MockSongRepository) DeleteSong(songID int) error {
-	args := m.Called(songID)
-	return args.Error(0)
+func (m *MockSongRepository) GetSongSections(ctx context.Context, songID int) (models.LyricStructure, error) {
+	args := m.Called(ctx, songID)
+	return args.Get(0).(models.LyricStructure), args.Error(1)
 }
 
-func (m *MockSongRepository) UpdateSong(song models.Song) error {
-	args := m.Called(song)
-	return args.Error(0)
+func (m *MockSongRepository) GetSong(ctx context.Context, songID int) (models.Song, error) {
+	args := m.Called(ctx, songID)
+	return args.Get(0).(models.Song), args.Error(1)
+}
+
+func (m *MockSongRepository) CountSongs(ctx context.Context) (int, error) {
+	args := m.Called(ctx)
+	return args.Int(0), args.Error(1)
 }
 
-func (m *MockSongRepository) AddSong(song models.Song) error {
-	args := m.Called(song)
+func (m *MockSongRepository) DeleteSong(ctx context.Context, songID, expectedVersion int, meta models.AuditMeta) error {
+	args := m.Called(ctx, songID, expectedVersion, meta)
 	return args.Error(0)
 }
+
+func (m *MockSongRepository) UpdateSong(ctx context.Context, song models.Song, meta models.AuditMeta) (models.Song, error) {
+	args := m.Called(ctx, song, meta)
+	return args.Get(0).(models.Song), args.Error(1)
+}
+
+func (m *MockSongRepository) PatchSong(ctx context.Context, songID int, patch models.SongPatch, meta models.AuditMeta) (models.Song, error) {
+	args := m.Called(ctx, songID, patch, meta)
+	return args.Get(0).(models.Song), args.Error(1)
+}
+
+func (m *MockSongRepository) AddSong(ctx context.Context, song models.Song, meta models.AuditMeta) (models.Song, error) {
+	args := m.Called(ctx, song, meta)
+	return args.Get(0).(models.Song), args.Error(1)
+}
+
+func (m *MockSongRepository) AddSongs(ctx context.Context, songs []models.Song, meta models.AuditMeta) ([]models.Song, error) {
+	args := m.Called(ctx, songs, meta)
+	return args.Get(0).([]models.Song), args.Error(1)
+}
+
+func (m *MockSongRepository) GetSongRevisions(ctx context.Context, songID, limit, offset int) ([]models.SongRevision, error) {
+	args := m.Called(ctx, songID, limit, offset)
+	return args.Get(0).([]models.SongRevision), args.Error(1)
+}
+
+func (m *MockSongRepository) GetSongRevision(ctx context.Context, songID, revision int) (models.SongRevision, error) {
+	args := m.Called(ctx, songID, revision)
+	return args.Get(0).(models.SongRevision), args.Error(1)
+}
+
+func (m *MockSongRepository) RestoreSongRevision(ctx context.Context, songID, revision int, meta models.AuditMeta) (models.Song, error) {
+	args := m.Called(ctx, songID, revision, meta)
+	return args.Get(0).(models.Song), args.Error(1)
+}
+
+func (m *MockSongRepository) GetDeletedSongs(ctx context.Context, limit, offset int) ([]models.Song, error) {
+	args := m.Called(ctx, limit, offset)
+	return args.Get(0).([]models.Song), args.Error(1)
+}
+
+func (m *MockSongRepository) RestoreSong(ctx context.Context, songID int, meta models.AuditMeta) (models.Song, error) {
+	args := m.Called(ctx, songID, meta)
+	return args.Get(0).(models.Song), args.Error(1)
+}
+
+func (m *MockSongRepository) PurgeDeletedSongs(ctx context.Context, deletedBefore time.Time, meta models.AuditMeta) (int, error) {
+	args := m.Called(ctx, deletedBefore, meta)
+	return args.Int(0), args.Error(1)
+}
+
+func (m *MockSongRepository) ExportSongs(ctx context.Context, filter map[string]string, fn func(models.Song) error) error {
+	args := m.Called(ctx, filter, fn)
+	if songs, ok := args.Get(0).([]models.Song); ok {
+		for _, song := range songs {
+			if err := fn(song); err != nil {
+				return err
+			}
+		}
+	}
+	return args.Error(1)
+}
+
+// WithTx runs fn with the repository returned for the call, or with the mock
+// itself when none is set.
+func (m *MockSongRepository) WithTx(ctx context.Context, fn func(repo repositories.SongRepository) error) error {
+	args := m.Called(ctx)
+	if err := args.Error(1); err != nil {
+		return err
+	}
+	repo, ok := args.Get(0).(repositories.SongRepository)
+	if !ok {
+		repo = m
+	}
+	return fn(repo)
+}
@@ -1,6 +1,8 @@
 package mocks
 
 import (
+	"context"
+
 	"github.com/lmd1e/song_library/app/requests"
 	"github.com/stretchr/testify/mock"
 )
@@ -9,7 +11,7 @@ type MockSongRequest struct {
 	mock.Mock
 }
 
-func (m *MockSongRequest) GetSongDetail(group, song string) (*requests.SongDetail, error) {
-	args := m.Called(group, song)
+func (m *MockSongRequest) GetSongDetail(ctx context.Context, group, song string) (*requests.SongDetail, error) {
+	args := m.Called(ctx, group, song)
 	return args.Get(0).(*requests.SongDetail), args.Error(1)
 }
@@ -0,0 +1,57 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/lmd1e/song_library/app/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseLyricSections(t *testing.T) {
+	text := "Ooh\n\n[Verse 1: Matt Bellamy]\nParanoia is in bloom\n\n" +
+		"[Chorus]\nThey will not force us\nThey will stop degrading us\n\n" +
+		"Pre-chorus:\nRise up\n[Spoken]\nYeah\n[Chorus]\n"
+
+	sections := models.ParseLyricSections(text)
+
+	assert.Equal(t, models.LyricSections{
+		{Type: models.SectionVerse, Start: 0, End: 1},
+		{Type: models.SectionVerse, Label: "Verse 1: Matt Bellamy", Start: 3, End: 4},
+		{Type: models.SectionChorus, Label: "Chorus", Start: 6, End: 8},
+		{Type: models.SectionPreChorus, Label: "Pre-chorus", Start: 10, End: 11},
+		{Type: models.SectionOther, Label: "Spoken", Start: 12, End: 13},
+		{Type: models.SectionChorus, Label: "Chorus", Start: 14, End: 14},
+	}, sections)
+}
+
+func TestParseLyricSectionsWithoutHeaders(t *testing.T) {
+	sections := models.ParseLyricSections("One\nTwo\n\n\nThree\n[00:12.00]\nNote: not a header")
+
+	assert.Equal(t, models.LyricSections{
+		{Type: models.SectionVerse, Start: 0, End: 2},
+		{Type: models.SectionVerse, Start: 4, End: 7},
+	}, sections)
+}
+
+func TestLyricStructureResolveRepeats(t *testing.T) {
+	text := "[Chorus]\nLa la\n[Verse]\nHello\n[Chorus]"
+	structure := models.LyricStructure{
+		Lines:    []string{"[Chorus]", "La la", "[Verse]", "Hello", "[Chorus]"},
+		Sections: models.ParseLyricSections(text),
+	}
+
+	resolved := structure.Resolve()
+
+	assert.Equal(t, []models.ResolvedSection{
+		{Type: models.SectionChorus, Label: "Chorus", Lines: []string{"La la"}},
+		{Type: models.SectionVerse, Label: "Verse", Lines: []string{"Hello"}},
+		{Type: models.SectionChorus, Label: "Chorus", Repeat: true, Lines: []string{"La la"}},
+	}, resolved)
+}
+
+func TestSectionType(t *testing.T) {
+	assert.Equal(t, models.SectionPreChorus, models.SectionType("Pre Chorus"))
+	assert.Equal(t, models.SectionChorus, models.SectionType("Hook x2"))
+	assert.Equal(t, models.SectionPostChorus, models.SectionType("post-chorus"))
+	assert.Equal(t, models.SectionOther, models.SectionType("Guitar Solo"))
+}
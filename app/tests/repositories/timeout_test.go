@@ -0,0 +1,33 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/lmd1e/song_library/app/repositories"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSongRepositoryQueryTimeout(t *testing.T) {
+	repo := repositories.NewSongRepository(openRecorder(t), time.Second)
+	ctx, cancel := context.WithDeadline(context.Background(), time.Now().Add(-time.Second))
+	defer cancel()
+
+	_, err := repo.GetSong(ctx, 1)
+
+	assert.ErrorIs(t, err, repositories.ErrQueryTimeout)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
+
+func TestSongRepositoryCancelledIsNotTimeout(t *testing.T) {
+	repo := repositories.NewSongRepository(openRecorder(t), time.Second)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := repo.GetSong(ctx, 1)
+
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.False(t, errors.Is(err, repositories.ErrQueryTimeout))
+}
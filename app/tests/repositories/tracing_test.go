@@ -0,0 +1,37 @@
+package repositories
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/lmd1e/song_library/app/repositories"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestSongRepositoryCallsAreTraced(t *testing.T) {
+	spans := tracetest.NewSpanRecorder()
+	previous := otel.GetTracerProvider()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(spans)))
+	defer otel.SetTracerProvider(previous)
+
+	repo := repositories.NewSongRepository(openRecorder(t), time.Second)
+	_, err := repo.GetSong(context.Background(), 1)
+	assert.Error(t, err)
+
+	ended := spans.Ended()
+	if assert.Len(t, ended, 1) {
+		span := ended[0]
+		assert.Equal(t, "SongRepository.GetSong", span.Name())
+		assert.Contains(t, span.Attributes(), attribute.String("db.system", "postgresql"))
+		assert.Contains(t, span.Attributes(), attribute.String("code.function", "GetSong"))
+		// The recorder returns no rows, which is a missing song rather than
+		// a failure.
+		assert.NotEqual(t, codes.Error, span.Status().Code)
+	}
+}
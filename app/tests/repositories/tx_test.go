@@ -0,0 +1,194 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/lmd1e/song_library/app/models"
+	"github.com/lmd1e/song_library/app/repositories"
+	"github.com/stretchr/testify/assert"
+)
+
+// recorder is a database/sql driver that records the statements it gets.
+type recorder struct {
+	mu  sync.Mutex
+	log []string
+}
+
+func (r *recorder) record(s string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.log = append(r.log, regexp.MustCompile(`sp_\d+`).ReplaceAllString(s, "sp"))
+}
+
+func (r *recorder) Open(string) (driver.Conn, error) { return &recorderConn{r}, nil }
+
+type recorderConn struct{ r *recorder }
+
+func (c *recorderConn) Prepare(query string) (driver.Stmt, error) {
+	return &recorderStmt{c.r, query}, nil
+}
+func (c *recorderConn) Close() error { return nil }
+func (c *recorderConn) Begin() (driver.Tx, error) {
+	c.r.record("BEGIN")
+	return &recorderTx{c.r}, nil
+}
+
+type recorderTx struct{ r *recorder }
+
+func (t *recorderTx) Commit() error   { t.r.record("COMMIT"); return nil }
+func (t *recorderTx) Rollback() error { t.r.record("ROLLBACK"); return nil }
+
+type recorderStmt struct {
+	r     *recorder
+	query string
+}
+
+func (s *recorderStmt) Close() error  { return nil }
+func (s *recorderStmt) NumInput() int { return -1 }
+func (s *recorderStmt) Exec([]driver.Value) (driver.Result, error) {
+	s.r.record(s.query)
+	return driver.RowsAffected(0), nil
+}
+func (s *recorderStmt) Query([]driver.Value) (driver.Rows, error) {
+	s.r.record(s.query)
+	return emptyRows{}, nil
+}
+
+type emptyRows struct{}
+
+func (emptyRows) Columns() []string         { return nil }
+func (emptyRows) Close() error              { return nil }
+func (emptyRows) Next([]driver.Value) error { return io.EOF }
+
+var (
+	registerOnce sync.Once
+	rec          = &recorder{}
+)
+
+func openRecorder(t *testing.T) *sql.DB {
+	registerOnce.Do(func() { sql.Register("recorder", rec) })
+	rec.mu.Lock()
+	rec.log = nil
+	rec.mu.Unlock()
+	db, err := sql.Open("recorder", "")
+	assert.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func TestWithTxCommits(t *testing.T) {
+	db := openRecorder(t)
+
+	err := repositories.WithTx(context.Background(), db, func(tx *sql.Tx) error {
+		_, err := tx.Exec("UPDATE songs")
+		return err
+	})
+
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"BEGIN", "UPDATE songs", "COMMIT"}, rec.log)
+}
+
+func TestWithTxRollsBackOnError(t *testing.T) {
+	db := openRecorder(t)
+	failure := errors.New("failure")
+
+	err := repositories.WithTx(context.Background(), db, func(tx *sql.Tx) error {
+		tx.Exec("UPDATE songs")
+		return failure
+	})
+
+	assert.ErrorIs(t, err, failure)
+	assert.Equal(t, []string{"BEGIN", "UPDATE songs", "ROLLBACK"}, rec.log)
+}
+
+func TestWithTxRollsBackOnPanic(t *testing.T) {
+	db := openRecorder(t)
+
+	assert.PanicsWithValue(t, "boom", func() {
+		repositories.WithTx(context.Background(), db, func(tx *sql.Tx) error {
+			tx.Exec("UPDATE songs")
+			panic("boom")
+		})
+	})
+	assert.Equal(t, []string{"BEGIN", "UPDATE songs", "ROLLBACK"}, rec.log)
+}
+
+func TestWithTxNestsWithSavepoints(t *testing.T) {
+	db := openRecorder(t)
+
+	err := repositories.WithTx(context.Background(), db, func(tx *sql.Tx) error {
+		if err := repositories.WithTx(context.Background(), tx, func(tx *sql.Tx) error {
+			_, err := tx.Exec("INSERT INTO songs")
+			return err
+		}); err != nil {
+			return err
+		}
+		err := repositories.WithTx(context.Background(), tx, func(tx *sql.Tx) error {
+			tx.Exec("DELETE FROM songs")
+			return errors.New("undo the delete only")
+		})
+		assert.Error(t, err)
+		return nil
+	})
+
+	assert.NoError(t, err)
+	assert.Equal(t, []string{
+		"BEGIN",
+		"SAVEPOINT sp", "INSERT INTO songs", "RELEASE SAVEPOINT sp",
+		"SAVEPOINT sp", "DELETE FROM songs", "ROLLBACK TO SAVEPOINT sp",
+		"COMMIT",
+	}, rec.log)
+}
+
+func TestSongRepositoryWithTxBindsRepository(t *testing.T) {
+	db := openRecorder(t)
+	repo := repositories.NewSongRepository(db, time.Second)
+
+	err := repo.WithTx(context.Background(), func(tx repositories.SongRepository) error {
+		_, err := tx.GetSongRevisions(context.Background(), 1, 10, 0)
+		return err
+	})
+
+	assert.NoError(t, err)
+	assert.Equal(t, "BEGIN", rec.log[0])
+	assert.Contains(t, rec.log[1], "FROM song_revisions")
+	assert.Equal(t, "COMMIT", rec.log[2])
+}
+
+func TestExportSongsClosesItsOwnCursor(t *testing.T) {
+	db := openRecorder(t)
+	repo := repositories.NewSongRepository(db, time.Second)
+
+	err := repo.WithTx(context.Background(), func(tx repositories.SongRepository) error {
+		for range 2 {
+			if err := tx.ExportSongs(context.Background(), nil, func(models.Song) error { return nil }); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+
+	assert.NoError(t, err)
+	var cursors []string
+	for _, statement := range rec.log {
+		if name, ok := strings.CutPrefix(statement, "DECLARE "); ok {
+			name, _, _ = strings.Cut(name, " ")
+			cursors = append(cursors, name)
+		}
+	}
+	assert.Len(t, cursors, 2)
+	assert.NotEqual(t, cursors[0], cursors[1])
+	for _, cursor := range cursors {
+		assert.Contains(t, rec.log, "CLOSE "+cursor)
+	}
+	assert.Equal(t, "COMMIT", rec.log[len(rec.log)-1])
+}
@@ -0,0 +1,41 @@
+package requests
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/lmd1e/song_library/app/models"
+	"github.com/lmd1e/song_library/app/requests"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSongRequestIgnoresIdentity(t *testing.T) {
+	var req requests.SongRequest
+	err := json.Unmarshal([]byte(`{"id": 99, "version": 7, "group": "Muse", "song": "Uprising"}`), &req)
+	assert.NoError(t, err)
+
+	song := req.ToSong(1, 3)
+
+	assert.Equal(t, models.Song{ID: 1, Group: "Muse", Song: "Uprising", Version: 3}, song)
+}
+
+func TestPatchSongRequestToPatch(t *testing.T) {
+	text := "la"
+	patch := requests.PatchSongRequest{Text: &text}.ToPatch(2)
+
+	assert.Equal(t, models.SongPatch{Text: &text, Version: 2}, patch)
+}
+
+func TestNewSongResponse(t *testing.T) {
+	deletedAt := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	song := models.Song{ID: 1, Group: "Muse", Song: "Uprising", Link: "https://example.com", DeletedAt: &deletedAt, Version: 4}
+
+	resp := requests.NewSongResponse(song)
+
+	assert.Equal(t, song.ID, resp.ID)
+	assert.Equal(t, song.Link, resp.Link)
+	assert.Equal(t, &deletedAt, resp.DeletedAt)
+	assert.Equal(t, 4, resp.Version)
+	assert.Len(t, requests.NewSongResponses([]models.Song{song, song}), 2)
+}
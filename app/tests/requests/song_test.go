@@ -0,0 +1,44 @@
+package requests
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/lmd1e/song_library/app/requests"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestGetSongDetailPropagatesTraceContext(t *testing.T) {
+	spans := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(spans))
+	previous := otel.GetTracerProvider()
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	defer otel.SetTracerProvider(previous)
+
+	var traceparent, group string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		traceparent = r.Header.Get("traceparent")
+		group = r.URL.Query().Get("group")
+		json.NewEncoder(w).Encode(requests.SongDetail{ReleaseDate: time.Date(2006, 7, 16, 0, 0, 0, 0, time.UTC), Text: "Ooh baby", Link: "https://example.com"})
+	}))
+	defer server.Close()
+
+	ctx, parent := provider.Tracer("test").Start(context.Background(), "AddSong")
+	detail, err := requests.NewSongDetailClient(server.URL+"/info").GetSongDetail(ctx, "Muse", "Supermassive Black Hole")
+	parent.End()
+
+	assert.NoError(t, err)
+	assert.Equal(t, "Ooh baby", detail.Text)
+	assert.Equal(t, "Muse", group)
+	assert.Contains(t, traceparent, parent.SpanContext().TraceID().String())
+	assert.GreaterOrEqual(t, len(spans.Ended()), 2)
+}
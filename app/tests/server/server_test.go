@@ -0,0 +1,77 @@
+package server
+
+import (
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lmd1e/song_library/app/config"
+	"github.com/lmd1e/song_library/app/middleware"
+	"github.com/lmd1e/song_library/app/server"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLiftDeadlines(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/slow", func(ctx *gin.Context) {
+		server.LiftDeadlines(ctx.Writer)
+		time.Sleep(200 * time.Millisecond)
+		ctx.String(http.StatusOK, "done")
+	})
+	cfg := config.Default().Server
+	cfg.WriteTimeout = 50 * time.Millisecond
+	srv := server.New(cfg, router)
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	go srv.Serve(listener)
+	defer srv.Close()
+
+	resp, err := http.Get("http://" + listener.Addr().String() + "/slow")
+
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	assert.NoError(t, err)
+	assert.Equal(t, "done", string(body))
+}
+
+func TestNewRouterIgnoresForwardedForFromUntrustedPeers(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	post := func(router *gin.Engine, peer, forwardedFor string) int {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/songs", nil)
+		req.RemoteAddr = peer + ":1234"
+		req.Header.Set("X-Forwarded-For", forwardedFor)
+		router.ServeHTTP(w, req)
+		return w.Code
+	}
+	newRouter := func(trustedProxies string) *gin.Engine {
+		cfg := config.Default().Server
+		cfg.TrustedProxies = trustedProxies
+		router, err := server.NewRouter(cfg)
+		require.NoError(t, err)
+		router.Use(middleware.RateLimit(middleware.NewMemoryRateLimitStore(), middleware.RateLimitConfig{
+			Default: middleware.Limit{Burst: 1, Period: time.Minute},
+		}))
+		router.POST("/songs", func(ctx *gin.Context) { ctx.Status(http.StatusCreated) })
+		return router
+	}
+
+	// A client spoofing X-Forwarded-For shares one bucket.
+	untrusted := newRouter("")
+	assert.Equal(t, 201, post(untrusted, "203.0.113.7", "1.1.1.1"))
+	assert.Equal(t, 429, post(untrusted, "203.0.113.7", "2.2.2.2"))
+	assert.Equal(t, 429, post(untrusted, "203.0.113.7", "3.3.3.3"))
+
+	// Behind a trusted proxy, every forwarded client has its own bucket.
+	trusted := newRouter("10.0.0.0/8")
+	assert.Equal(t, 201, post(trusted, "10.0.0.1", "1.1.1.1"))
+	assert.Equal(t, 201, post(trusted, "10.0.0.1", "2.2.2.2"))
+	assert.Equal(t, 429, post(trusted, "10.0.0.1", "2.2.2.2"))
+}
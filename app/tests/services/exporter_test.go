@@ -0,0 +1,87 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lmd1e/song_library/app/models"
+	"github.com/lmd1e/song_library/app/services"
+	"github.com/lmd1e/song_library/app/tests/mocks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+var exportedSongs = []models.Song{
+	{ID: 1, Group: "Muse", Song: "Uprising", ReleaseDate: time.Date(2009, 9, 7, 0, 0, 0, 0, time.UTC), Text: "They will not force us\nThey will stop degrading us", Link: "https://example.com/1"},
+	{ID: 2, Group: "Muse", Song: "Starlight", ReleaseDate: time.Date(2006, 9, 4, 0, 0, 0, 0, time.UTC), Text: "Far away", Link: "https://example.com/2",
+		Timings: models.LyricTimings{0, time.Second}, Version: 4, UpdatedAt: time.Date(2026, 1, 2, 0, 0, 0, 0, time.UTC)},
+}
+
+func TestExportCSV(t *testing.T) {
+	mockRepo := new(mocks.MockSongRepository)
+	filter := map[string]string{"group": "Muse"}
+	mockRepo.On("ExportSongs", mock.Anything, filter, mock.Anything).Return(exportedSongs, nil)
+
+	var buf bytes.Buffer
+	count, err := services.NewExporter(mockRepo).Export(context.Background(), &buf, services.ExportOptions{Format: services.FormatCSV, Filter: filter})
+
+	assert.NoError(t, err)
+	assert.Equal(t, 2, count)
+	records, err := csv.NewReader(&buf).ReadAll()
+	assert.NoError(t, err)
+	assert.Equal(t, services.ExportColumns, records[0])
+	assert.Equal(t, []string{"1", "Muse", "Uprising", "2009-09-07", exportedSongs[0].Text, "https://example.com/1"}, records[1])
+	assert.Len(t, records, 3)
+}
+
+func TestExportNDJSONAndJSON(t *testing.T) {
+	mockRepo := new(mocks.MockSongRepository)
+	mockRepo.On("ExportSongs", mock.Anything, mock.Anything, mock.Anything).Return(exportedSongs, nil)
+
+	var ndjson bytes.Buffer
+	_, err := services.NewExporter(mockRepo).Export(context.Background(), &ndjson, services.ExportOptions{Format: services.FormatNDJSON})
+	assert.NoError(t, err)
+	lines := strings.Split(strings.TrimSpace(ndjson.String()), "\n")
+	assert.Len(t, lines, 2)
+	assert.JSONEq(t, `{"id":2,"group":"Muse","song":"Starlight","release_date":"2006-09-04T00:00:00Z","text":"Far away","link":"https://example.com/2"}`, lines[1])
+
+	var array bytes.Buffer
+	_, err = services.NewExporter(mockRepo).Export(context.Background(), &array, services.ExportOptions{Format: services.FormatJSON})
+	assert.NoError(t, err)
+	var songs []models.Song
+	assert.NoError(t, json.Unmarshal(array.Bytes(), &songs))
+	assert.Equal(t, "Uprising", songs[0].Song)
+	assert.Len(t, songs, 2)
+	assert.NotContains(t, array.String(), "version")
+	assert.NotContains(t, array.String(), "updated_at")
+	assert.NotContains(t, array.String(), "timings")
+}
+
+func TestExportEmptyJSON(t *testing.T) {
+	mockRepo := new(mocks.MockSongRepository)
+	mockRepo.On("ExportSongs", mock.Anything, mock.Anything, mock.Anything).Return([]models.Song{}, nil)
+
+	var buf bytes.Buffer
+	count, err := services.NewExporter(mockRepo).Export(context.Background(), &buf, services.ExportOptions{Format: services.FormatJSON})
+
+	assert.NoError(t, err)
+	assert.Equal(t, 0, count)
+	assert.JSONEq(t, "[]", buf.String())
+}
+
+func TestExportFailureWritesNothing(t *testing.T) {
+	mockRepo := new(mocks.MockSongRepository)
+	mockRepo.On("ExportSongs", mock.Anything, mock.Anything, mock.Anything).Return(nil, errors.New("db error"))
+
+	var buf bytes.Buffer
+	_, err := services.NewExporter(mockRepo).Export(context.Background(), &buf, services.ExportOptions{Format: services.FormatJSON})
+
+	assert.Error(t, err)
+	assert.Equal(t, 0, buf.Len())
+}
@@ -0,0 +1,37 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/lmd1e/song_library/app/services"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHealthChecker(t *testing.T) {
+	healthy := services.HealthCheck{Name: "database", Check: func(context.Context) error { return nil }}
+	failing := services.HealthCheck{Name: "migrations", Check: func(context.Context) error {
+		return errors.New("schema version 7 is behind the expected version 8")
+	}}
+
+	report := services.NewHealthChecker(healthy).Check(context.Background())
+	assert.Equal(t, services.HealthStatusUp, report.Status)
+	assert.Equal(t, services.HealthStatusUp, report.Checks["database"].Status)
+
+	report = services.NewHealthChecker(healthy, failing).Check(context.Background())
+	assert.Equal(t, services.HealthStatusDown, report.Status)
+	assert.Equal(t, services.HealthStatusUp, report.Checks["database"].Status)
+	assert.Equal(t, services.HealthStatusDown, report.Checks["migrations"].Status)
+	assert.Equal(t, "schema version 7 is behind the expected version 8", report.Checks["migrations"].Error)
+}
+
+func TestHealthCheckerDraining(t *testing.T) {
+	checker := services.NewHealthChecker(services.HealthCheck{Name: "database", Check: func(context.Context) error { return nil }})
+	checker.SetDraining()
+
+	report := checker.Check(context.Background())
+	assert.Equal(t, services.HealthStatusDown, report.Status)
+	assert.Equal(t, services.HealthStatusUp, report.Checks["database"].Status)
+	assert.Equal(t, services.HealthStatusDown, report.Checks["shutdown"].Status)
+}
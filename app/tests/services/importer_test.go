@@ -0,0 +1,222 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lmd1e/song_library/app/models"
+	"github.com/lmd1e/song_library/app/requests"
+	"github.com/lmd1e/song_library/app/services"
+	"github.com/lmd1e/song_library/app/tests/mocks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestImportCSVWithMappingAndRowErrors(t *testing.T) {
+	mockRepo := new(mocks.MockSongRepository)
+	importer := services.NewImporter(mockRepo, new(mocks.MockSongRequest))
+
+	csv := "artist,title,release_date,text\n" +
+		"Muse,Uprising,2009-09-07,They will not force us\n" +
+		",No Group,2009-09-07,\n" +
+		"Muse,Resistance,07.09.2009,Is our secret safe tonight\n" +
+		"Muse,Bad Date,someday,\n"
+
+	mockRepo.On("AddSongs", mock.Anything, mock.MatchedBy(func(songs []models.Song) bool {
+		return len(songs) == 2 &&
+			songs[0].Group == "Muse" && songs[0].Song == "Uprising" &&
+			songs[1].ReleaseDate.Equal(time.Date(2009, 9, 7, 0, 0, 0, 0, time.UTC))
+	}), mock.AnythingOfType("models.AuditMeta")).Return([]models.Song{}, nil).Once()
+
+	result, err := importer.Import(context.Background(), strings.NewReader(csv), services.ImportOptions{
+		Format:  services.FormatCSV,
+		Mapping: map[string]string{"group": "artist", "song": "title"},
+	}, models.AuditMeta{Actor: "importer"})
+
+	assert.NoError(t, err)
+	assert.Equal(t, 4, result.Rows)
+	assert.Equal(t, 2, result.Imported)
+	assert.Equal(t, 2, result.Failed)
+	assert.Equal(t, []services.ImportRowError{
+		{Row: 2, Field: "group", Error: "is required"},
+		{Row: 4, Field: "release_date", Error: `invalid date "someday", expected YYYY-MM-DD, DD.MM.YYYY or RFC3339`},
+	}, result.Errors)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestImportValidatesRowsLikeSongBodies(t *testing.T) {
+	mockRepo := new(mocks.MockSongRepository)
+	importer := services.NewImporter(mockRepo, new(mocks.MockSongRequest))
+
+	csv := "group,song,release_date,link\n" +
+		strings.Repeat("a", 256) + ",Too Long,2009-09-07,\n" +
+		"Muse,Bad Link,2009-09-07,ftp://example.com\n" +
+		"Muse,Too Old,1850-01-01,\n" +
+		" Muse ,Uprising,2009-09-07,https://example.com\n"
+
+	mockRepo.On("AddSongs", mock.Anything, mock.MatchedBy(func(songs []models.Song) bool {
+		return len(songs) == 1 && songs[0].Group == "Muse"
+	}), mock.AnythingOfType("models.AuditMeta")).Return([]models.Song{}, nil).Once()
+
+	result, err := importer.Import(context.Background(), strings.NewReader(csv), services.ImportOptions{
+		Format: services.FormatCSV,
+	}, models.AuditMeta{})
+
+	assert.NoError(t, err)
+	assert.Equal(t, 1, result.Imported)
+	assert.Equal(t, 3, result.Failed)
+	assert.Equal(t, []services.ImportRowError{
+		{Row: 1, Field: "group", Error: "must be at most 255 characters long"},
+		{Row: 2, Field: "link", Error: "must be an http or https URL"},
+		{Row: 3, Field: "release_date", Error: "must be between 1860-01-01 and 1 year from now"},
+	}, result.Errors)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestImportNDJSONInBatchesWithEnrichment(t *testing.T) {
+	mockRepo := new(mocks.MockSongRepository)
+	mockDetails := new(mocks.MockSongRequest)
+	importer := services.NewImporter(mockRepo, mockDetails)
+
+	releaseDate := time.Date(2006, 7, 16, 0, 0, 0, 0, time.UTC)
+	mockDetails.On("GetSongDetail", mock.Anything, "Muse", "Starlight").Return(&requests.SongDetail{ReleaseDate: releaseDate, Text: "Far away", Link: "https://example.com"}, nil)
+	mockDetails.On("GetSongDetail", mock.Anything, "Muse", "Unknown").Return((*requests.SongDetail)(nil), errors.New("not found"))
+	mockRepo.On("AddSongs", mock.Anything, mock.MatchedBy(func(songs []models.Song) bool {
+		return len(songs) == 1
+	}), mock.AnythingOfType("models.AuditMeta")).Return([]models.Song{}, nil).Twice()
+
+	ndjson := `{"group": "Muse", "song": "Starlight"}
+{"group": "Muse", "song": "Unknown"}
+not json
+
+{"group": "Muse", "song": "Hysteria", "release_date": "2003-12-01", "text": "It's bugging me", "link": "https://example.com/h"}
+`
+	result, err := importer.Import(context.Background(), strings.NewReader(ndjson), services.ImportOptions{
+		Format:    services.FormatNDJSON,
+		Enrich:    true,
+		BatchSize: 1,
+	}, models.AuditMeta{})
+
+	assert.NoError(t, err)
+	assert.Equal(t, 4, result.Rows)
+	assert.Equal(t, 2, result.Imported)
+	assert.Equal(t, 2, result.Failed)
+	assert.Equal(t, 2, result.Errors[0].Row)
+	assert.Equal(t, 3, result.Errors[1].Row)
+	mockRepo.AssertExpectations(t)
+	mockDetails.AssertExpectations(t)
+}
+
+func TestImportDryRunDoesNotWrite(t *testing.T) {
+	mockRepo := new(mocks.MockSongRepository)
+	mockDetails := new(mocks.MockSongRequest)
+	importer := services.NewImporter(mockRepo, mockDetails)
+
+	result, err := importer.Import(context.Background(), strings.NewReader("group,song\nMuse,Uprising\n"), services.ImportOptions{
+		Format: services.FormatCSV,
+		Enrich: true,
+		DryRun: true,
+	}, models.AuditMeta{})
+
+	assert.NoError(t, err)
+	assert.True(t, result.DryRun)
+	assert.Equal(t, 1, result.Imported)
+	mockRepo.AssertNotCalled(t, "AddSongs", mock.Anything, mock.Anything)
+	mockDetails.AssertNotCalled(t, "GetSongDetail", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestParseColumnMapping(t *testing.T) {
+	mapping, err := services.ParseColumnMapping("group:artist, song:title")
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"group": "artist", "song": "title"}, mapping)
+
+	_, err = services.ParseColumnMapping("id:key")
+	assert.Error(t, err)
+}
+
+func TestImportReportsProgressAndResumes(t *testing.T) {
+	mockRepo := new(mocks.MockSongRepository)
+	importer := services.NewImporter(mockRepo, new(mocks.MockSongRequest))
+	mockRepo.On("AddSongs", mock.Anything, mock.Anything, mock.Anything).Return([]models.Song{}, nil)
+
+	csv := "group,song,release_date\n" +
+		"A,1,2020-01-01\n" +
+		"B,,2020-01-01\n" +
+		"C,3,2020-01-01\n" +
+		"D,4,2020-01-01\n"
+	var checkpoints []services.ImportResult
+	result, err := importer.Import(context.Background(), strings.NewReader(csv), services.ImportOptions{
+		Format:    services.FormatCSV,
+		BatchSize: 2,
+		Checkpoint: &services.ImportResult{
+			Rows: 1, Imported: 1,
+		},
+		Progress: func(r services.ImportResult) error {
+			checkpoints = append(checkpoints, r)
+			return nil
+		},
+	}, models.AuditMeta{})
+
+	assert.NoError(t, err)
+	assert.Equal(t, 4, result.Rows)
+	assert.Equal(t, 3, result.Imported)
+	assert.Equal(t, 1, result.Failed)
+	assert.Equal(t, 2, result.Errors[0].Row)
+	assert.Len(t, checkpoints, 2)
+	assert.Equal(t, 3, checkpoints[0].Rows)
+	assert.Equal(t, 2, checkpoints[0].Imported)
+	mockRepo.AssertNumberOfCalls(t, "AddSongs", 2)
+}
+
+func TestImportStopsWhenProgressFails(t *testing.T) {
+	mockRepo := new(mocks.MockSongRepository)
+	importer := services.NewImporter(mockRepo, new(mocks.MockSongRequest))
+	mockRepo.On("AddSongs", mock.Anything, mock.Anything, mock.Anything).Return([]models.Song{}, nil)
+
+	stop := errors.New("cancelled")
+	_, err := importer.Import(context.Background(), strings.NewReader("group,song,release_date\nA,1,2020-01-01\nB,2,2020-01-01\n"), services.ImportOptions{
+		Format:    services.FormatCSV,
+		BatchSize: 1,
+		Progress:  func(services.ImportResult) error { return stop },
+	}, models.AuditMeta{})
+
+	assert.ErrorIs(t, err, stop)
+	mockRepo.AssertNumberOfCalls(t, "AddSongs", 1)
+}
+
+func TestImportStoresBatchesWithTheirTotals(t *testing.T) {
+	mockRepo := new(mocks.MockSongRepository)
+	importer := services.NewImporter(mockRepo, new(mocks.MockSongRequest))
+
+	csv := "group,song,release_date\n" +
+		"A,1,2020-01-01\n" +
+		"B,,2020-01-01\n" +
+		"C,3,2020-01-01\n"
+	var batches [][]string
+	var totals []services.ImportResult
+	result, err := importer.Import(context.Background(), strings.NewReader(csv), services.ImportOptions{
+		Format:    services.FormatCSV,
+		BatchSize: 2,
+		Store: func(songs []models.Song, result services.ImportResult) error {
+			var names []string
+			for _, song := range songs {
+				names = append(names, song.Song)
+			}
+			batches = append(batches, names)
+			totals = append(totals, result)
+			return nil
+		},
+	}, models.AuditMeta{})
+
+	assert.NoError(t, err)
+	assert.Equal(t, [][]string{{"1"}, {"3"}}, batches)
+	assert.Equal(t, 2, totals[0].Rows)
+	assert.Equal(t, 1, totals[0].Imported)
+	assert.Equal(t, 3, totals[1].Rows)
+	assert.Equal(t, 2, totals[1].Imported)
+	assert.Equal(t, 2, result.Imported)
+	mockRepo.AssertNotCalled(t, "AddSongs", mock.Anything, mock.Anything, mock.Anything)
+}
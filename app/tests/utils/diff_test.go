@@ -0,0 +1,96 @@
+package utils
+
+import (
+	"math/rand"
+	"strconv"
+	"testing"
+
+	"github.com/lmd1e/song_library/app/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDiffLines(t *testing.T) {
+	before := utils.SplitLines("one\ntwo\nthree")
+	after := utils.SplitLines("one\n2\nthree\nfour")
+
+	assert.Equal(t, []utils.DiffChange{
+		{Op: utils.DiffEqual, Text: "one"},
+		{Op: utils.DiffDelete, Text: "two"},
+		{Op: utils.DiffInsert, Text: "2"},
+		{Op: utils.DiffEqual, Text: "three"},
+		{Op: utils.DiffInsert, Text: "four"},
+	}, utils.Diff(before, after))
+}
+
+func TestDiffIsShortestEditScript(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	randomLines := func() []string {
+		lines := make([]string, rng.Intn(12))
+		for i := range lines {
+			lines[i] = strconv.Itoa(rng.Intn(4))
+		}
+		return lines
+	}
+	for i := 0; i < 500; i++ {
+		a, b := randomLines(), randomLines()
+		changes := utils.Diff(a, b)
+
+		var gotA, gotB []string
+		edits := 0
+		for _, change := range changes {
+			if change.Op != utils.DiffInsert {
+				gotA = append(gotA, change.Text)
+			}
+			if change.Op != utils.DiffDelete {
+				gotB = append(gotB, change.Text)
+			}
+			if change.Op != utils.DiffEqual {
+				edits++
+			}
+		}
+		assert.Equal(t, len(a), len(gotA))
+		assert.Equal(t, len(b), len(gotB))
+		if len(a) > 0 {
+			assert.Equal(t, a, gotA)
+		}
+		if len(b) > 0 {
+			assert.Equal(t, b, gotB)
+		}
+		assert.Equal(t, len(a)+len(b)-2*lcsLength(a, b), edits, "%v -> %v", a, b)
+	}
+}
+
+func TestDiffLargeTexts(t *testing.T) {
+	a := make([]string, 10000)
+	b := make([]string, 10000)
+	for i := range a {
+		a[i] = strconv.Itoa(i % 7)
+		b[i] = strconv.Itoa(i % 11)
+	}
+
+	changes := utils.Diff(a, b)
+	assert.NotEmpty(t, changes)
+}
+
+func lcsLength(a, b []string) int {
+	prev := make([]int, len(b)+1)
+	for i := range a {
+		cur := make([]int, len(b)+1)
+		for j := range b {
+			if a[i] == b[j] {
+				cur[j+1] = prev[j] + 1
+			} else {
+				cur[j+1] = max(prev[j+1], cur[j])
+			}
+		}
+		prev = cur
+	}
+	return prev[len(b)]
+}
+
+func TestSplitVerses(t *testing.T) {
+	text := "line 1\nline 2\n\n  \nchorus 1\r\nchorus 2\n"
+
+	assert.Equal(t, []string{"line 1\nline 2", "chorus 1\nchorus 2"}, utils.SplitVerses(text))
+	assert.Nil(t, utils.SplitVerses("\n\n"))
+}
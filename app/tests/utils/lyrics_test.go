@@ -0,0 +1,62 @@
+package utils
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lmd1e/song_library/app/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseLRC(t *testing.T) {
+	lrc := "\ufeff[ar:Muse]\n[ti:Uprising]\n[offset:+500]\n\n" +
+		"[00:12.50]Paranoia is in bloom\n" +
+		"[00:20.1][01:05.250] They will not force us\n" +
+		"[00:16]The PR transmissions will resume\n"
+
+	lines, starts, err := utils.ParseLRC(strings.NewReader(lrc))
+
+	assert.NoError(t, err)
+	assert.Equal(t, []string{
+		"Paranoia is in bloom",
+		"The PR transmissions will resume",
+		"They will not force us",
+		"They will not force us",
+	}, lines)
+	assert.Equal(t, []time.Duration{
+		12 * time.Second,
+		15500 * time.Millisecond,
+		19600 * time.Millisecond,
+		64750 * time.Millisecond,
+	}, starts)
+}
+
+func TestParseLRCErrors(t *testing.T) {
+	_, _, err := utils.ParseLRC(strings.NewReader("[00:01.00]One\nTwo\n"))
+	assert.EqualError(t, err, "line 2: expected a [mm:ss.xx] time tag")
+
+	_, _, err = utils.ParseLRC(strings.NewReader("[00:75.00]One\n"))
+	assert.EqualError(t, err, "line 1: invalid time tag [00:75]")
+
+	_, _, err = utils.ParseLRC(strings.NewReader("[ar:Muse]\n"))
+	assert.EqualError(t, err, "no timed lines")
+}
+
+func TestFormatLRC(t *testing.T) {
+	lrc := utils.FormatLRC([]string{"One", "Two"}, []time.Duration{1500 * time.Millisecond, 61*time.Second + 20*time.Millisecond})
+
+	assert.Equal(t, "[00:01.50]One\n[01:01.02]Two\n", string(lrc))
+}
+
+func TestFormatWebVTT(t *testing.T) {
+	vtt := utils.FormatWebVTT(
+		[]string{"Rock & roll", "", "<Outro>"},
+		[]time.Duration{time.Second, 4 * time.Second, 3725 * time.Second},
+		0,
+	)
+
+	assert.Equal(t, "WEBVTT\n"+
+		"\n00:00:01.000 --> 00:00:04.000\nRock &amp; roll\n"+
+		"\n01:02:05.000 --> 01:02:10.000\n&lt;Outro&gt;\n", string(vtt))
+}
@@ -0,0 +1,25 @@
+package utils
+
+import (
+	"testing"
+
+	"github.com/lmd1e/song_library/app/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNormalizeStrings(t *testing.T) {
+	group := " Sigur Rós\t"
+	body := struct {
+		Name    string
+		Group   *string
+		Tags    []string
+		private string
+	}{Name: "A\u030angstro\u0308m ", Group: &group, Tags: []string{" a ", "b"}, private: " kept "}
+
+	utils.NormalizeStrings(&body)
+
+	assert.Equal(t, "\u00c5ngstr\u00f6m", body.Name)
+	assert.Equal(t, "Sigur Rós", *body.Group)
+	assert.Equal(t, []string{"a", "b"}, body.Tags)
+	assert.Equal(t, " kept ", body.private)
+}
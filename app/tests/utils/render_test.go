@@ -0,0 +1,58 @@
+package utils
+
+import (
+	"testing"
+	"time"
+
+	"github.com/lmd1e/song_library/app/models"
+	"github.com/lmd1e/song_library/app/requests"
+	"github.com/lmd1e/song_library/app/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNegotiateMediaType(t *testing.T) {
+	offered := utils.RenderMediaTypes
+	cases := map[string]string{
+		"":                                    utils.MediaTypeJSON,
+		"*/*":                                 utils.MediaTypeJSON,
+		"application/xml":                     utils.MediaTypeXML,
+		"text/*":                              utils.MediaTypeCSV,
+		"text/csv;q=0.5, application/yaml":    utils.MediaTypeYAML,
+		"application/*;q=0.2, text/csv;q=0.9": utils.MediaTypeCSV,
+		"*/*;q=0.1, application/json;q=0":     utils.MediaTypeXML,
+		"text/html, image/png":                "",
+		"application/json;q=0, application/*;q=0": "",
+	}
+	for accept, expected := range cases {
+		assert.Equal(t, expected, utils.NegotiateMediaType(accept, offered), accept)
+	}
+}
+
+func TestRenderSongs(t *testing.T) {
+	songs := requests.NewSongResponses([]models.Song{{
+		ID: 1, Group: "Muse", Song: "Uprising", Text: "Paranoia, is in bloom",
+		ReleaseDate: time.Date(2009, 9, 7, 0, 0, 0, 0, time.UTC),
+		UpdatedAt:   time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+		Version:     2,
+	}})
+
+	body, contentType, err := utils.Render(utils.MediaTypeCSV, songs)
+	assert.NoError(t, err)
+	assert.Equal(t, "text/csv; charset=utf-8", contentType)
+	assert.Equal(t, "id,group,song,release_date,text,link,deleted_at,version,updated_at\n"+
+		`1,Muse,Uprising,2009-09-07,"Paranoia, is in bloom",,,2,2024-01-02T03:04:05Z`+"\n", string(body))
+
+	body, contentType, err = utils.Render(utils.MediaTypeXML, songs)
+	assert.NoError(t, err)
+	assert.Equal(t, "application/xml; charset=utf-8", contentType)
+	assert.Contains(t, string(body), `<?xml version="1.0" encoding="UTF-8"?>`+"\n<songs><song><id>1</id><group>Muse</group>")
+	assert.NotContains(t, string(body), "deleted_at")
+
+	body, _, err = utils.Render(utils.MediaTypeYAML, songs)
+	assert.NoError(t, err)
+	assert.Contains(t, string(body), "- id: 1\n  group: Muse\n")
+	assert.Contains(t, string(body), "release_date: 2009-09-07T00:00:00Z\n")
+
+	_, _, err = utils.Render(utils.MediaTypeCSV, map[string]string{"text": "la"})
+	assert.Error(t, err)
+}
@@ -0,0 +1,171 @@
+package workers
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/lmd1e/song_library/app/models"
+	"github.com/lmd1e/song_library/app/repositories"
+	"github.com/lmd1e/song_library/app/services"
+	"github.com/lmd1e/song_library/app/tests/mocks"
+	"github.com/lmd1e/song_library/app/workers"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func newRunner(t *testing.T, jobRepo *mocks.MockJobRepository, songRepo *mocks.MockSongRepository) (*workers.JobRunner, string) {
+	dir := t.TempDir()
+	storage, err := services.NewJobStorage(dir)
+	assert.NoError(t, err)
+	return workers.NewJobRunner(jobRepo, songRepo, new(mocks.MockSongRequest), storage, 1, time.Millisecond, time.Minute), dir
+}
+
+func TestRunImportJobResumesFromCheckpoint(t *testing.T) {
+	jobRepo := new(mocks.MockJobRepository)
+	songRepo := new(mocks.MockSongRepository)
+	runner, dir := newRunner(t, jobRepo, songRepo)
+
+	input := filepath.Join(dir, "upload.csv")
+	csv := "group,song,release_date\n" +
+		"Muse,Uprising,2009-09-07\n" +
+		",Broken,2009-09-07\n" +
+		"Muse,Resistance,2009-09-07\n"
+	assert.NoError(t, os.WriteFile(input, []byte(csv), 0o644))
+
+	// The first two rows were handled before the process stopped.
+	job := models.Job{
+		ID: 1, Kind: models.JobKindImport, Status: models.JobStatusRunning, Attempts: 2,
+		Params: models.JobParams{Format: services.FormatCSV}, InputPath: input,
+		RowsProcessed: 2, RowsFailed: 1, Errors: json.RawMessage(`[{"row":2,"field":"group","error":"is required"}]`),
+	}
+	songRepo.On("AddSongs", mock.Anything, mock.MatchedBy(func(songs []models.Song) bool {
+		return len(songs) == 1 && songs[0].Song == "Resistance"
+	}), models.AuditMeta{}).Return([]models.Song{}, nil).Once()
+	jobRepo.On("WithTx", mock.Anything).Return(songRepo, nil)
+	jobRepo.On("UpdateJobProgress", mock.Anything, mock.AnythingOfType("models.Job")).Return(false, nil)
+	jobRepo.On("FinishJob", mock.Anything, mock.MatchedBy(func(job models.Job) bool {
+		return job.Status == models.JobStatusSucceeded && job.RowsProcessed == 3 && job.RowsFailed == 1 &&
+			assert.JSONEq(t, `[{"row":2,"field":"group","error":"is required"}]`, string(job.Errors))
+	})).Return(nil)
+
+	runner.RunJob(context.Background(), job)
+
+	songRepo.AssertExpectations(t)
+	jobRepo.AssertExpectations(t)
+	_, err := os.Stat(input)
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestRunImportJobLeavesCheckpointWhenLeaseIsLost(t *testing.T) {
+	jobRepo := new(mocks.MockJobRepository)
+	songRepo := new(mocks.MockSongRepository)
+	runner, dir := newRunner(t, jobRepo, songRepo)
+
+	input := filepath.Join(dir, "upload.csv")
+	assert.NoError(t, os.WriteFile(input, []byte("group,song,release_date\nMuse,Uprising,2009-09-07\n"), 0o644))
+	// The batch is stored in the transaction that fails to save the
+	// checkpoint, so both are rolled back.
+	jobRepo.On("WithTx", mock.Anything).Return(songRepo, nil)
+	songRepo.On("AddSongs", mock.Anything, mock.Anything, mock.Anything).Return([]models.Song{}, nil).Once()
+	jobRepo.On("UpdateJobProgress", mock.Anything, mock.MatchedBy(func(job models.Job) bool {
+		return job.RowsProcessed == 1
+	})).Return(false, repositories.ErrJobLeaseLost).Once()
+
+	runner.RunJob(context.Background(), models.Job{ID: 5, Kind: models.JobKindImport, Status: models.JobStatusRunning,
+		Params: models.JobParams{Format: services.FormatCSV}, InputPath: input})
+
+	songRepo.AssertExpectations(t)
+	jobRepo.AssertExpectations(t)
+	jobRepo.AssertNotCalled(t, "FinishJob", mock.Anything, mock.Anything)
+	jobRepo.AssertNotCalled(t, "RequeueJob", mock.Anything, mock.Anything)
+	_, err := os.Stat(input)
+	assert.NoError(t, err)
+}
+
+func TestRunExportJobWritesArtifact(t *testing.T) {
+	jobRepo := new(mocks.MockJobRepository)
+	songRepo := new(mocks.MockSongRepository)
+	runner, dir := newRunner(t, jobRepo, songRepo)
+
+	songs := []models.Song{{ID: 1, Group: "Muse", Song: "Uprising", ReleaseDate: time.Date(2009, 9, 7, 0, 0, 0, 0, time.UTC)}}
+	songRepo.On("ExportSongs", mock.Anything, map[string]string{"group": "Muse"}, mock.Anything).Return(songs, nil)
+	jobRepo.On("UpdateJobProgress", mock.Anything, mock.AnythingOfType("models.Job")).Return(false, nil)
+	var finished models.Job
+	jobRepo.On("FinishJob", mock.Anything, mock.AnythingOfType("models.Job")).Run(func(args mock.Arguments) {
+		finished = args.Get(1).(models.Job)
+	}).Return(nil)
+
+	runner.RunJob(context.Background(), models.Job{
+		ID: 2, Kind: models.JobKindExport, Status: models.JobStatusRunning, Attempts: 1,
+		Params:    models.JobParams{Format: services.FormatCSV, Filter: map[string]string{"group": "Muse"}},
+		CreatedAt: time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC),
+	})
+
+	assert.Equal(t, models.JobStatusSucceeded, finished.Status)
+	assert.Equal(t, 1, finished.RowsProcessed)
+	assert.Equal(t, "songs-20260102-030405.csv", finished.ArtifactName)
+	assert.Equal(t, dir, filepath.Dir(finished.ArtifactPath))
+	data, err := os.ReadFile(finished.ArtifactPath)
+	assert.NoError(t, err)
+	assert.Equal(t, "id,group,song,release_date,text,link\n1,Muse,Uprising,2009-09-07,,\n", string(data))
+}
+
+func TestRunJobCancelledOnProgress(t *testing.T) {
+	jobRepo := new(mocks.MockJobRepository)
+	songRepo := new(mocks.MockSongRepository)
+	runner, dir := newRunner(t, jobRepo, songRepo)
+
+	songs := make([]models.Song, 1500)
+	songRepo.On("ExportSongs", mock.Anything, mock.Anything, mock.Anything).Return(songs, nil)
+	jobRepo.On("UpdateJobProgress", mock.Anything, mock.AnythingOfType("models.Job")).Return(true, nil).Once()
+	jobRepo.On("FinishJob", mock.Anything, mock.MatchedBy(func(job models.Job) bool {
+		return job.Status == models.JobStatusCancelled && job.ArtifactPath == ""
+	})).Return(nil)
+
+	runner.RunJob(context.Background(), models.Job{ID: 3, Kind: models.JobKindExport, Status: models.JobStatusRunning,
+		Params: models.JobParams{Format: services.FormatNDJSON}})
+
+	jobRepo.AssertExpectations(t)
+	entries, _ := os.ReadDir(dir)
+	assert.Empty(t, entries)
+}
+
+func TestRunJobRequeuedOnShutdown(t *testing.T) {
+	jobRepo := new(mocks.MockJobRepository)
+	songRepo := new(mocks.MockSongRepository)
+	runner, _ := newRunner(t, jobRepo, songRepo)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	songs := make([]models.Song, 1500)
+	songRepo.On("ExportSongs", mock.Anything, mock.Anything, mock.Anything).Run(func(mock.Arguments) { cancel() }).Return(songs, nil)
+	jobRepo.On("RequeueJob", mock.Anything, mock.AnythingOfType("models.Job")).Return(nil)
+
+	runner.RunJob(ctx, models.Job{ID: 4, Kind: models.JobKindExport, Status: models.JobStatusRunning,
+		Params: models.JobParams{Format: services.FormatNDJSON}})
+
+	jobRepo.AssertExpectations(t)
+	jobRepo.AssertNotCalled(t, "FinishJob", mock.Anything)
+}
+
+func TestJobPurgerRemovesFinishedJobsAndFiles(t *testing.T) {
+	jobRepo := new(mocks.MockJobRepository)
+	dir := t.TempDir()
+	storage, err := services.NewJobStorage(dir)
+	assert.NoError(t, err)
+
+	artifact := filepath.Join(dir, "job-3-songs.csv")
+	assert.NoError(t, os.WriteFile(artifact, []byte("group,song\n"), 0o644))
+	jobRepo.On("PurgeFinishedJobs", mock.Anything, mock.MatchedBy(func(before time.Time) bool {
+		return time.Since(before) >= 24*time.Hour
+	})).Return([]models.Job{{ID: 3, ArtifactPath: artifact}, {ID: 4, InputPath: filepath.Join(dir, "gone.csv")}}, nil)
+
+	workers.NewJobPurger(jobRepo, storage, 24*time.Hour, time.Hour).PurgeOnce(context.Background())
+
+	_, err = os.Stat(artifact)
+	assert.True(t, os.IsNotExist(err))
+	jobRepo.AssertExpectations(t)
+}
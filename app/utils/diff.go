@@ -0,0 +1,168 @@
+package utils
+
+import (
+	"regexp"
+	"strings"
+)
+
+const (
+	DiffEqual  = "equal"
+	DiffInsert = "insert"
+	DiffDelete = "delete"
+)
+
+type DiffChange struct {
+	Op   string `json:"op"`
+	Text string `json:"text"`
+}
+
+var verseSeparator = regexp.MustCompile(`\n[ \t]*\n`)
+
+// SplitLines splits text into lines, ignoring the difference between \r\n
+// and \n line endings.
+func SplitLines(text string) []string {
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+	if text == "" {
+		return nil
+	}
+	return strings.Split(text, "\n")
+}
+
+// SplitVerses splits text into verses separated by blank lines.
+func SplitVerses(text string) []string {
+	text = strings.TrimSpace(strings.ReplaceAll(text, "\r\n", "\n"))
+	if text == "" {
+		return nil
+	}
+	var verses []string
+	for _, verse := range verseSeparator.Split(text, -1) {
+		if verse = strings.TrimSpace(verse); verse != "" {
+			verses = append(verses, verse)
+		}
+	}
+	return verses
+}
+
+// Diff returns the changes turning a into b along a shortest edit script.
+// It uses Myers' algorithm with linear space refinement, so memory grows with
+// the length of the texts rather than with the product of their lengths.
+func Diff(a, b []string) []DiffChange {
+	return diff(make([]DiffChange, 0, max(len(a), len(b))), a, b)
+}
+
+func diff(changes []DiffChange, a, b []string) []DiffChange {
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	changes = appendChanges(changes, DiffEqual, a[:prefix])
+	a, b = a[prefix:], b[prefix:]
+
+	suffix := 0
+	for suffix < len(a) && suffix < len(b) && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+	common := a[len(a)-suffix:]
+	a, b = a[:len(a)-suffix], b[:len(b)-suffix]
+
+	if x, y, ok := bisect(a, b); ok {
+		changes = diff(changes, a[:x], b[:y])
+		changes = diff(changes, a[x:], b[y:])
+	} else {
+		changes = appendChanges(changes, DiffDelete, a)
+		changes = appendChanges(changes, DiffInsert, b)
+	}
+	return appendChanges(changes, DiffEqual, common)
+}
+
+// bisect finds the point where the forward and backward searches for a
+// shortest edit script meet. It reports false when a and b have nothing in
+// common.
+func bisect(a, b []string) (x, y int, ok bool) {
+	n, m := len(a), len(b)
+	maxD := (n + m + 1) / 2
+	offset := maxD
+	forward := make([]int, 2*maxD+2)
+	backward := make([]int, 2*maxD+2)
+	for i := range forward {
+		forward[i], backward[i] = -1, -1
+	}
+	forward[offset+1], backward[offset+1] = 0, 0
+	delta := n - m
+	// With an odd delta the searches meet while searching forward, with an
+	// even one while searching backward.
+	odd := delta%2 != 0
+	// Diagonals that ran off the edges are skipped on later passes.
+	kStart, kEnd, rStart, rEnd := 0, 0, 0, 0
+
+	for d := 0; d < maxD; d++ {
+		for k := -d + kStart; k <= d-kEnd; k += 2 {
+			var x int
+			if k == -d || (k != d && forward[offset+k-1] < forward[offset+k+1]) {
+				x = forward[offset+k+1]
+			} else {
+				x = forward[offset+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			forward[offset+k] = x
+			switch {
+			case x > n:
+				kEnd += 2
+			case y > m:
+				kStart += 2
+			case odd:
+				if r := offset + delta - k; r >= 0 && r < len(backward) && backward[r] != -1 && x >= n-backward[r] {
+					return splitPoint(x, y, n, m)
+				}
+			}
+		}
+
+		for k := -d + rStart; k <= d-rEnd; k += 2 {
+			var x int
+			if k == -d || (k != d && backward[offset+k-1] < backward[offset+k+1]) {
+				x = backward[offset+k+1]
+			} else {
+				x = backward[offset+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[n-x-1] == b[m-y-1] {
+				x++
+				y++
+			}
+			backward[offset+k] = x
+			switch {
+			case x > n:
+				rEnd += 2
+			case y > m:
+				rStart += 2
+			case !odd:
+				if f := offset + delta - k; f >= 0 && f < len(forward) && forward[f] != -1 {
+					fx := forward[f]
+					if fx >= n-x {
+						return splitPoint(fx, offset+fx-f, n, m)
+					}
+				}
+			}
+		}
+	}
+	return 0, 0, false
+}
+
+// splitPoint rejects a split that would not make either half smaller.
+func splitPoint(x, y, n, m int) (int, int, bool) {
+	if (x == 0 && y == 0) || (x == n && y == m) {
+		return 0, 0, false
+	}
+	return x, y, true
+}
+
+func appendChanges(changes []DiffChange, op string, lines []string) []DiffChange {
+	for _, line := range lines {
+		changes = append(changes, DiffChange{Op: op, Text: line})
+	}
+	return changes
+}
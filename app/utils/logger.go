@@ -1,15 +1,117 @@
 package utils
 
 import (
+	"context"
+	"fmt"
 	"os"
+	"strings"
+	"sync/atomic"
 
 	"github.com/sirupsen/logrus"
 )
 
+const (
+	LogFormatJSON = "json"
+	LogFormatText = "text"
+)
+
 var Logger = logrus.New()
 
+var logFormat atomic.Value
+
 func init() {
 	Logger.SetFormatter(&logrus.JSONFormatter{})
+	logFormat.Store(LogFormatJSON)
 	Logger.SetOutput(os.Stdout)
-	Logger.SetLevel(logrus.DebugLevel)
+	Logger.SetLevel(logrus.InfoLevel)
+}
+
+// ConfigureLogger applies a level and format, keeping the current ones for
+// empty values.
+func ConfigureLogger(level, format string) error {
+	if level != "" {
+		if err := SetLogLevel(level); err != nil {
+			return err
+		}
+	}
+	if format != "" {
+		if err := SetLogFormat(format); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ParseLogLevel parses a level name such as "info" or "warn".
+func ParseLogLevel(level string) (logrus.Level, error) {
+	parsed, err := logrus.ParseLevel(level)
+	if err != nil {
+		return 0, fmt.Errorf("invalid log level %q, expected trace, debug, info, warn or error", level)
+	}
+	return parsed, nil
+}
+
+// SetLogLevel changes the level of Logger.
+func SetLogLevel(level string) error {
+	parsed, err := ParseLogLevel(level)
+	if err != nil {
+		return err
+	}
+	Logger.SetLevel(parsed)
+	return nil
+}
+
+// ValidLogFormat reports whether format can be passed to SetLogFormat.
+func ValidLogFormat(format string) bool {
+	format = strings.ToLower(format)
+	return format == LogFormatJSON || format == LogFormatText
+}
+
+// SetLogFormat switches Logger between JSON and plain text output.
+func SetLogFormat(format string) error {
+	format = strings.ToLower(format)
+	switch format {
+	case LogFormatJSON:
+		Logger.SetFormatter(&logrus.JSONFormatter{})
+	case LogFormatText:
+		Logger.SetFormatter(&logrus.TextFormatter{FullTimestamp: true})
+	default:
+		return fmt.Errorf("invalid log format %q: expected json or text", format)
+	}
+	logFormat.Store(format)
+	return nil
+}
+
+// LogFormat returns the current output format of Logger.
+func LogFormat() string {
+	return logFormat.Load().(string)
+}
+
+type loggerKey struct{}
+
+type requestIDKey struct{}
+
+// ContextWithLogger returns a copy of ctx carrying logger.
+func ContextWithLogger(ctx context.Context, logger *logrus.Entry) context.Context {
+	return context.WithValue(ctx, loggerKey{}, logger)
+}
+
+// LoggerFromContext returns the logger attached to ctx, which carries the
+// fields of the request being served, or Logger itself when there is none.
+func LoggerFromContext(ctx context.Context) *logrus.Entry {
+	if logger, ok := ctx.Value(loggerKey{}).(*logrus.Entry); ok {
+		return logger
+	}
+	return logrus.NewEntry(Logger)
+}
+
+// ContextWithRequestID returns a copy of ctx carrying the id of the request.
+func ContextWithRequestID(ctx context.Context, requestID string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, requestID)
+}
+
+// RequestIDFromContext returns the request id stored in ctx, if any.
+func RequestIDFromContext(ctx context.Context) string {
+	requestID, _ := ctx.Value(requestIDKey{}).(string)
+	return requestID
 }
@@ -0,0 +1,147 @@
+package utils
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// lastCueDuration is how long the last WebVTT cue is shown when nothing
+// follows it.
+const lastCueDuration = 5 * time.Second
+
+var (
+	lrcTimeTag = regexp.MustCompile(`^\[(\d+):(\d{1,2})(?:[.:](\d{1,3}))?\]`)
+	lrcInfoTag = regexp.MustCompile(`^\[([A-Za-z#]+):(.*)\]$`)
+)
+
+// ParseLRC reads lyrics in the LRC format and returns their lines in time
+// order with the start of each. A line with several time tags, such as a
+// repeated chorus, is returned once per tag. The offset tag is applied;
+// other ID tags and blank lines are skipped.
+func ParseLRC(r io.Reader) (lines []string, starts []time.Duration, err error) {
+	type timedLine struct {
+		start time.Duration
+		text  string
+	}
+	var timed []timedLine
+	var offset time.Duration
+	scanner := bufio.NewScanner(r)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if n == 1 {
+			line = strings.TrimPrefix(line, "\ufeff")
+		}
+		if line == "" {
+			continue
+		}
+
+		var lineStarts []time.Duration
+		for {
+			match := lrcTimeTag.FindStringSubmatch(line)
+			if match == nil {
+				break
+			}
+			start, err := lrcTimestamp(match[1], match[2], match[3])
+			if err != nil {
+				return nil, nil, fmt.Errorf("line %d: %w", n, err)
+			}
+			lineStarts = append(lineStarts, start)
+			line = line[len(match[0]):]
+		}
+		if len(lineStarts) > 0 {
+			text := strings.TrimSpace(line)
+			for _, start := range lineStarts {
+				timed = append(timed, timedLine{start: start, text: text})
+			}
+			continue
+		}
+
+		match := lrcInfoTag.FindStringSubmatch(line)
+		if match == nil {
+			return nil, nil, fmt.Errorf("line %d: expected a [mm:ss.xx] time tag", n)
+		}
+		if strings.EqualFold(match[1], "offset") {
+			ms, err := strconv.Atoi(strings.TrimSpace(match[2]))
+			if err != nil {
+				return nil, nil, fmt.Errorf("line %d: invalid offset %q", n, match[2])
+			}
+			offset = time.Duration(ms) * time.Millisecond
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, nil, err
+	}
+	if len(timed) == 0 {
+		return nil, nil, fmt.Errorf("no timed lines")
+	}
+
+	sort.SliceStable(timed, func(i, j int) bool { return timed[i].start < timed[j].start })
+	lines = make([]string, len(timed))
+	starts = make([]time.Duration, len(timed))
+	for i, t := range timed {
+		lines[i] = t.text
+		// A positive offset makes the lyrics appear sooner.
+		starts[i] = max(t.start-offset, 0)
+	}
+	return lines, starts, nil
+}
+
+func lrcTimestamp(minutes, seconds, fraction string) (time.Duration, error) {
+	m, _ := strconv.Atoi(minutes)
+	s, _ := strconv.Atoi(seconds)
+	if s >= 60 {
+		return 0, fmt.Errorf("invalid time tag [%s:%s]", minutes, seconds)
+	}
+	// The fraction is in tenths, hundredths or thousandths of a second.
+	ms := 0
+	if fraction != "" {
+		ms, _ = strconv.Atoi((fraction + "00")[:3])
+	}
+	return time.Duration(m)*time.Minute + time.Duration(s)*time.Second + time.Duration(ms)*time.Millisecond, nil
+}
+
+// FormatLRC writes lines as LRC, each with its [mm:ss.xx] time tag.
+func FormatLRC(lines []string, starts []time.Duration) []byte {
+	var sb strings.Builder
+	for i, line := range lines {
+		start := starts[i]
+		fmt.Fprintf(&sb, "[%02d:%02d.%02d]%s\n",
+			int(start/time.Minute), int(start%time.Minute/time.Second), int(start%time.Second/(10*time.Millisecond)), line)
+	}
+	return []byte(sb.String())
+}
+
+// FormatWebVTT writes lines as WebVTT cues. Each cue lasts until the next
+// line starts; the last one until end, or for five seconds when end is not
+// after its start. Blank lines only end the previous cue.
+func FormatWebVTT(lines []string, starts []time.Duration, end time.Duration) []byte {
+	var sb strings.Builder
+	sb.WriteString("WEBVTT\n")
+	for i, line := range lines {
+		if line == "" {
+			continue
+		}
+		cueEnd := end
+		if i+1 < len(starts) {
+			cueEnd = starts[i+1]
+		}
+		if cueEnd <= starts[i] {
+			cueEnd = starts[i] + lastCueDuration
+		}
+		fmt.Fprintf(&sb, "\n%s --> %s\n%s\n", vttTimestamp(starts[i]), vttTimestamp(cueEnd), vttEscaper.Replace(line))
+	}
+	return []byte(sb.String())
+}
+
+var vttEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
+
+func vttTimestamp(d time.Duration) string {
+	return fmt.Sprintf("%02d:%02d:%02d.%03d",
+		int(d/time.Hour), int(d%time.Hour/time.Minute), int(d%time.Minute/time.Second), int(d%time.Second/time.Millisecond))
+}
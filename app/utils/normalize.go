@@ -0,0 +1,44 @@
+package utils
+
+import (
+	"reflect"
+	"strings"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// NormalizeString trims surrounding whitespace and brings s to Unicode
+// normalization form C, so that visually equal strings compare and count
+// equal.
+func NormalizeString(s string) string {
+	return norm.NFC.String(strings.TrimSpace(s))
+}
+
+// NormalizeStrings applies NormalizeString to every string reachable from v,
+// a pointer, through struct fields, pointers and slices.
+func NormalizeStrings(v any) {
+	normalizeValue(reflect.ValueOf(v))
+}
+
+func normalizeValue(v reflect.Value) {
+	switch v.Kind() {
+	case reflect.Pointer, reflect.Interface:
+		if !v.IsNil() {
+			normalizeValue(v.Elem())
+		}
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			if v.Type().Field(i).IsExported() {
+				normalizeValue(v.Field(i))
+			}
+		}
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			normalizeValue(v.Index(i))
+		}
+	case reflect.String:
+		if v.CanSet() {
+			v.SetString(NormalizeString(v.String()))
+		}
+	}
+}
@@ -0,0 +1,60 @@
+package utils
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ProblemContentType is the media type of error responses (RFC 7807).
+const ProblemContentType = "application/problem+json"
+
+// Problem is the body of every error response, following RFC 7807 with the
+// extension members code, errors and request_id.
+type Problem struct {
+	// Type is always about:blank: the status and code identify the problem.
+	Type   string `json:"type" example:"about:blank"`
+	Title  string `json:"title" example:"Not Found"`
+	Status int    `json:"status" example:"404"`
+	// Detail is a human-readable message about this occurrence.
+	Detail   string `json:"detail" example:"Song not found"`
+	Instance string `json:"instance,omitempty" example:"/songs/42"`
+	// Code is a stable machine-readable identifier of the problem.
+	Code      string       `json:"code" example:"song_not_found"`
+	Errors    []FieldError `json:"errors,omitempty"`
+	RequestID string       `json:"request_id,omitempty" example:"4bf92f3577b34da6a3ce929d0e0e4736"`
+}
+
+// FieldError describes an invalid field of the request.
+type FieldError struct {
+	Field   string `json:"field" example:"group"`
+	Code    string `json:"code" example:"required"`
+	Message string `json:"message" example:"is required"`
+}
+
+// NewProblem returns a problem about r with its path and request id.
+func NewProblem(r *http.Request, status int, code, detail string) Problem {
+	return Problem{
+		Type:      "about:blank",
+		Title:     http.StatusText(status),
+		Status:    status,
+		Detail:    detail,
+		Instance:  r.URL.Path,
+		Code:      code,
+		RequestID: RequestIDFromContext(r.Context()),
+	}
+}
+
+// RespondWithProblem aborts the request with a problem response.
+func RespondWithProblem(ctx *gin.Context, status int, code, detail string, fields ...FieldError) {
+	problem := NewProblem(ctx.Request, status, code, detail)
+	problem.Errors = fields
+	WriteProblem(ctx, status, problem)
+}
+
+// WriteProblem aborts the request with body, a Problem or a struct embedding
+// one to add members, as application/problem+json.
+func WriteProblem(ctx *gin.Context, status int, body any) {
+	ctx.Header("Content-Type", ProblemContentType)
+	ctx.AbortWithStatusJSON(status, body)
+}
@@ -0,0 +1,193 @@
+package utils
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"mime"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Media types responses can be rendered in.
+const (
+	MediaTypeJSON = "application/json"
+	MediaTypeXML  = "application/xml"
+	MediaTypeCSV  = "text/csv"
+	MediaTypeYAML = "application/yaml"
+
+	MediaTypePlainText = "text/plain"
+	MediaTypeLRC       = "text/x-lrc"
+	MediaTypeWebVTT    = "text/vtt"
+)
+
+// RenderMediaTypes lists the media types Render supports, in the order
+// preferred when a client accepts several of them equally.
+var RenderMediaTypes = []string{MediaTypeJSON, MediaTypeXML, MediaTypeCSV, MediaTypeYAML}
+
+// LyricMediaTypes lists the additional media types lyrics are rendered in.
+var LyricMediaTypes = []string{MediaTypePlainText, MediaTypeLRC, MediaTypeWebVTT}
+
+// CSVMarshaler is implemented by the response types that can be rendered as
+// CSV. The header row comes first, followed by one record per item.
+type CSVMarshaler interface {
+	MarshalCSV() (header []string, records [][]string)
+}
+
+// PlainTextMarshaler, LRCMarshaler and WebVTTMarshaler are implemented by
+// the response types that can be rendered as plain text, LRC and WebVTT.
+type PlainTextMarshaler interface {
+	MarshalPlainText() []byte
+}
+
+type LRCMarshaler interface {
+	MarshalLRC() []byte
+}
+
+type WebVTTMarshaler interface {
+	MarshalWebVTT() []byte
+}
+
+// Render encodes v as mediaType and returns the body with its Content-Type.
+// The media types beyond JSON, XML and YAML require v to implement the
+// matching marshaler interface.
+func Render(mediaType string, v any) ([]byte, string, error) {
+	var body []byte
+	var err error
+	switch mediaType {
+	case MediaTypeJSON:
+		body, err = json.Marshal(v)
+	case MediaTypeXML:
+		body, err = xml.Marshal(v)
+		body = append([]byte(xml.Header), body...)
+	case MediaTypeCSV:
+		body, err = renderCSV(v)
+	case MediaTypeYAML:
+		body, err = yaml.Marshal(v)
+	case MediaTypePlainText, MediaTypeLRC, MediaTypeWebVTT:
+		body, err = renderLyrics(mediaType, v)
+	default:
+		err = fmt.Errorf("unsupported media type %q", mediaType)
+	}
+	if err != nil {
+		return nil, "", err
+	}
+	return body, mediaType + "; charset=utf-8", nil
+}
+
+func renderLyrics(mediaType string, v any) ([]byte, error) {
+	switch mediaType {
+	case MediaTypePlainText:
+		if marshaler, ok := v.(PlainTextMarshaler); ok {
+			return marshaler.MarshalPlainText(), nil
+		}
+	case MediaTypeLRC:
+		if marshaler, ok := v.(LRCMarshaler); ok {
+			return marshaler.MarshalLRC(), nil
+		}
+	case MediaTypeWebVTT:
+		if marshaler, ok := v.(WebVTTMarshaler); ok {
+			return marshaler.MarshalWebVTT(), nil
+		}
+	}
+	return nil, fmt.Errorf("%T cannot be rendered as %s", v, mediaType)
+}
+
+func renderCSV(v any) ([]byte, error) {
+	marshaler, ok := v.(CSVMarshaler)
+	if !ok {
+		return nil, fmt.Errorf("%T cannot be rendered as CSV", v)
+	}
+	header, records := marshaler.MarshalCSV()
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	w.Write(header)
+	w.WriteAll(records)
+	return buf.Bytes(), w.Error()
+}
+
+// NegotiateMediaType returns the offered media type the Accept header
+// prefers, honouring quality values and wildcards, or "" when none is
+// acceptable. A missing header accepts the first offered type.
+func NegotiateMediaType(accept string, offered []string) string {
+	if strings.TrimSpace(accept) == "" {
+		return offered[0]
+	}
+	ranges := parseAccept(accept)
+	best, bestQ := "", 0.0
+	for _, mediaType := range offered {
+		if q := acceptQuality(ranges, mediaType); q > bestQ {
+			best, bestQ = mediaType, q
+		}
+	}
+	return best
+}
+
+type mediaRange struct {
+	mediaType string
+	q         float64
+}
+
+func parseAccept(accept string) []mediaRange {
+	var ranges []mediaRange
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		q := 1.0
+		if value, ok := params["q"]; ok {
+			if q, err = strconv.ParseFloat(value, 64); err != nil {
+				continue
+			}
+		}
+		ranges = append(ranges, mediaRange{mediaType: mediaType, q: q})
+	}
+	return ranges
+}
+
+// acceptQuality returns the quality of mediaType given by the most specific
+// matching range: type/subtype, then type/*, then */*.
+func acceptQuality(ranges []mediaRange, mediaType string) float64 {
+	typ, _, _ := strings.Cut(mediaType, "/")
+	q, specificity := 0.0, 0
+	for _, r := range ranges {
+		var s int
+		switch r.mediaType {
+		case mediaType:
+			s = 3
+		case typ + "/*":
+			s = 2
+		case "*/*":
+			s = 1
+		default:
+			continue
+		}
+		if s > specificity {
+			q, specificity = r.q, s
+		}
+	}
+	return q
+}
+
+type mediaTypeKey struct{}
+
+// ContextWithMediaType returns a copy of ctx carrying the media type the
+// response is to be rendered in.
+func ContextWithMediaType(ctx context.Context, mediaType string) context.Context {
+	return context.WithValue(ctx, mediaTypeKey{}, mediaType)
+}
+
+// MediaTypeFromContext returns the negotiated media type of the request,
+// or JSON when none was negotiated.
+func MediaTypeFromContext(ctx context.Context) string {
+	if mediaType, ok := ctx.Value(mediaTypeKey{}).(string); ok {
+		return mediaType
+	}
+	return MediaTypeJSON
+}
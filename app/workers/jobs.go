@@ -0,0 +1,298 @@
+package workers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/lmd1e/song_library/app/models"
+	"github.com/lmd1e/song_library/app/repositories"
+	"github.com/lmd1e/song_library/app/requests"
+	"github.com/lmd1e/song_library/app/services"
+	"github.com/lmd1e/song_library/app/tracing"
+	"github.com/lmd1e/song_library/app/utils"
+	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var errJobCancelled = errors.New("job cancelled")
+
+// JobRunner runs queued import and export jobs on a pool of workers. Jobs are
+// claimed with a lease that the worker keeps renewing; if the process stops,
+// the lease runs out and the job is picked up again, continuing an import
+// from its last saved checkpoint and starting an export over.
+type JobRunner struct {
+	jobs         repositories.JobRepository
+	songs        repositories.SongRepository
+	details      requests.SongDetailClient
+	storage      *services.JobStorage
+	workers      int
+	pollInterval time.Duration
+	lease        time.Duration
+}
+
+func NewJobRunner(jobs repositories.JobRepository, songs repositories.SongRepository, details requests.SongDetailClient,
+	storage *services.JobStorage, workers int, pollInterval, lease time.Duration) *JobRunner {
+	return &JobRunner{
+		jobs:         jobs,
+		songs:        songs,
+		details:      details,
+		storage:      storage,
+		workers:      workers,
+		pollInterval: pollInterval,
+		lease:        lease,
+	}
+}
+
+// Run starts the workers and returns once ctx is cancelled and they have
+// stopped. Jobs interrupted by the cancellation are put back in the queue.
+func (r *JobRunner) Run(ctx context.Context) {
+	var wg sync.WaitGroup
+	for i := 0; i < r.workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			r.work(ctx)
+		}()
+	}
+	wg.Wait()
+}
+
+func (r *JobRunner) work(ctx context.Context) {
+	for ctx.Err() == nil {
+		job, err := r.jobs.ClaimJob(ctx, r.lease)
+		if err == nil && job != nil {
+			r.RunJob(ctx, *job)
+			continue
+		}
+		select {
+		case <-ctx.Done():
+		case <-time.After(r.pollInterval):
+		}
+	}
+}
+
+// RunJob runs a claimed job to completion and records its outcome.
+func (r *JobRunner) RunJob(ctx context.Context, job models.Job) {
+	// Logs of the job, including those of the repositories, carry the id of
+	// the request that submitted it.
+	logger := utils.Logger.WithFields(logrus.Fields{"job_id": job.ID, "request_id": job.RequestID})
+	ctx = utils.ContextWithLogger(ctx, logger)
+	logger.Infof("Running %s job %d (attempt %d)", job.Kind, job.ID, job.Attempts)
+	ctx, span := tracing.Tracer().Start(ctx, "JobRunner.RunJob", trace.WithAttributes(
+		attribute.Int64("job.id", job.ID),
+		attribute.String("job.kind", string(job.Kind)),
+		attribute.Int("job.attempt", job.Attempts),
+	))
+	defer span.End()
+	jobCtx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+
+	var heartbeat sync.WaitGroup
+	heartbeat.Add(1)
+	go func() {
+		defer heartbeat.Done()
+		r.heartbeat(jobCtx, job, cancel)
+	}()
+
+	var err error
+	switch {
+	case job.CancelRequested:
+		// Cancelled while no worker held it, e.g. after a restart.
+		err = errJobCancelled
+	case job.Kind == models.JobKindImport:
+		err = r.runImport(jobCtx, &job)
+	case job.Kind == models.JobKindExport:
+		err = r.runExport(jobCtx, &job)
+	default:
+		err = fmt.Errorf("unknown job kind %q", job.Kind)
+	}
+	cause := context.Cause(jobCtx)
+	cancel(nil)
+	heartbeat.Wait()
+
+	switch {
+	case err == nil:
+		job.Status = models.JobStatusSucceeded
+	case errors.Is(err, errJobCancelled) || errors.Is(cause, errJobCancelled):
+		job.Status = models.JobStatusCancelled
+	case errors.Is(err, repositories.ErrJobLeaseLost) || errors.Is(cause, repositories.ErrJobLeaseLost):
+		logger.Warnf("Job %d was taken over by another worker", job.ID)
+		return
+	case ctx.Err() != nil:
+		logger.Infof("Stopping job %d, it will be resumed later", job.ID)
+		if err := r.jobs.RequeueJob(context.WithoutCancel(ctx), job); err != nil {
+			logger.Error("Failed to requeue job: ", err)
+		}
+		return
+	default:
+		logger.Error("Job failed: ", err)
+		job.Status = models.JobStatusFailed
+		job.Error = err.Error()
+	}
+
+	// The outcome is recorded even if the runner is stopping meanwhile.
+	if err := r.jobs.FinishJob(context.WithoutCancel(ctx), job); err != nil {
+		logger.Error("Failed to finish job: ", err)
+		return
+	}
+	if job.InputPath != "" {
+		os.Remove(job.InputPath)
+	}
+	logger.Infof("Job %d %s", job.ID, job.Status)
+}
+
+// heartbeat renews the lease of a job until ctx is done, cancelling the job
+// when its cancellation is requested or the lease is lost.
+func (r *JobRunner) heartbeat(ctx context.Context, job models.Job, cancel context.CancelCauseFunc) {
+	ticker := time.NewTicker(r.lease / 3)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		cancelRequested, err := r.jobs.HeartbeatJob(ctx, job, r.lease)
+		if errors.Is(err, repositories.ErrJobLeaseLost) {
+			cancel(err)
+			return
+		}
+		if err == nil && cancelRequested {
+			cancel(errJobCancelled)
+			return
+		}
+	}
+}
+
+// saveProgress stores the progress of an export and stops it if it has been
+// cancelled in the meantime.
+func (r *JobRunner) saveProgress(ctx context.Context, job models.Job) error {
+	if ctx.Err() != nil {
+		return context.Cause(ctx)
+	}
+	cancelRequested, err := r.jobs.UpdateJobProgress(ctx, job)
+	if err != nil {
+		return err
+	}
+	if cancelRequested {
+		return errJobCancelled
+	}
+	return nil
+}
+
+func (r *JobRunner) runImport(ctx context.Context, job *models.Job) error {
+	f, err := os.Open(job.InputPath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	opts := services.ImportOptions{
+		Format:  job.Params.Format,
+		Mapping: job.Params.Mapping,
+		Enrich:  job.Params.Enrich,
+		DryRun:  job.Params.DryRun,
+	}
+	if job.RowsProcessed > 0 {
+		checkpoint := services.ImportResult{
+			Rows:     job.RowsProcessed,
+			Imported: job.RowsProcessed - job.RowsFailed,
+			Failed:   job.RowsFailed,
+		}
+		if err := json.Unmarshal(job.Errors, &checkpoint.Errors); err != nil {
+			return err
+		}
+		opts.Checkpoint = &checkpoint
+	}
+	meta := models.AuditMeta{Actor: job.Actor, RequestID: job.RequestID}
+	opts.Store = func(batch []models.Song, result services.ImportResult) error {
+		if ctx.Err() != nil {
+			return context.Cause(ctx)
+		}
+		saved := *job
+		if err := setImportProgress(&saved, result); err != nil {
+			return err
+		}
+		// A batch is committed together with the checkpoint that counts it,
+		// and only while the job is held, so a resumed import neither repeats
+		// nor skips rows.
+		var cancelRequested bool
+		err := r.jobs.WithTx(ctx, func(jobs repositories.JobRepository, songs repositories.SongRepository) error {
+			if len(batch) > 0 {
+				if _, err := songs.AddSongs(ctx, batch, meta); err != nil {
+					return err
+				}
+			}
+			var err error
+			cancelRequested, err = jobs.UpdateJobProgress(ctx, saved)
+			return err
+		})
+		if err != nil {
+			return err
+		}
+		*job = saved
+		if cancelRequested {
+			return errJobCancelled
+		}
+		return nil
+	}
+
+	result, err := services.NewImporter(r.songs, r.details).Import(ctx, f, opts, meta)
+	if err != nil {
+		// The job keeps the counts of its last checkpoint, which match
+		// what has been stored.
+		return err
+	}
+	return setImportProgress(job, result)
+}
+
+func setImportProgress(job *models.Job, result services.ImportResult) error {
+	errs, err := json.Marshal(result.Errors)
+	if err != nil {
+		return err
+	}
+	job.RowsProcessed = result.Rows
+	job.RowsFailed = result.Failed
+	job.Errors = errs
+	return nil
+}
+
+func (r *JobRunner) runExport(ctx context.Context, job *models.Job) error {
+	opts := services.ExportOptions{
+		Format: job.Params.Format,
+		Filter: job.Params.Filter,
+		Gzip:   job.Params.Gzip,
+	}
+	opts.Progress = func(count int) error {
+		job.RowsProcessed = count
+		return r.saveProgress(ctx, *job)
+	}
+	name := opts.Filename(job.CreatedAt)
+	f, commit, err := r.storage.CreateArtifact(job.ID, name)
+	if err != nil {
+		return err
+	}
+
+	job.RowsProcessed = 0
+	count, err := services.NewExporter(r.songs).Export(ctx, f, opts)
+	if err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return err
+	}
+	path, err := commit()
+	if err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+	job.RowsProcessed = count
+	job.ArtifactPath = path
+	job.ArtifactName = name
+	return nil
+}
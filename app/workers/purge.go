@@ -0,0 +1,91 @@
+package workers
+
+import (
+	"context"
+	"time"
+
+	"github.com/lmd1e/song_library/app/models"
+	"github.com/lmd1e/song_library/app/repositories"
+	"github.com/lmd1e/song_library/app/services"
+	"github.com/lmd1e/song_library/app/utils"
+)
+
+// TrashPurger periodically removes songs that have been in the trash for
+// longer than the retention period.
+type TrashPurger struct {
+	repo      repositories.SongRepository
+	retention time.Duration
+	interval  time.Duration
+}
+
+func NewTrashPurger(repo repositories.SongRepository, retention, interval time.Duration) *TrashPurger {
+	return &TrashPurger{repo: repo, retention: retention, interval: interval}
+}
+
+// Run purges the trash every interval until ctx is cancelled.
+func (p *TrashPurger) Run(ctx context.Context) {
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+	for {
+		p.PurgeOnce(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (p *TrashPurger) PurgeOnce(ctx context.Context) {
+	purged, err := p.repo.PurgeDeletedSongs(ctx, time.Now().Add(-p.retention), models.AuditMeta{Actor: "system:trash-purger"})
+	if err != nil {
+		utils.Logger.Error("Failed to purge trash: ", err)
+		return
+	}
+	if purged > 0 {
+		utils.Logger.Infof("Purged %d songs from the trash", purged)
+	}
+}
+
+// JobPurger periodically removes jobs that finished longer than the retention
+// period ago, together with their uploads and artifacts.
+type JobPurger struct {
+	repo      repositories.JobRepository
+	storage   *services.JobStorage
+	retention time.Duration
+	interval  time.Duration
+}
+
+func NewJobPurger(repo repositories.JobRepository, storage *services.JobStorage, retention, interval time.Duration) *JobPurger {
+	return &JobPurger{repo: repo, storage: storage, retention: retention, interval: interval}
+}
+
+// Run purges finished jobs every interval until ctx is cancelled.
+func (p *JobPurger) Run(ctx context.Context) {
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+	for {
+		p.PurgeOnce(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (p *JobPurger) PurgeOnce(ctx context.Context) {
+	jobs, err := p.repo.PurgeFinishedJobs(ctx, time.Now().Add(-p.retention))
+	if err != nil {
+		utils.Logger.Error("Failed to purge finished jobs: ", err)
+		return
+	}
+	for _, job := range jobs {
+		if err := p.storage.Remove(job); err != nil {
+			utils.Logger.Errorf("Failed to remove files of job %d: %v", job.ID, err)
+		}
+	}
+	if len(jobs) > 0 {
+		utils.Logger.Infof("Purged %d finished jobs", len(jobs))
+	}
+}